@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/palantir/stacktrace"
+	"github.com/spf13/cobra"
+
+	"github.com/Kdag-K/kdag/src/crypto"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+var (
+	archiveDB     string
+	archiveFile   string
+	archiveEvents bool
+	archiveKey    string
+)
+
+// NewExportCmd produces an ExportCmd which writes the history of a stopped
+// node's database to an archive file.
+func NewExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the consensus history to an archive",
+		Long: `Export the consensus history to an archive
+
+Writes the blocks, signatures, frames and peer-sets contained in a badger
+database to a portable archive file. Use --events to include the raw hashgraph
+events, which are necessary to bootstrap a node from the archive. The database
+must not be in use by a running node.`,
+		RunE: exportArchive,
+	}
+
+	AddExportFlags(cmd)
+
+	return cmd
+}
+
+// NewImportCmd produces an ImportCmd which seeds a fresh database from an
+// archive file.
+func NewImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import the consensus history from an archive",
+		Long: `Import the consensus history from an archive
+
+Seeds a fresh badger database with the content of an archive produced by
+"kdag export". If the archive contains raw events, a node can then be started
+from the database with "kdag run --bootstrap".`,
+		RunE: importArchive,
+	}
+
+	AddImportFlags(cmd)
+
+	return cmd
+}
+
+//AddExportFlags adds flags to the export command
+func AddExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&archiveDB, "db", _config.Kdag.DatabaseDir, "Database directory")
+	cmd.Flags().StringVarP(&archiveFile, "file", "f", "kdag.archive", "File where the archive will be written")
+	cmd.Flags().BoolVar(&archiveEvents, "events", false, "Include raw hashgraph events")
+	cmd.Flags().StringVar(&archiveKey, "db-key", "", "File containing the encryption key of an encrypted database")
+}
+
+//AddImportFlags adds flags to the import command
+func AddImportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&archiveDB, "db", _config.Kdag.DatabaseDir, "Database directory")
+	cmd.Flags().StringVarP(&archiveFile, "file", "f", "kdag.archive", "Archive file to import")
+	cmd.Flags().StringVar(&archiveKey, "db-key", "", "File containing the database encryption key, created if necessary")
+}
+
+func exportArchive(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(archiveDB); err != nil {
+		return stacktrace.NewError("No database found under: %s", archiveDB)
+	}
+
+	key, err := crypto.ReadOrCreateSymmetricKey(archiveKey, false)
+	if err != nil {
+		return stacktrace.NewError("Reading key: %s", err)
+	}
+
+	store, err := h.NewBadgerStore(_config.Kdag.CacheSize, _config.Kdag.CacheBytes, archiveDB, true, false, key, nil)
+	if err != nil {
+		return stacktrace.NewError("Opening database: %s", err)
+	}
+	defer store.Close()
+
+	f, err := os.Create(archiveFile)
+	if err != nil {
+		return stacktrace.NewError("Creating archive: %s", err)
+	}
+	defer f.Close()
+
+	if err := store.Export(f, archiveEvents); err != nil {
+		return stacktrace.NewError("Exporting: %s", err)
+	}
+
+	fmt.Printf("The archive has been saved to: %s\n", archiveFile)
+
+	return nil
+}
+
+func importArchive(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(archiveFile)
+	if err != nil {
+		return stacktrace.NewError("Opening archive: %s", err)
+	}
+	defer f.Close()
+
+	key, err := crypto.ReadOrCreateSymmetricKey(archiveKey, true)
+	if err != nil {
+		return stacktrace.NewError("Reading key: %s", err)
+	}
+
+	store, err := h.NewBadgerStore(_config.Kdag.CacheSize, _config.Kdag.CacheBytes, archiveDB, false, false, key, nil)
+	if err != nil {
+		return stacktrace.NewError("Opening database: %s", err)
+	}
+	defer store.Close()
+
+	if err := store.Import(f); err != nil {
+		return stacktrace.NewError("Importing: %s", err)
+	}
+
+	fmt.Printf("The archive has been imported to: %s\n", archiveDB)
+
+	return nil
+}
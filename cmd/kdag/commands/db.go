@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/palantir/stacktrace"
+	"github.com/spf13/cobra"
+
+	"github.com/Kdag-K/kdag/src/crypto"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+var (
+	dbDir     string
+	dbKeyfile string
+)
+
+// NewDBCmd produces a DBCmd which groups the database maintenance commands.
+func NewDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Database maintenance",
+		Long: `Database maintenance
+
+A database created with --encrypt-db only has its values encrypted. The
+database keys, which contain Event and transaction hashes, public keys, round
+and block indexes, and block timestamps, are stored in plaintext.`,
+	}
+
+	cmd.AddCommand(NewRotateKeyCmd())
+
+	return cmd
+}
+
+// NewRotateKeyCmd produces a RotateKeyCmd which replaces the key that encrypts
+// a database.
+func NewRotateKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Rotate the database encryption key",
+		Long: `Rotate the database encryption key
+
+Generates a new key for a database created with --encrypt-db, and replaces the
+key file with it. The data itself is encrypted with an internal key, which is
+re-encrypted with the new key, so the operation does not depend on the size of
+the database. The database must not be in use by a running node.
+
+The new key is first written to a temporary file with the .new suffix, which
+should be moved over the key file manually if the command is interrupted after
+updating the database.`,
+		RunE: rotateKey,
+	}
+
+	AddRotateKeyFlags(cmd)
+
+	return cmd
+}
+
+//AddRotateKeyFlags adds flags to the rotate-key command
+func AddRotateKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&dbDir, "db", _config.Kdag.DatabaseDir, "Database directory")
+	cmd.Flags().StringVar(&dbKeyfile, "key", _config.Kdag.DBKeyfile(), "File containing the database encryption key")
+}
+
+func rotateKey(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(dbDir); err != nil {
+		return stacktrace.NewError("No database found under: %s", dbDir)
+	}
+
+	oldKey, err := crypto.ReadSymmetricKey(dbKeyfile)
+	if err != nil {
+		return stacktrace.NewError("Reading key: %s", err)
+	}
+
+	store, err := h.NewBadgerStore(_config.Kdag.CacheSize, _config.Kdag.CacheBytes, dbDir, false, false, oldKey, nil)
+	if err != nil {
+		return stacktrace.NewError("Opening database: %s", err)
+	}
+	defer store.Close()
+
+	newKey, err := crypto.GenerateSymmetricKey()
+	if err != nil {
+		return stacktrace.NewError("Generating key: %s", err)
+	}
+
+	newKeyfile := dbKeyfile + ".new"
+	if err := crypto.WriteSymmetricKey(newKeyfile, newKey); err != nil {
+		return stacktrace.NewError("Writing key: %s", err)
+	}
+
+	if err := store.RotateEncryptionKey(newKey); err != nil {
+		os.Remove(newKeyfile)
+		return stacktrace.NewError("Rotating key: %s", err)
+	}
+
+	if err := os.Rename(newKeyfile, dbKeyfile); err != nil {
+		return stacktrace.NewError("Replacing key: %s", err)
+	}
+
+	fmt.Printf("The new key has been saved to: %s\n", dbKeyfile)
+
+	return nil
+}
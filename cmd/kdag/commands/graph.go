@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/palantir/stacktrace"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto"
+	"github.com/Kdag-K/kdag/src/genesis"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+var (
+	graphDataDir   string
+	graphDB        string
+	graphKey       string
+	graphFormat    string
+	graphFile      string
+	graphFromRound int
+	graphToRound   int
+	graphFromTime  int64
+	graphToTime    int64
+)
+
+// NewGraphCmd produces a GraphCmd which exports the hashgraph of a stopped
+// node's database for visualization.
+func NewGraphCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export the hashgraph for visualization",
+		Long: `Export the hashgraph for visualization
+
+Replays the events of a badger database to recompute rounds, fame and
+round-received, and writes a window of the hashgraph in Graphviz DOT or JSON
+format. The consensus parameters are read from the genesis files of the data
+directory. The database must not be in use by a running node; for a running
+node, use the /graph?format=dot endpoint of the HTTP service instead.
+
+  kdag graph --from-round 10 --to-round 20 | dot -Tsvg > graph.svg`,
+		RunE: exportGraph,
+	}
+
+	AddGraphFlags(cmd)
+
+	return cmd
+}
+
+//AddGraphFlags adds flags to the graph command
+func AddGraphFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&graphDataDir, "datadir", _config.Kdag.DataDir, "Top-level directory for configuration and data")
+	cmd.Flags().StringVar(&graphDB, "db", _config.Kdag.DatabaseDir, "Database directory")
+	cmd.Flags().StringVar(&graphKey, "db-key", "", "File containing the encryption key of an encrypted database")
+	cmd.Flags().StringVar(&graphFormat, "format", "dot", "Output format: dot or json")
+	cmd.Flags().StringVarP(&graphFile, "file", "f", "", "File where the graph will be written (defaults to stdout)")
+	cmd.Flags().IntVar(&graphFromRound, "from-round", 0, "First round to export")
+	cmd.Flags().IntVar(&graphToRound, "to-round", -1, "Last round to export (-1 for the last round)")
+	cmd.Flags().Int64Var(&graphFromTime, "from-time", 0, "Only export events created after this time, in Unix seconds")
+	cmd.Flags().Int64Var(&graphToTime, "to-time", 0, "Only export events created before this time, in Unix seconds")
+}
+
+func exportGraph(cmd *cobra.Command, args []string) error {
+	if graphFormat != "dot" && graphFormat != "json" {
+		return stacktrace.NewError("Unknown format: %s", graphFormat)
+	}
+
+	if _, err := os.Stat(graphDB); err != nil {
+		return stacktrace.NewError("No database found under: %s", graphDB)
+	}
+
+	params, err := graphConsensusParams()
+	if err != nil {
+		return stacktrace.NewError("Reading consensus parameters: %s", err)
+	}
+
+	key, err := crypto.ReadOrCreateSymmetricKey(graphKey, false)
+	if err != nil {
+		return stacktrace.NewError("Reading key: %s", err)
+	}
+
+	logger := logrus.New()
+	logger.Level = logrus.WarnLevel
+
+	store, err := h.NewBadgerStore(_config.Kdag.CacheSize, _config.Kdag.CacheBytes, graphDB, true, false, key, logrus.NewEntry(logger))
+	if err != nil {
+		return stacktrace.NewError("Opening database: %s", err)
+	}
+	defer store.Close()
+
+	hg := h.NewHashgraph(store, h.DummyInternalCommitCallback, logrus.NewEntry(logger))
+
+	if err := hg.SetConsensusParams(params); err != nil {
+		return stacktrace.NewError("Setting consensus parameters: %s", err)
+	}
+
+	if err := hg.Bootstrap(); err != nil {
+		return stacktrace.NewError("Replaying events: %s", err)
+	}
+
+	dag, err := h.ExportDAG(store, h.DAGOptions{
+		FromRound: graphFromRound,
+		ToRound:   graphToRound,
+		FromTime:  graphFromTime,
+		ToTime:    graphToTime,
+	})
+	if err != nil {
+		return stacktrace.NewError("Exporting graph: %s", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if graphFile != "" {
+		f, err := os.Create(graphFile)
+		if err != nil {
+			return stacktrace.NewError("Creating file: %s", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if graphFormat == "dot" {
+		err = dag.WriteDOT(w)
+	} else {
+		err = json.NewEncoder(w).Encode(dag)
+	}
+	if err != nil {
+		return stacktrace.NewError("Writing graph: %s", err)
+	}
+
+	return nil
+}
+
+// graphConsensusParams reads the consensus parameters like a node started with
+// the same data directory would.
+func graphConsensusParams() (h.ConsensusParams, error) {
+	g, err := genesis.Read(filepath.Join(graphDataDir, config.DefaultGenesisFile))
+	if err == nil {
+		return g.ConsensusParams, nil
+	} else if !os.IsNotExist(err) {
+		return h.ConsensusParams{}, err
+	}
+
+	params, err := h.ReadConsensusParams(filepath.Join(graphDataDir, config.DefaultConsensusParamsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return params, err
+	}
+
+	return params, nil
+}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/palantir/stacktrace"
+	"github.com/spf13/cobra"
+
+	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/genesis"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+var (
+	initDataDir         string
+	initChainID         string
+	initGenesisTime     string
+	initAppStateHash    string
+	initCoinRoundFreq   int
+	initMaxVotingRounds int
+	initJoinAllowlist   string
+	initRequireInvite   bool
+	initMaxValidators   int
+)
+
+// NewInitCmd produces an InitCmd which creates the genesis file of a network
+func NewInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the genesis file of a network",
+		Long: `Create the genesis file of a network
+
+Writes a genesis.json file in the data directory. The initial validators are
+read from peers.genesis.json, or peers.json if there is no peers.genesis.json.
+The consensus parameters are read from consensus.genesis.json, if it exists,
+and can be overridden with flags. The join policy, which every validator applies to
+the peers that request to join, is defined with the --join-allowlist,
+--join-require-invite, and --max-validators flags. The same genesis.json file
+must then be copied to the data directory of every node of the network.`,
+		RunE: initGenesis,
+	}
+
+	AddInitFlags(cmd)
+
+	return cmd
+}
+
+//AddInitFlags adds flags to the init command
+func AddInitFlags(cmd *cobra.Command) {
+	defaults := h.DefaultConsensusParams()
+
+	cmd.Flags().StringVar(&initDataDir, "datadir", _config.Kdag.DataDir, "Top-level directory for configuration and data")
+	cmd.Flags().StringVar(&initChainID, "chain-id", "", "Unique name of the network (required)")
+	cmd.Flags().StringVar(&initGenesisTime, "genesis-time", "", "Creation time of the network, in RFC3339 format (defaults to now)")
+	cmd.Flags().StringVar(&initAppStateHash, "app-state-hash", "", "Hash of the initial state of the application, in 0X-prefixed hex")
+	cmd.Flags().IntVar(&initCoinRoundFreq, "coin-round-freq", defaults.CoinRoundFreq, "Frequency of coin rounds in fame voting")
+	cmd.Flags().IntVar(&initMaxVotingRounds, "max-voting-rounds", defaults.MaxVotingRounds, "Maximum number of fame voting rounds (0 means no limit)")
+	cmd.Flags().StringVar(&initJoinAllowlist, "join-allowlist", "", "File listing the public keys of the peers authorized to join, one per line")
+	cmd.Flags().BoolVar(&initRequireInvite, "join-require-invite", false, "Only accept joining peers with an invite from a validator")
+	cmd.Flags().IntVar(&initMaxValidators, "max-validators", 0, "Maximum number of validators (0 means no limit)")
+}
+
+func initGenesis(cmd *cobra.Command, args []string) error {
+	genesisFile := filepath.Join(initDataDir, config.DefaultGenesisFile)
+
+	if _, err := os.Stat(genesisFile); err == nil {
+		return stacktrace.NewError("A genesis file already exists: %s", genesisFile)
+	}
+
+	if initChainID == "" {
+		return stacktrace.NewError("--chain-id is required")
+	}
+
+	genesisTime := time.Now().Truncate(time.Second)
+	if initGenesisTime != "" {
+		t, err := time.Parse(time.RFC3339, initGenesisTime)
+		if err != nil {
+			return stacktrace.NewError("Parsing genesis time: %s", err)
+		}
+		genesisTime = t
+	}
+
+	validators, err := peers.NewJSONPeerSet(initDataDir, false).PeerSet()
+	if err != nil {
+		validators, err = peers.NewJSONPeerSet(initDataDir, true).PeerSet()
+	}
+	if err != nil {
+		return stacktrace.NewError("Reading validators: %s", err)
+	}
+	if validators == nil {
+		return stacktrace.NewError("No validators in %s", initDataDir)
+	}
+
+	g := genesis.NewGenesis(initChainID, genesisTime, validators.Peers)
+	g.AppStateHash = initAppStateHash
+
+	params, err := h.ReadConsensusParams(filepath.Join(initDataDir, config.DefaultConsensusParamsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return stacktrace.NewError("Reading consensus parameters: %s", err)
+	}
+	if cmd.Flags().Changed("coin-round-freq") {
+		params.CoinRoundFreq = initCoinRoundFreq
+	}
+	if cmd.Flags().Changed("max-voting-rounds") {
+		params.MaxVotingRounds = initMaxVotingRounds
+	}
+	g.ConsensusParams = params
+
+	if initJoinAllowlist != "" || initRequireInvite || initMaxValidators != 0 {
+		g.JoinPolicy = &genesis.JoinPolicy{
+			RequireInvite: initRequireInvite,
+			MaxValidators: initMaxValidators,
+		}
+		if initJoinAllowlist != "" {
+			allowlist, err := readAllowlist(initJoinAllowlist)
+			if err != nil {
+				return stacktrace.NewError("Reading join allowlist: %s", err)
+			}
+			g.JoinPolicy.Allowlist = allowlist
+		}
+	}
+
+	if err := g.Validate(); err != nil {
+		return stacktrace.NewError("Invalid genesis: %s", err)
+	}
+
+	if err := g.Write(genesisFile); err != nil {
+		return stacktrace.NewError("Writing genesis file: %s", err)
+	}
+
+	hash, err := g.HashString()
+	if err != nil {
+		return stacktrace.NewError("Hashing genesis: %s", err)
+	}
+
+	fmt.Printf("Genesis file for %s has been saved to: %s\n", g.ChainID, genesisFile)
+	fmt.Printf("Genesis hash: %s\n", hash)
+
+	return nil
+}
+
+// readAllowlist reads public keys from a file that contains one key per line.
+// Empty lines and lines starting with # are ignored.
+func readAllowlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pubKeys []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pubKeys = append(pubKeys, line)
+	}
+
+	return pubKeys, scanner.Err()
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/palantir/stacktrace"
+	"github.com/spf13/cobra"
+
+	_state "github.com/Kdag-K/kdag/src/node/state"
+)
+
+var leaveServiceAddr string
+
+// NewLeaveCmd produces a LeaveCmd which makes a running node leave the network.
+func NewLeaveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "leave",
+		Short: "Leave the network politely",
+		Long: `Leave the network politely
+
+Asks the running node to leave the network through its HTTP service. The node
+submits a request to be removed from the validator-set, and keeps gossiping
+until the removal takes effect. If it cannot get the request through consensus
+by itself, it submits it through the other peers. The command prints the
+states the node goes through (Leaving, LeaveAccepted, Left), and returns when
+the node has left and shut down. The node only accepts the request from its own
+host.`,
+		Args: cobra.NoArgs,
+		RunE: leave,
+	}
+
+	AddLeaveFlags(cmd)
+
+	return cmd
+}
+
+//AddLeaveFlags adds flags to the Leave command
+func AddLeaveFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&leaveServiceAddr, "service-listen", "s", _config.Kdag.ServiceAddr, "Listen IP:Port of the node's HTTP service")
+}
+
+func leave(cmd *cobra.Command, args []string) error {
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/leave", leaveServiceAddr),
+		"application/json",
+		nil)
+	if err != nil {
+		return stacktrace.NewError("Contacting node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return stacktrace.NewError("Leave failed: %s", strings.TrimSpace(string(msg)))
+	}
+
+	// The node shuts down as soon as it has left, so the stream may end
+	// abruptly after the Left state.
+	left := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var msg struct {
+			State string `json:"state"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg); err != nil {
+			return stacktrace.NewError("Decoding response: %s", err)
+		}
+
+		if msg.Error != "" {
+			return stacktrace.NewError("Leave failed: %s", msg.Error)
+		}
+
+		fmt.Println(msg.State)
+
+		if msg.State == _state.Left.String() {
+			left = true
+		}
+	}
+
+	if !left {
+		return stacktrace.NewError("Node stopped responding before leaving")
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/Kdag-K/kdag/src/kdag"
+	aproxy "github.com/Kdag-K/kdag/src/proxy/socket/app"
+)
+
+var (
+	replayFrom int
+	replayTo   int
+)
+
+//NewReplayCmd returns the command that replays stored blocks to the app
+func NewReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Replay stored blocks to the app",
+		Long: `Replay stored blocks to the app
+
+Commits the blocks of a stopped node's database to the app, in order, without
+gossiping. The state-hash returned by the app for every block is compared with
+the one recorded in the block, and the replay stops at the first mismatch. The
+app is expected to be in the state that results from committing block from-1.`,
+		PreRunE: bindFlagsLoadViper,
+		RunE:    runReplay,
+	}
+	AddReplayFlags(cmd)
+	return cmd
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	_config.Kdag.Logger().WithFields(logrus.Fields{
+		"ProxyAddr":  _config.ProxyAddr,
+		"ClientAddr": _config.ClientAddr,
+	}).Debug("Config Proxy")
+
+	p, err := aproxy.NewSocketAppProxy(
+		_config.ClientAddr,
+		_config.ProxyAddr,
+		_config.Kdag.HeartbeatTimeout,
+		_config.Kdag.Logger(),
+	)
+
+	if err != nil {
+		_config.Kdag.Logger().Error("Cannot initialize socket AppGateway:", err)
+		return err
+	}
+
+	_config.Kdag.Proxy = p
+
+	engine := kdag.NewKdag(&_config.Kdag)
+
+	report, err := engine.Replay(replayFrom, replayTo)
+	if err != nil {
+		_config.Kdag.Logger().Error("Cannot replay:", err)
+		return err
+	}
+
+	if report.Mismatch != nil {
+		return fmt.Errorf("Replayed blocks %d to %d, state diverged at %s",
+			report.From,
+			report.LastBlock,
+			report.Mismatch)
+	}
+
+	fmt.Printf("Replayed blocks %d to %d\n", report.From, report.LastBlock)
+
+	return nil
+}
+
+//AddReplayFlags adds flags to the Replay command
+func AddReplayFlags(cmd *cobra.Command) {
+	cmd.Flags().String("datadir", _config.Kdag.DataDir, "Top-level directory for configuration and data")
+	cmd.Flags().String("log", _config.Kdag.LogLevel, "debug, info, warn, error, fatal, panic")
+	cmd.Flags().String("db", _config.Kdag.DatabaseDir, "Dabatabase directory")
+	cmd.Flags().Int("cache-size", _config.Kdag.CacheSize, "Number of items in LRU caches")
+	cmd.Flags().Int64("cache-bytes", _config.Kdag.CacheBytes, "Max estimated bytes in each LRU cache (0 for no limit)")
+
+	// Proxy
+	cmd.Flags().StringP("proxy-listen", "p", _config.ProxyAddr, "Listen IP:Port for kdag proxy")
+	cmd.Flags().StringP("client-connect", "c", _config.ClientAddr, "IP:Port to connect to client")
+
+	// Range
+	cmd.Flags().IntVar(&replayFrom, "from", 0, "Index of the first block to replay")
+	cmd.Flags().IntVar(&replayTo, "to", -1, "Index of the last block to replay (-1 for all)")
+}
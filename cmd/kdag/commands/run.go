@@ -71,6 +71,7 @@ func AddRunFlags(cmd *cobra.Command) {
 	cmd.Flags().String("log", _config.Kdag.LogLevel, "debug, info, warn, error, fatal, panic")
 	cmd.Flags().String("moniker", _config.Kdag.Moniker, "Optional name")
 	cmd.Flags().BoolP("maintenance-mode", "R", _config.Kdag.MaintenanceMode, "Start Kdag in a suspended (non-gossipping) state")
+	cmd.Flags().Bool("observer", _config.Kdag.Observer, "Follow consensus without being a validator")
 
 	// Network
 	cmd.Flags().StringP("listen", "l", _config.Kdag.BindAddr, "Listen IP:Port for kdag node")
@@ -98,15 +99,28 @@ func AddRunFlags(cmd *cobra.Command) {
 	// Store
 	cmd.Flags().Bool("store", _config.Kdag.Store, "Use badgerDB instead of in-mem DB")
 	cmd.Flags().String("db", _config.Kdag.DatabaseDir, "Dabatabase directory")
+	cmd.Flags().Bool("store-indexes", _config.Kdag.StoreIndexes, "Index transactions and block timestamps in badgerDB")
+	cmd.Flags().Bool("encrypt-db", _config.Kdag.EncryptDB, "Encrypt badgerDB values, but not keys, with the key in the db_key file")
 	cmd.Flags().Bool("bootstrap", _config.Kdag.Bootstrap, "Load from database")
 	cmd.Flags().Int("cache-size", _config.Kdag.CacheSize, "Number of items in LRU caches")
+	cmd.Flags().Int64("cache-bytes", _config.Kdag.CacheBytes, "Max estimated bytes in each LRU cache (0 for no limit)")
 
 	// Node configuration
 	cmd.Flags().Duration("heartbeat", _config.Kdag.HeartbeatTimeout, "Timer frequency when there is something to gossip about")
 	cmd.Flags().Duration("slow-heartbeat", _config.Kdag.SlowHeartbeatTimeout, "Timer frequency when there is nothing to gossip about")
 	cmd.Flags().Int("sync-limit", _config.Kdag.SyncLimit, "Max number of events for sync")
 	cmd.Flags().Bool("fast-sync", _config.Kdag.EnableFastSync, "Enable FastSync")
+	cmd.Flags().Int("snapshot-chunk-size", _config.Kdag.SnapshotChunkSize, "Size in bytes of the chunks of the snapshots served to fast-syncing nodes")
 	cmd.Flags().Int("suspend-limit", _config.Kdag.SuspendLimit, "Limit of undetermined events before entering suspended state")
+	cmd.Flags().Int("trace-rounds", _config.Kdag.TraceRounds, "Number of recent rounds for which consensus decisions are traced (0 to disable)")
+
+	// Join policy
+	cmd.Flags().String("join-invite", _config.Kdag.JoinInvite, "Invite to present when joining")
+
+	// Peer discovery
+	cmd.Flags().String("seeds", _config.Kdag.Seeds, "Comma-separated list of pubkey@address peers to contact when joining")
+	cmd.Flags().String("resolver-service", _config.Kdag.ResolverService, "Service to look up in resolver.json to find peers")
+	cmd.Flags().Bool("signal-discovery", _config.Kdag.SignalDiscovery, "Find peers connected to the WebRTC signaling server")
 }
 
 // Bind all flags and read the config into viper
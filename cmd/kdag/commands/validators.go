@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/palantir/stacktrace"
+	"github.com/spf13/cobra"
+
+	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+var (
+	validatorsDataDir     string
+	validatorsServiceAddr string
+	inviteValidRounds     int
+)
+
+// NewValidatorsCmd produces a ValidatorsCmd which administers the
+// validator-set through a running node.
+func NewValidatorsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validators",
+		Short: "Administer the validator-set",
+	}
+
+	cmd.AddCommand(
+		newValidatorsRemoveCmd(),
+		newValidatorsInviteCmd(),
+		newValidatorsRotateCmd())
+
+	return cmd
+}
+
+func newValidatorsRemoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove [pubkey]",
+		Short: "Vote to remove a validator",
+		Long: `Vote to remove a validator
+
+Submits a PEER_REMOVE internal transaction, signed with the private key of the
+data directory, to the HTTP service of the node that owns this key. The
+validator is removed when validators holding a supermajority of the voting
+weight have voted to remove it, so the command must be run against enough
+nodes. This is the way to remove an unreachable validator, which cannot leave
+by itself. The vote is bound to the round from which the validator has held its
+place, read from the node's history, so it does not count after the validator
+rejoins or its weight changes.`,
+		Args: cobra.ExactArgs(1),
+		RunE: removeValidator,
+	}
+
+	AddValidatorsFlags(cmd)
+
+	return cmd
+}
+
+func newValidatorsInviteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "invite [pubkey]",
+		Short: "Invite a peer to join",
+		Long: `Invite a peer to join
+
+Creates an invite, signed with the private key of the data directory, that the
+peer with the given public key presents with --join-invite when it joins a
+network whose genesis requires invites (cf. kdag init --join-require-invite).
+The invite is only valid as long as the private key belongs to a validator, and
+it expires --valid-rounds rounds after the last consensus round of the node.`,
+		Args: cobra.ExactArgs(1),
+		RunE: invitePeer,
+	}
+
+	AddValidatorsFlags(cmd)
+	cmd.Flags().IntVar(&inviteValidRounds, "valid-rounds", 1000, "Number of rounds during which the invite can be used")
+
+	return cmd
+}
+
+func newValidatorsRotateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate the validator's key",
+		Long: `Rotate the validator's key
+
+Generates a new private key in the data directory (priv_key.next), unless one
+is already there, and asks the node that owns the current key to replace it
+with a PEER_ROTATE_KEY internal transaction signed by both keys. The validator
+keeps its place in the validator-set, and the node switches to the new key at
+the round returned by the command. The new key then replaces priv_key, which is
+kept as priv_key.old. The public key in peers.json must be updated before the
+node restarts from an empty database.`,
+		Args: cobra.NoArgs,
+		RunE: rotateValidatorKey,
+	}
+
+	AddValidatorsFlags(cmd)
+
+	return cmd
+}
+
+//AddValidatorsFlags adds flags to the validators commands
+func AddValidatorsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&validatorsDataDir, "datadir", _config.Kdag.DataDir, "Top-level directory for configuration and data")
+	cmd.Flags().StringVarP(&validatorsServiceAddr, "service-listen", "s", _config.Kdag.ServiceAddr, "Listen IP:Port of the node's HTTP service")
+}
+
+func removeValidator(cmd *cobra.Command, args []string) error {
+	pubKey := "0X" + strings.TrimPrefix(strings.ToUpper(args[0]), "0X")
+
+	privKey, err := readValidatorKey()
+	if err != nil {
+		return err
+	}
+
+	round, err := validatorRound(pubKey)
+	if err != nil {
+		return err
+	}
+
+	itx := h.NewInternalTransactionRemoval(
+		peers.Peer{PubKeyHex: pubKey},
+		keys.PublicKeyHex(&privKey.PublicKey),
+		round)
+
+	if err := itx.Sign(privKey); err != nil {
+		return stacktrace.NewError("Signing removal proposal: %s", err)
+	}
+
+	body, err := json.Marshal(itx)
+	if err != nil {
+		return stacktrace.NewError("Encoding removal proposal: %s", err)
+	}
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/validators/remove", validatorsServiceAddr),
+		"application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return stacktrace.NewError("Contacting node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return stacktrace.NewError("Removal proposal failed: %s", strings.TrimSpace(string(msg)))
+	}
+
+	var res struct {
+		Removed bool `json:"removed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return stacktrace.NewError("Decoding response: %s", err)
+	}
+
+	if res.Removed {
+		fmt.Printf("Validator %s has been removed\n", pubKey)
+	} else {
+		fmt.Printf("Vote to remove %s recorded, waiting for other validators\n", pubKey)
+	}
+
+	return nil
+}
+
+// validatorRound fetches the history of validator-sets from the node, and
+// returns the round from which the given validator has held its place.
+func validatorRound(pubKey string) (int, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s/history", validatorsServiceAddr))
+	if err != nil {
+		return 0, stacktrace.NewError("Contacting node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var history map[int][]*peers.Peer
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		return 0, stacktrace.NewError("Decoding validator history: %s", err)
+	}
+
+	round, ok := h.ValidatorRound(history, pubKey)
+	if !ok {
+		return 0, stacktrace.NewError("%s is not a validator", pubKey)
+	}
+
+	return round, nil
+}
+
+func invitePeer(cmd *cobra.Command, args []string) error {
+	privKey, err := readValidatorKey()
+	if err != nil {
+		return err
+	}
+
+	round, err := lastConsensusRound()
+	if err != nil {
+		return err
+	}
+
+	invite, err := h.NewJoinInvite(privKey, args[0], round+inviteValidRounds)
+	if err != nil {
+		return stacktrace.NewError("Creating invite: %s", err)
+	}
+
+	fmt.Println(invite)
+
+	return nil
+}
+
+// lastConsensusRound fetches the last consensus round from the node's stats.
+func lastConsensusRound() (int, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s/stats", validatorsServiceAddr))
+	if err != nil {
+		return 0, stacktrace.NewError("Contacting node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var stats map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, stacktrace.NewError("Decoding stats: %s", err)
+	}
+
+	round, err := strconv.Atoi(stats["last_consensus_round"])
+	if err != nil {
+		return 0, stacktrace.NewError("Reading last consensus round: %s", err)
+	}
+
+	return round, nil
+}
+
+func rotateValidatorKey(cmd *cobra.Command, args []string) error {
+	keyfile := filepath.Join(validatorsDataDir, config.DefaultKeyfile)
+	nextKeyfile := filepath.Join(validatorsDataDir, config.DefaultNextKeyfile)
+
+	// Reuse the next key of an unfinished rotation
+	nextKey, err := keys.NewSimpleKeyfile(nextKeyfile).ReadKey()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return stacktrace.NewError("Reading next key: %s", err)
+		}
+
+		nextKey, err = keys.GenerateECDSAKey()
+		if err != nil {
+			return stacktrace.NewError("Generating next key: %s", err)
+		}
+
+		if err := keys.NewSimpleKeyfile(nextKeyfile).WriteKey(nextKey); err != nil {
+			return stacktrace.NewError("Writing next key: %s", err)
+		}
+	}
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/validators/rotate", validatorsServiceAddr),
+		"application/json",
+		nil)
+	if err != nil {
+		return stacktrace.NewError("Contacting node: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return stacktrace.NewError("Key rotation failed: %s", strings.TrimSpace(string(msg)))
+	}
+
+	var res struct {
+		EffectiveRound int `json:"effective_round"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return stacktrace.NewError("Decoding response: %s", err)
+	}
+
+	if err := os.Rename(keyfile, keyfile+".old"); err != nil {
+		return stacktrace.NewError("Backing up old key: %s", err)
+	}
+
+	if err := os.Rename(nextKeyfile, keyfile); err != nil {
+		return stacktrace.NewError("Replacing key: %s", err)
+	}
+
+	fmt.Printf("Validator key rotated to %s, effective from round %d\n",
+		keys.PublicKeyHex(&nextKey.PublicKey),
+		res.EffectiveRound)
+
+	return nil
+}
+
+func readValidatorKey() (*ecdsa.PrivateKey, error) {
+	privKey, err := keys.NewSimpleKeyfile(filepath.Join(validatorsDataDir, config.DefaultKeyfile)).ReadKey()
+	if err != nil {
+		return nil, stacktrace.NewError("Reading private key: %s", err)
+	}
+	return privKey, nil
+}
@@ -13,7 +13,15 @@ func main() {
 	rootCmd.AddCommand(
 		cmd.VersionCmd,
 		cmd.NewKeygenCmd(),
-		cmd.NewRunCmd())
+		cmd.NewInitCmd(),
+		cmd.NewRunCmd(),
+		cmd.NewExportCmd(),
+		cmd.NewImportCmd(),
+		cmd.NewReplayCmd(),
+		cmd.NewGraphCmd(),
+		cmd.NewValidatorsCmd(),
+		cmd.NewLeaveCmd(),
+		cmd.NewDBCmd())
 
 	//Do not print usage when error occurs
 	rootCmd.SilenceUsage = true
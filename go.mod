@@ -21,7 +21,8 @@ require (
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/viper v1.8.0
 	github.com/stretchr/testify v1.7.0
-	github.com/ugorji/go/codec v1.2.6
+	github.com/ugorji/go v1.2.6 // indirect
+	github.com/ugorji/go/codec v1.2.14
 	github.com/x-cray/logrus-prefixed-formatter v0.5.2
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 )
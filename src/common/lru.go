@@ -11,27 +11,61 @@ import (
 // EvictCallback is used to get a callback when a cache entry is evicted.
 type EvictCallback func(key interface{}, value interface{})
 
-// LRU implements a non-thread safe fixed size LRU cache.
+// CostFunc returns the approximate memory footprint, in bytes, of a cache
+// value.
+type CostFunc func(value interface{}) int64
+
+// LRUStats contains the usage statistics of an LRU cache.
+type LRUStats struct {
+	Items     int
+	Bytes     int64
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// LRU implements a non-thread safe fixed size LRU cache. The cache is bounded
+// by a number of items and, optionally, by a total cost in bytes.
 type LRU struct {
 	size      int
+	maxBytes  int64
+	bytes     int64
+	costFn    CostFunc
 	evictList *list.List
 	items     map[interface{}]*list.Element
 	onEvict   EvictCallback
+	hits      uint64
+	misses    uint64
+	evictions uint64
 }
 
 // entry is used to hold a value in the evictList.
 type entry struct {
 	key   interface{}
 	value interface{}
+	cost  int64
 }
 
 // NewLRU constructs an LRU of the given size.
 func NewLRU(size int, onEvict EvictCallback) *LRU {
+	return NewSizedLRU(size, 0, nil, onEvict)
+}
+
+// NewSizedLRU constructs an LRU of the given size, which also evicts the oldest
+// items when the sum of their costs, as computed by costFn, exceeds maxBytes.
+// The most recently added item is never evicted to honour the byte budget. A
+// maxBytes of zero, or a nil costFn, disables the byte budget.
+func NewSizedLRU(size int, maxBytes int64, costFn CostFunc, onEvict EvictCallback) *LRU {
 	if size <= 0 {
 		panic(stacktrace.NewError("Must provide a positive size"))
 	}
+	if costFn == nil {
+		maxBytes = 0
+	}
 	c := &LRU{
 		size:      size,
+		maxBytes:  maxBytes,
+		costFn:    costFn,
 		evictList: list.New(),
 		items:     make(map[interface{}]*list.Element),
 		onEvict:   onEvict,
@@ -51,22 +85,32 @@ func (c *LRU) Purge() {
 	}
 
 	c.evictList.Init()
+	c.bytes = 0
 }
 
 // Add adds a value to the cache.  Returns true if an eviction occurred.
 func (c *LRU) Add(key, value interface{}) bool {
+	var cost int64
+	if c.costFn != nil {
+		cost = c.costFn(value)
+	}
+
 	// Check for existing item
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
-		ent.Value.(*entry).value = value
+		kv := ent.Value.(*entry)
+		c.bytes += cost - kv.cost
+		kv.value = value
+		kv.cost = cost
 
-		return false
+		return c.evictOverBudget()
 	}
 
 	// Add new item.
-	ent := &entry{key, value}
+	ent := &entry{key, value, cost}
 	entry := c.evictList.PushFront(ent)
 	c.items[key] = entry
+	c.bytes += cost
 
 	evict := c.evictList.Len() > c.size
 	// Verify size not exceeded.
@@ -74,6 +118,19 @@ func (c *LRU) Add(key, value interface{}) bool {
 		c.removeOldest()
 	}
 
+	return c.evictOverBudget() || evict
+}
+
+// evictOverBudget removes the oldest items until the total cost is within the
+// byte budget, keeping at least the most recent item. It returns true if an
+// eviction occurred.
+func (c *LRU) evictOverBudget() bool {
+	evict := false
+	for c.maxBytes > 0 && c.bytes > c.maxBytes && c.evictList.Len() > 1 {
+		c.removeOldest()
+		evict = true
+	}
+
 	return evict
 }
 
@@ -82,12 +139,15 @@ func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
 		if ent.Value.(*entry) == nil {
+			c.misses++
 			return nil, false
 		}
 
+		c.hits++
 		return ent.Value.(*entry).value, true
 	}
 
+	c.misses++
 	return
 }
 
@@ -161,6 +221,23 @@ func (c *LRU) Len() int {
 	return c.evictList.Len()
 }
 
+// Bytes returns the total cost of the items in the cache.
+func (c *LRU) Bytes() int64 {
+	return c.bytes
+}
+
+// Stats returns the usage statistics of the cache. Hits and misses are only
+// counted by Get.
+func (c *LRU) Stats() LRUStats {
+	return LRUStats{
+		Items:     c.Len(),
+		Bytes:     c.bytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
 // Resize changes the cache size.
 func (c *LRU) Resize(size int) int {
 	diff := c.Len() - size
@@ -179,6 +256,7 @@ func (c *LRU) Resize(size int) int {
 func (c *LRU) removeOldest() {
 	if ent := c.evictList.Back(); ent != nil {
 		c.removeElement(ent)
+		c.evictions++
 	}
 }
 
@@ -187,6 +265,7 @@ func (c *LRU) removeElement(e *list.Element) {
 	c.evictList.Remove(e)
 	if kv, ok := e.Value.(*entry); ok {
 		delete(c.items, kv.key)
+		c.bytes -= kv.cost
 		if c.onEvict != nil {
 			c.onEvict(kv.key, kv.value)
 		}
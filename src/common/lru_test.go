@@ -150,4 +150,47 @@ func TestLRU_Peek(t *testing.T) {
 	if l.Contains(1) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
-}
\ No newline at end of file
+}
+
+// Test that the byte budget evicts the oldest items, and that the statistics
+// are maintained
+func TestLRU_Bytes(t *testing.T) {
+	cost := func(v interface{}) int64 {
+		return int64(len(v.(string)))
+	}
+
+	l := NewSizedLRU(10, 10, cost, nil)
+
+	l.Add(1, "aaaa")
+	l.Add(2, "bbbb")
+	if l.Bytes() != 8 {
+		t.Fatalf("bytes should be 8, not %d", l.Bytes())
+	}
+
+	if !l.Add(3, "cccc") {
+		t.Errorf("should have an eviction")
+	}
+	if l.Contains(1) || l.Bytes() != 8 {
+		t.Errorf("1 should have been evicted to stay within budget")
+	}
+
+	// replacing a value updates its cost
+	l.Add(3, "cc")
+	if l.Bytes() != 6 {
+		t.Errorf("bytes should be 6, not %d", l.Bytes())
+	}
+
+	// an item larger than the budget is kept on its own
+	l.Add(4, "dddddddddddd")
+	if l.Len() != 1 || !l.Contains(4) {
+		t.Errorf("only 4 should remain")
+	}
+
+	l.Get(4)
+	l.Get(1)
+
+	stats := l.Stats()
+	if stats.Items != 1 || stats.Bytes != 12 || stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 3 {
+		t.Errorf("wrong stats: %+v", stats)
+	}
+}
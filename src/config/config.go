@@ -14,6 +14,8 @@ import (
 	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 
 	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/genesis"
+	"github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/proxy"
 )
 
@@ -23,12 +25,36 @@ const (
 	// private key
 	DefaultKeyfile = "priv_key"
 
+	// DefaultNextKeyfile is the default name of the file containing the
+	// private key that replaces the validator's key in a key rotation
+	DefaultNextKeyfile = "priv_key.next"
+
+	// DefaultDBKeyfile is the default name of the file containing the key that
+	// encrypts the Badger database
+	DefaultDBKeyfile = "db_key"
+
 	// DefaultBadgerFile is the default name of the folder containing the Badger
 	// database
 	DefaultBadgerFile = "badger_db"
 	// DefaultCertFile is the default name of the file containing the TLS
 	// certificate for connecting to the signaling server.
 	DefaultCertFile = "cert.pem"
+
+	// DefaultConsensusParamsFile is the default name of the file containing the
+	// consensus parameters of the network
+	DefaultConsensusParamsFile = "consensus.genesis.json"
+
+	// DefaultGenesisFile is the default name of the file containing the
+	// genesis of the network
+	DefaultGenesisFile = "genesis.json"
+
+	// DefaultResolverFile is the default name of the file containing the
+	// SRV-like records used to discover peers
+	DefaultResolverFile = "resolver.json"
+
+	// DefaultSnapshotsDir is the default name of the folder containing the
+	// chunks of the snapshots exchanged during fast-sync
+	DefaultSnapshotsDir = "snapshots"
 )
 
 // Default configuration values.
@@ -41,11 +67,21 @@ const (
 	DefaultTCPTimeout           = 1000 * time.Millisecond
 	DefaultJoinTimeout          = 10000 * time.Millisecond
 	DefaultCacheSize            = 10000
+	DefaultCacheBytes           = 0
 	DefaultSyncLimit            = 1000
+	DefaultSnapshotChunkSize    = 1 << 20
 	DefaultMaxPool              = 2
 	DefaultStore                = false
+	DefaultStoreIndexes         = false
+	DefaultEncryptDB            = false
 	DefaultMaintenanceMode      = false
 	DefaultSuspendLimit         = 100
+	DefaultTraceRounds          = 0
+	DefaultJoinInvite           = ""
+	DefaultSeeds                = ""
+	DefaultResolverService      = ""
+	DefaultSignalDiscovery      = false
+	DefaultObserver             = false
 	DefaultWebRTC               = false
 	DefaultSignalAddr           = "127.0.0.1:2443"
 	DefaultSignalRealm          = "main"
@@ -107,6 +143,25 @@ type Config struct {
 	// JoinTimeout is the timeout of Join Requests
 	JoinTimeout time.Duration `mapstructure:"join_timeout"`
 
+	// JoinInvite is the invite that this node presents when it joins a
+	// network whose validators require one.
+	JoinInvite string `mapstructure:"join-invite"`
+
+	// Seeds is a comma-separated list of peers, in the pubkey@address format,
+	// that the node contacts to join or fast-forward, in addition to the peers
+	// from peers.json.
+	Seeds string `mapstructure:"seeds"`
+
+	// ResolverService is the name of a service whose peers are looked up in
+	// the resolver.json file in DataDir, to join or fast-forward. Empty
+	// disables the lookup.
+	ResolverService string `mapstructure:"resolver-service"`
+
+	// SignalDiscovery makes the node contact the peers connected to the WebRTC
+	// signaling server to join or fast-forward. It is ignored when WebRTC is
+	// not enabled.
+	SignalDiscovery bool `mapstructure:"signal-discovery"`
+
 	// SyncLimit defines the max number of hashgraph events to include in a
 	// SyncResponse or EagerSyncRequest
 	SyncLimit int `mapstructure:"sync-limit"`
@@ -114,15 +169,37 @@ type Config struct {
 	// EnableFastSync enables the FastSync protocol.
 	EnableFastSync bool `mapstructure:"fast-sync"`
 
+	// SnapshotChunkSize is the size, in bytes, of the chunks that the
+	// snapshots served to fast-syncing nodes are split into.
+	SnapshotChunkSize int `mapstructure:"snapshot-chunk-size"`
+
 	// Store activates persistent storage.
 	Store bool `mapstructure:"store"`
 
+	// StoreIndexes activates the secondary indexes of the persistent store,
+	// which map transaction hashes to blocks and block timestamps to block
+	// indexes. It is ignored when Store is not set.
+	StoreIndexes bool `mapstructure:"store-indexes"`
+
+	// EncryptDB activates the encryption at rest of the persistent store. The
+	// key is read from the db_key file in DataDir, and created along with a
+	// new database if it does not exist. It is ignored when Store is not set.
+	// Only the values are encrypted; the database keys, which contain Event
+	// and transaction hashes, public keys, round and block indexes, and block
+	// timestamps, are stored in plaintext.
+	EncryptDB bool `mapstructure:"encrypt-db"`
+
 	// DatabaseDir is the directory containing database files.
 	DatabaseDir string `mapstructure:"db"`
 
 	// CacheSize is the max number of items in in-memory caches.
 	CacheSize int `mapstructure:"cache-size"`
 
+	// CacheBytes is the max estimated memory footprint, in bytes, of each of
+	// the event, round, block and frame caches. Zero means that the caches
+	// are only limited by CacheSize.
+	CacheBytes int64 `mapstructure:"cache-bytes"`
+
 	// Bootstrap determines whether or not to load Kdag from an existing
 	// database file. Forces Store, ie. bootstrap only works with a persistent
 	// database store.
@@ -141,6 +218,18 @@ type Config struct {
 	// node will suspend itself after registering 400 undetermined events.
 	SuspendLimit int `mapstructure:"suspend-limit"`
 
+	// TraceRounds is the number of recent rounds for which the node records
+	// how fame and round-received were decided, cf. hashgraph.RoundTrace. The
+	// traces are served by the /debug/rounds/ endpoint, and dumped to a file in
+	// DataDir when the node suspends itself. 0 disables tracing.
+	TraceRounds int `mapstructure:"trace-rounds"`
+
+	// Observer runs the node as an observer, which follows consensus and
+	// commits blocks to the application, without being a validator. It pulls
+	// Events from the validators, but never creates Events, signs Blocks, or
+	// joins the PeerSet. Transactions submitted to an observer are dropped.
+	Observer bool `mapstructure:"observer"`
+
 	// Moniker defines the friendly name of this node
 	Moniker string `mapstructure:"moniker"`
 
@@ -193,6 +282,17 @@ type Config struct {
 	// Key is the private key of the validator.
 	Key *ecdsa.PrivateKey
 
+	// ConsensusParams are the fame-voting parameters of the network. They must
+	// be the same for all the nodes. If nil, they are read from the
+	// consensus.genesis.json file in DataDir, or set to the defaults if there
+	// is no such file. They are ignored when Genesis is set.
+	ConsensusParams *hashgraph.ConsensusParams
+
+	// Genesis defines the network. If nil, it is read from the genesis.json
+	// file in DataDir, if there is one. Without a Genesis, the node can only
+	// communicate with other nodes that do not have a Genesis either.
+	Genesis *genesis.Genesis
+
 	logger *logrus.Logger
 }
 
@@ -211,12 +311,22 @@ func NewDefaultConf() *Config {
 		TCPTimeout:           DefaultTCPTimeout,
 		JoinTimeout:          DefaultJoinTimeout,
 		CacheSize:            DefaultCacheSize,
+		CacheBytes:           DefaultCacheBytes,
 		SyncLimit:            DefaultSyncLimit,
+		SnapshotChunkSize:    DefaultSnapshotChunkSize,
 		MaxPool:              DefaultMaxPool,
 		Store:                DefaultStore,
+		StoreIndexes:         DefaultStoreIndexes,
+		EncryptDB:            DefaultEncryptDB,
 		MaintenanceMode:      DefaultMaintenanceMode,
 		DatabaseDir:          DefaultDatabaseDir(),
 		SuspendLimit:         DefaultSuspendLimit,
+		TraceRounds:          DefaultTraceRounds,
+		JoinInvite:           DefaultJoinInvite,
+		Seeds:                DefaultSeeds,
+		ResolverService:      DefaultResolverService,
+		SignalDiscovery:      DefaultSignalDiscovery,
+		Observer:             DefaultObserver,
 		WebRTC:               DefaultWebRTC,
 		SignalAddr:           DefaultSignalAddr,
 		SignalRealm:          DefaultSignalRealm,
@@ -253,12 +363,47 @@ func (c *Config) Keyfile() string {
 	return filepath.Join(c.DataDir, DefaultKeyfile)
 }
 
+// NextKeyfile returns the full path of the file containing the private key
+// that replaces the current key in a key rotation.
+func (c *Config) NextKeyfile() string {
+	return filepath.Join(c.DataDir, DefaultNextKeyfile)
+}
+
+// DBKeyfile returns the full path of the file containing the database
+// encryption key.
+func (c *Config) DBKeyfile() string {
+	return filepath.Join(c.DataDir, DefaultDBKeyfile)
+}
+
 // CertFile returns the full path of the file containing the signal-server TLS
 // certificate.
 func (c *Config) CertFile() string {
 	return filepath.Join(c.DataDir, DefaultCertFile)
 }
 
+// ConsensusParamsFile returns the full path of the file containing the
+// consensus parameters.
+func (c *Config) ConsensusParamsFile() string {
+	return filepath.Join(c.DataDir, DefaultConsensusParamsFile)
+}
+
+// GenesisFile returns the full path of the genesis file.
+func (c *Config) GenesisFile() string {
+	return filepath.Join(c.DataDir, DefaultGenesisFile)
+}
+
+// ResolverFile returns the full path of the file containing the SRV-like records
+// used to discover peers.
+func (c *Config) ResolverFile() string {
+	return filepath.Join(c.DataDir, DefaultResolverFile)
+}
+
+// SnapshotsDir returns the full path of the folder containing the chunks of the
+// snapshots served to other nodes, and of the snapshots being fetched.
+func (c *Config) SnapshotsDir() string {
+	return filepath.Join(c.DataDir, DefaultSnapshotsDir)
+}
+
 // ICEServers returns a list of ICE servers used by the WebRTCStreamLayer to
 // connect to peers. The list contains a single item which is based on the
 // configuration passed through the config object. This configuration is limited
@@ -9,6 +9,8 @@
 //
 //  priv_key // a plain text file containing the raw private key (cf. kdag keygen).
 //  peers.json // a JSON file containing the current list of peers.
+//  genesis.json // (optional) a JSON file defining the network, which takes precedence over peers.genesis.json and consensus.genesis.json, cf. the genesis package.
 //  peers.genesis.json // (optional, defaults to peers.json) a JSON file containing the initial list of peers.
+//  consensus.genesis.json // (optional) a JSON file containing the consensus parameters, cf. hashgraph.ConsensusParams.
 //  cert.pem // (optional) an x509 certificate for the WebRTC signaling server.
 package config
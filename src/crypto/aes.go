@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// SymmetricKeySize is the size, in bytes, of the AES-256 keys used to encrypt
+// data at rest.
+const SymmetricKeySize = 32
+
+// GenerateSymmetricKey returns a new random AES-256 key.
+func GenerateSymmetricKey() ([]byte, error) {
+	key := make([]byte, SymmetricKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Seal encrypts and authenticates data with AES-GCM. The random nonce is
+// prepended to the returned ciphertext.
+func Seal(key []byte, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// Open decrypts and authenticates a ciphertext produced by Seal.
+func Open(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("Ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadSymmetricKey reads a key from a file containing its raw hex dump, as
+// produced by WriteSymmetricKey.
+func ReadSymmetricKey(keyfile string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != SymmetricKeySize {
+		return nil, fmt.Errorf("Key in %s should be %d bytes long, not %d",
+			keyfile,
+			SymmetricKeySize,
+			len(key))
+	}
+
+	return key, nil
+}
+
+// ReadOrCreateSymmetricKey reads a key from keyfile, and generates it if create
+// is true and the file does not exist. It returns nil if keyfile is empty.
+func ReadOrCreateSymmetricKey(keyfile string, create bool) ([]byte, error) {
+	if keyfile == "" {
+		return nil, nil
+	}
+
+	key, err := ReadSymmetricKey(keyfile)
+	if err == nil || !os.IsNotExist(err) || !create {
+		return key, err
+	}
+
+	key, err = GenerateSymmetricKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return key, WriteSymmetricKey(keyfile, key)
+}
+
+// WriteSymmetricKey writes a raw hex dump of the key to a file that is only
+// readable by its owner.
+func WriteSymmetricKey(keyfile string, key []byte) error {
+	if err := os.MkdirAll(path.Dir(keyfile), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(keyfile, []byte(hex.EncodeToString(key)), 0600)
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/sirupsen/logrus"
+)
+
+// Source defines an interface for systems that provide candidate peers.
+type Source interface {
+	// Name identifies the source in logs
+	Name() string
+
+	// Peers returns the peers currently known to the source
+	Peers() ([]*peers.Peer, error)
+}
+
+// Discovery aggregates the peers of multiple Sources.
+type Discovery struct {
+	sources []Source
+	logger  *logrus.Entry
+}
+
+// NewDiscovery creates a Discovery from a list of Sources. Sources listed first
+// take precedence when multiple Sources return the same peer.
+func NewDiscovery(logger *logrus.Entry, sources ...Source) *Discovery {
+	return &Discovery{
+		sources: sources,
+		logger:  logger,
+	}
+}
+
+// AddSource appends a Source to the Discovery.
+func (d *Discovery) AddSource(source Source) {
+	d.sources = append(d.sources, source)
+}
+
+// Len returns the number of Sources.
+func (d *Discovery) Len() int {
+	return len(d.sources)
+}
+
+// Peers queries all the Sources and returns the peers they know of, without
+// duplicate public keys. A Source that returns an error is skipped, so that
+// the other Sources can still be used.
+func (d *Discovery) Peers() []*peers.Peer {
+	res := []*peers.Peer{}
+	seen := make(map[string]bool)
+
+	for _, s := range d.sources {
+		ps, err := s.Peers()
+		if err != nil {
+			d.logger.WithError(err).WithField("source", s.Name()).Warn("Discovering peers")
+			continue
+		}
+
+		for _, p := range ps {
+			if seen[p.PubKeyString()] {
+				continue
+			}
+			seen[p.PubKeyString()] = true
+			res = append(res, p)
+		}
+
+		d.logger.WithFields(logrus.Fields{
+			"source": s.Name(),
+			"peers":  len(ps),
+		}).Debug("Discovered peers")
+	}
+
+	return res
+}
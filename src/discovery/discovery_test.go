@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+type failingSource struct{}
+
+func (s failingSource) Name() string {
+	return "failing"
+}
+
+func (s failingSource) Peers() ([]*peers.Peer, error) {
+	return nil, fmt.Errorf("unreachable")
+}
+
+type testPresence struct {
+	id    string
+	peers []string
+}
+
+func (p testPresence) ID() string {
+	return p.id
+}
+
+func (p testPresence) Presence() ([]string, error) {
+	return p.peers, nil
+}
+
+func newTestPubKeys(t *testing.T, n int) []string {
+	res := []string{}
+	for i := 0; i < n; i++ {
+		key, err := keys.GenerateECDSAKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, keys.PublicKeyHex(&key.PublicKey))
+	}
+	return res
+}
+
+func TestParseSeeds(t *testing.T) {
+	pubKeys := newTestPubKeys(t, 2)
+
+	seeds := fmt.Sprintf(" %s@10.0.0.1:1337, ,0x%s", pubKeys[0], pubKeys[1][2:])
+
+	ps, err := ParseSeeds(seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ps) != 2 {
+		t.Fatalf("Expected 2 seeds, not %d", len(ps))
+	}
+
+	if ps[0].PubKeyHex != pubKeys[0] || ps[0].NetAddr != "10.0.0.1:1337" {
+		t.Fatalf("Wrong first seed: %v", ps[0])
+	}
+
+	// Without an address, the public key is the address, like with WebRTC
+	if ps[1].PubKeyHex != pubKeys[1] || ps[1].NetAddr != pubKeys[1] {
+		t.Fatalf("Wrong second seed: %v", ps[1])
+	}
+
+	if _, err := ParseSeeds("@10.0.0.1:1337"); err == nil {
+		t.Fatal("A seed without public key should be invalid")
+	}
+}
+
+func TestResolverSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kdag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pubKeys := newTestPubKeys(t, 3)
+
+	records := fmt.Sprintf(`{
+	"_kdag._tcp.test": [
+		{"priority": 20, "weight": 0, "target": "10.0.0.1", "port": 1337, "pubkey": "%s"},
+		{"priority": 10, "weight": 1, "target": "10.0.0.2", "port": 1337, "pubkey": "%s"},
+		{"priority": 10, "weight": 5, "target": "10.0.0.3", "port": 1338, "pubkey": "%s", "moniker": "three"},
+		{"priority": 0, "weight": 0, "target": "10.0.0.4", "port": 1337}
+	]
+}`, pubKeys[0], pubKeys[1], pubKeys[2])
+
+	path := filepath.Join(dir, "resolver.json")
+	if err := ioutil.WriteFile(path, []byte(records), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewResolverSource(NewLocalResolver(path), "_kdag._tcp.test")
+
+	ps, err := source.Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*peers.Peer{
+		peers.NewPeer(pubKeys[2], "10.0.0.3:1338", "three"),
+		peers.NewPeer(pubKeys[1], "10.0.0.2:1337", ""),
+		peers.NewPeer(pubKeys[0], "10.0.0.1:1337", ""),
+	}
+
+	if len(ps) != len(expected) {
+		t.Fatalf("Expected %d peers, not %d", len(expected), len(ps))
+	}
+
+	for i, p := range ps {
+		if *p != *expected[i] {
+			t.Fatalf("Peer %d should be %v, not %v", i, expected[i], p)
+		}
+	}
+
+	if _, err := NewResolverSource(NewLocalResolver(path), "_other._tcp.test").Peers(); err == nil {
+		t.Fatal("Resolving an unknown service should fail")
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	pubKeys := newTestPubKeys(t, 3)
+
+	static := NewStaticSource([]*peers.Peer{
+		peers.NewPeer(pubKeys[0], pubKeys[0], "zero"),
+	})
+
+	signal := NewSignalSource(testPresence{
+		id:    pubKeys[2],
+		peers: pubKeys,
+	})
+
+	d := NewDiscovery(common.NewTestEntry(t, common.TestLogLevel), static, failingSource{}, signal)
+
+	ps := d.Peers()
+
+	if len(ps) != 2 {
+		t.Fatalf("Expected 2 peers, not %d", len(ps))
+	}
+
+	// The first source takes precedence, and the signal excludes itself
+	if ps[0].Moniker != "zero" || ps[1].PubKeyHex != pubKeys[1] || ps[1].NetAddr != pubKeys[1] {
+		t.Fatalf("Wrong peers: %v, %v", ps[0], ps[1])
+	}
+}
@@ -0,0 +1,17 @@
+// Package discovery finds peers to contact when a node joins or fast-forwards.
+//
+// Upon starting up, a node only knows the peers listed in its peers.json file.
+// When all of them have left the network, the node has no way of reaching the
+// current validator-set. The discovery package gathers candidate peers from a
+// list of pluggable sources:
+//
+//   - StaticSource: a fixed list of seeds, typically from the --seeds option.
+//   - ResolverSource: SRV-like records looked up in a Resolver. LocalResolver
+//     reads them from a JSON file in the data directory.
+//   - SignalSource: the peers currently connected to the WebRTC signaling
+//     server.
+//
+// The node also rewrites its peers.json file every time accepted
+// InternalTransactions change the validator-set, so that a restarted node
+// starts with the latest known peers.
+package discovery
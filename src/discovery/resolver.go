@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// Record locates a peer of a service, like a DNS SRV record. It also carries
+// the peer's public key, which DNS would publish in a TXT record.
+type Record struct {
+	// Priority orders the records; lower values are contacted first.
+	Priority uint16 `json:"priority"`
+
+	// Weight orders the records with the same Priority; higher values are
+	// contacted first.
+	Weight uint16 `json:"weight"`
+
+	// Target is the host name or IP address of the peer.
+	Target string `json:"target"`
+
+	// Port is the gossip port of the peer.
+	Port uint16 `json:"port"`
+
+	// PubKey is the public key of the peer.
+	PubKey string `json:"pubkey"`
+
+	// Moniker is the optional friendly name of the peer.
+	Moniker string `json:"moniker,omitempty"`
+}
+
+// Resolver defines an interface for systems that resolve a service name into
+// Records.
+type Resolver interface {
+	Resolve(service string) ([]Record, error)
+}
+
+// LocalResolver is a Resolver backed by a JSON file which maps service names to
+// lists of Records. The file is read on every lookup, so it can be edited while
+// the node is running.
+type LocalResolver struct {
+	path string
+}
+
+// NewLocalResolver creates a LocalResolver that reads Records from the file at
+// path.
+func NewLocalResolver(path string) *LocalResolver {
+	return &LocalResolver{
+		path: path,
+	}
+}
+
+// Resolve implements the Resolver interface.
+func (r *LocalResolver) Resolve(service string) ([]Record, error) {
+	buf, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string][]Record)
+	if err := json.Unmarshal(buf, &services); err != nil {
+		return nil, fmt.Errorf("Parsing %s: %v", r.path, err)
+	}
+
+	records, ok := services[service]
+	if !ok {
+		return nil, fmt.Errorf("Service %s not found in %s", service, r.path)
+	}
+
+	return records, nil
+}
+
+// ResolverSource is a Source that looks up the peers of a service in a
+// Resolver.
+type ResolverSource struct {
+	resolver Resolver
+	service  string
+}
+
+// NewResolverSource creates a ResolverSource that looks up service in resolver.
+func NewResolverSource(resolver Resolver, service string) *ResolverSource {
+	return &ResolverSource{
+		resolver: resolver,
+		service:  service,
+	}
+}
+
+// Name implements the Source interface.
+func (s *ResolverSource) Name() string {
+	return fmt.Sprintf("resolver:%s", s.service)
+}
+
+// Peers implements the Source interface. Peers are sorted by increasing
+// priority, then by decreasing weight. Records without a public key are
+// skipped because the node cannot authenticate them.
+func (s *ResolverSource) Peers() ([]*peers.Peer, error) {
+	records, err := s.resolver.Resolve(s.service)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+
+	res := []*peers.Peer{}
+	for _, r := range records {
+		if r.PubKey == "" || r.Target == "" {
+			continue
+		}
+
+		addr := net.JoinHostPort(r.Target, strconv.Itoa(int(r.Port)))
+
+		res = append(res, peers.NewPeer(normalizePubKey(r.PubKey), addr, r.Moniker))
+	}
+
+	return res, nil
+}
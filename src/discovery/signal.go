@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// Presence defines an interface for signaling systems that list the peers
+// currently connected to them.
+type Presence interface {
+	// ID returns the identifier of this end of the connection
+	ID() string
+
+	// Presence returns the identifiers of the connected peers
+	Presence() ([]string, error)
+}
+
+// SignalSource is a Source that returns the peers connected to a WebRTC
+// signaling server. With WebRTC, peers are identified by their public keys,
+// which also serve as network addresses.
+type SignalSource struct {
+	presence Presence
+}
+
+// NewSignalSource creates a SignalSource from a signaling client.
+func NewSignalSource(presence Presence) *SignalSource {
+	return &SignalSource{
+		presence: presence,
+	}
+}
+
+// Name implements the Source interface.
+func (s *SignalSource) Name() string {
+	return "signal"
+}
+
+// Peers implements the Source interface. It excludes the signaling client's
+// own identifier.
+func (s *SignalSource) Peers() ([]*peers.Peer, error) {
+	ids, err := s.presence.Presence()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*peers.Peer{}
+	for _, id := range ids {
+		if id == s.presence.ID() {
+			continue
+		}
+		res = append(res, peers.NewPeer(id, id, ""))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// StaticSource is a Source that always returns the same peers.
+type StaticSource struct {
+	peers []*peers.Peer
+}
+
+// NewStaticSource creates a StaticSource from a list of peers.
+func NewStaticSource(peers []*peers.Peer) *StaticSource {
+	return &StaticSource{
+		peers: peers,
+	}
+}
+
+// NewSeedSource creates a StaticSource from a comma-separated list of seeds.
+// cf. ParseSeeds.
+func NewSeedSource(seeds string) (*StaticSource, error) {
+	ps, err := ParseSeeds(seeds)
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticSource(ps), nil
+}
+
+// Name implements the Source interface.
+func (s *StaticSource) Name() string {
+	return "static"
+}
+
+// Peers implements the Source interface.
+func (s *StaticSource) Peers() ([]*peers.Peer, error) {
+	return s.peers, nil
+}
+
+// ParseSeeds parses a comma-separated list of seeds in the pubkey@address
+// format. With WebRTC, the address is the public key itself, so a seed can be
+// a public key alone.
+func ParseSeeds(seeds string) ([]*peers.Peer, error) {
+	res := []*peers.Peer{}
+
+	for _, seed := range strings.Split(seeds, ",") {
+		seed = strings.TrimSpace(seed)
+		if seed == "" {
+			continue
+		}
+
+		pubKey, addr := seed, ""
+		if i := strings.Index(seed, "@"); i >= 0 {
+			pubKey, addr = seed[:i], seed[i+1:]
+			if pubKey == "" || addr == "" {
+				return nil, fmt.Errorf("Invalid seed %q, expected pubkey@address", seed)
+			}
+		}
+
+		pubKey = normalizePubKey(pubKey)
+		if addr == "" {
+			addr = pubKey
+		}
+
+		res = append(res, peers.NewPeer(pubKey, addr, ""))
+	}
+
+	return res, nil
+}
+
+// normalizePubKey formats a public key like the peers.json file does.
+func normalizePubKey(pubKey string) string {
+	return "0X" + strings.TrimPrefix(strings.ToUpper(pubKey), "0X")
+}
@@ -0,0 +1,22 @@
+// Package genesis defines the genesis file of a Kdag network.
+//
+// The genesis file, genesis.json, is placed in the data directory of every
+// node of a network. It records the properties that all the nodes must agree
+// upon from the inception of the network:
+//
+//  chain_id // a name that distinguishes the network from any other.
+//  genesis_time // the time at which the network was created.
+//  validators // the initial validator-set.
+//  consensus_params // the fame-voting parameters, cf. hashgraph.ConsensusParams.
+//  app_state_hash // (optional) the hash of the initial state of the application.
+//  join_policy // (optional) the peers that may join, cf. JoinPolicy.
+//
+// The hash of the genesis file identifies the network. Nodes include it in all
+// their requests and responses, and reject the ones that carry a different
+// hash, so that unrelated networks never exchange events, even if they share
+// the same keys.
+//
+// When a genesis.json file is present, it takes precedence over the
+// peers.genesis.json and consensus.genesis.json files. The "kdag init" command
+// creates a genesis.json file from the peers files of a data directory.
+package genesis
@@ -0,0 +1,172 @@
+package genesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto"
+	"github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// Genesis contains the properties of a Kdag network that are fixed at its
+// inception.
+type Genesis struct {
+	ChainID         string                    `json:"chain_id"`
+	GenesisTime     time.Time                 `json:"genesis_time"`
+	Validators      []*peers.Peer             `json:"validators"`
+	ConsensusParams hashgraph.ConsensusParams `json:"consensus_params"`
+	AppStateHash    string                    `json:"app_state_hash,omitempty"`
+	JoinPolicy      *JoinPolicy               `json:"join_policy,omitempty"`
+}
+
+// JoinPolicy restricts the peers that may join the validator-set. It is part of
+// the Genesis, so that all the validators apply the same policy when they
+// commit PEER_ADD InternalTransactions.
+type JoinPolicy struct {
+	// Allowlist contains the public keys of the peers that may join. Empty
+	// means any peer.
+	Allowlist []string `json:"allowlist,omitempty"`
+	// RequireInvite requires joining peers to present an unexpired invite
+	// created by a validator (cf. hashgraph.NewJoinInvite).
+	RequireInvite bool `json:"require_invite,omitempty"`
+	// MaxValidators is the size of the validator-set beyond which joins are
+	// refused. 0 means no limit.
+	MaxValidators int `json:"max_validators,omitempty"`
+}
+
+// NewGenesis creates a Genesis with the default consensus parameters.
+func NewGenesis(chainID string, genesisTime time.Time, validators []*peers.Peer) *Genesis {
+	g := &Genesis{
+		ChainID:         chainID,
+		GenesisTime:     genesisTime.UTC(),
+		Validators:      validators,
+		ConsensusParams: hashgraph.DefaultConsensusParams(),
+	}
+	g.normalize()
+	return g
+}
+
+// Read reads a Genesis from a JSON file, and validates it.
+func Read(path string) (*Genesis, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Genesis{
+		ConsensusParams: hashgraph.DefaultConsensusParams(),
+	}
+
+	if err := json.Unmarshal(buf, g); err != nil {
+		return nil, err
+	}
+
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Write writes the Genesis to a JSON file.
+func (g *Genesis) Write(path string) error {
+	buf, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// normalize standardizes the public keys of the validators, like
+// peers.JSONPeerSet does, and the time zone of GenesisTime, so that equivalent
+// files produce the same hash.
+func (g *Genesis) normalize() {
+	for _, v := range g.Validators {
+		v.PubKeyHex = "0X" + strings.TrimPrefix(strings.ToUpper(v.PubKeyHex), "0X")
+	}
+	g.GenesisTime = g.GenesisTime.UTC()
+	g.AppStateHash = strings.ToUpper(g.AppStateHash)
+	if g.JoinPolicy != nil {
+		for i, pubKey := range g.JoinPolicy.Allowlist {
+			g.JoinPolicy.Allowlist[i] = "0X" + strings.TrimPrefix(strings.ToUpper(pubKey), "0X")
+		}
+	}
+}
+
+// Validate normalizes the Genesis, and returns an error if it is incomplete or
+// inconsistent.
+func (g *Genesis) Validate() error {
+	g.normalize()
+
+	if g.ChainID == "" {
+		return fmt.Errorf("Genesis chain_id is empty")
+	}
+
+	if g.GenesisTime.IsZero() {
+		return fmt.Errorf("Genesis genesis_time is not set")
+	}
+
+	if len(g.Validators) == 0 {
+		return fmt.Errorf("Genesis has no validators")
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range g.Validators {
+		if seen[v.PubKeyHex] {
+			return fmt.Errorf("Genesis validator %s is listed twice", v.PubKeyHex)
+		}
+		seen[v.PubKeyHex] = true
+
+		if v.Weight < 0 {
+			return fmt.Errorf("Genesis validator %s has a negative weight", v.PubKeyHex)
+		}
+	}
+
+	if g.AppStateHash != "" {
+		if !strings.HasPrefix(g.AppStateHash, "0X") {
+			return fmt.Errorf("Genesis app_state_hash must start with 0X")
+		}
+		if _, err := common.DecodeFromString(g.AppStateHash); err != nil {
+			return fmt.Errorf("Genesis app_state_hash is not a hex string")
+		}
+	}
+
+	if g.JoinPolicy != nil && g.JoinPolicy.MaxValidators < 0 {
+		return fmt.Errorf("Genesis join_policy max_validators is negative")
+	}
+
+	return g.ConsensusParams.Validate()
+}
+
+// Hash returns the SHA256 hash of the JSON encoding of the normalized Genesis.
+func (g *Genesis) Hash() ([]byte, error) {
+	g.normalize()
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	if err := enc.Encode(g); err != nil {
+		return nil, err
+	}
+	return crypto.SHA256(b.Bytes()), nil
+}
+
+// HashString returns the hex representation of the Genesis hash.
+func (g *Genesis) HashString() (string, error) {
+	hash, err := g.Hash()
+	if err != nil {
+		return "", err
+	}
+	return common.EncodeToString(hash), nil
+}
+
+// PeerSet returns the initial validator-set.
+func (g *Genesis) PeerSet() *peers.PeerSet {
+	return peers.NewPeerSet(g.Validators)
+}
@@ -0,0 +1,117 @@
+package genesis
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+func newTestGenesis(t *testing.T, chainID string) *Genesis {
+	validators := []*peers.Peer{}
+	for i := 0; i < 3; i++ {
+		key, err := keys.GenerateECDSAKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		validators = append(validators, peers.NewPeer(keys.PublicKeyHex(&key.PublicKey), fmt.Sprintf("addr%d", i), fmt.Sprintf("peer%d", i)))
+	}
+	return NewGenesis(chainID, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), validators)
+}
+
+func TestGenesisReadWrite(t *testing.T) {
+	g := newTestGenesis(t, "test-chain")
+	g.AppStateHash = "0xabcd"
+
+	dir, err := ioutil.TempDir("", "kdag")
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "genesis.json")
+	if err := g.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	g2, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g2.AppStateHash != "0XABCD" {
+		t.Fatalf("AppStateHash should be normalized, not %s", g2.AppStateHash)
+	}
+
+	h1, err := g.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h2, err := g2.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(h1, h2) {
+		t.Fatalf("Hash should not change when writing and reading the genesis")
+	}
+
+	g2.ChainID = "other-chain"
+	h3, err := g2.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(h1, h3) {
+		t.Fatalf("Hash should depend on the chain ID")
+	}
+
+	g2.ChainID = g.ChainID
+	g2.JoinPolicy = &JoinPolicy{Allowlist: []string{"0xabcd"}, RequireInvite: true}
+	h4, err := g2.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(h1, h4) {
+		t.Fatalf("Hash should depend on the join policy")
+	}
+
+	if g2.JoinPolicy.Allowlist[0] != "0XABCD" {
+		t.Fatalf("Allowlist should be normalized, not %s", g2.JoinPolicy.Allowlist[0])
+	}
+}
+
+func TestGenesisValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(g *Genesis)
+	}{
+		{"no chain ID", func(g *Genesis) { g.ChainID = "" }},
+		{"no genesis time", func(g *Genesis) { g.GenesisTime = time.Time{} }},
+		{"no validators", func(g *Genesis) { g.Validators = nil }},
+		{"duplicate validator", func(g *Genesis) { g.Validators = append(g.Validators, g.Validators[0]) }},
+		{"bad app state hash", func(g *Genesis) { g.AppStateHash = "0XZZ" }},
+		{"bad coin round freq", func(g *Genesis) { g.ConsensusParams.CoinRoundFreq = 1 }},
+		{"negative max validators", func(g *Genesis) { g.JoinPolicy = &JoinPolicy{MaxValidators: -1} }},
+	}
+
+	if err := newTestGenesis(t, "test-chain").Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		g := newTestGenesis(t, "test-chain")
+		c.modify(g)
+		if err := g.Validate(); err == nil {
+			t.Fatalf("%s: Validate should return an error", c.name)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package hashgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// ArchiveVersion is the version of the archive format produced by Export.
+const ArchiveVersion = 1
+
+// Types of records in an archive.
+const (
+	ArchiveHeaderRecord  = "header"
+	ArchivePeerSetRecord = "peerset"
+	ArchiveEventRecord   = "event"
+	ArchiveBlockRecord   = "block"
+	ArchiveFrameRecord   = "frame"
+)
+
+/*
+An archive is a stream of JSON encoded ArchiveRecords separated by newlines. The
+first record is always a header. It is followed by the peer-sets, in round
+order, the raw events (optional), in topological order, and the blocks, in
+index order, each one followed by its frame. Blocks carry their signatures.
+
+This layout makes it possible to write and read an archive without holding the
+whole history in memory, and to inspect it with standard line-oriented tools.
+*/
+
+// ArchiveRecord is an element of an archive. Data contains the JSON encoding
+// of the object identified by Type, as it is stored in the database.
+type ArchiveRecord struct {
+	Type  string
+	Round int `json:",omitempty"` // only used by peer-set records
+	Data  json.RawMessage
+}
+
+// ArchiveHeader describes the content of an archive.
+type ArchiveHeader struct {
+	Version   int
+	Timestamp int64 // unix timestamp of the export
+	Events    bool  // whether the archive contains raw events
+}
+
+// Export writes the history contained in the store's database to w. The
+// history is read directly from the database, so the store should not be in
+// use by a running node. Raw events are only included if withEvents is true;
+// they are necessary for a node to bootstrap from an imported archive.
+func (s *BadgerStore) Export(w io.Writer, withEvents bool) error {
+	enc := json.NewEncoder(w)
+
+	writeRecord := func(recordType string, round int, v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(ArchiveRecord{
+			Type:  recordType,
+			Round: round,
+			Data:  data,
+		})
+	}
+
+	header := ArchiveHeader{
+		Version:   ArchiveVersion,
+		Timestamp: time.Now().Unix(),
+		Events:    withEvents,
+	}
+	if err := writeRecord(ArchiveHeaderRecord, 0, header); err != nil {
+		return err
+	}
+
+	// PeerSets
+	peerSets, err := s.dbGetPeerSets()
+	if err != nil {
+		return err
+	}
+	rounds := []int{}
+	for r := range peerSets {
+		rounds = append(rounds, r)
+	}
+	sort.Ints(rounds)
+	for _, r := range rounds {
+		if err := writeRecord(ArchivePeerSetRecord, r, peerSets[r].Peers); err != nil {
+			return err
+		}
+	}
+
+	// Events
+	if withEvents {
+		batchSize := 100
+		for index := 0; ; index += batchSize {
+			events, err := s.dbTopologicalEvents(index, batchSize)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				data, err := e.MarshalDB()
+				if err != nil {
+					return err
+				}
+				if err := enc.Encode(ArchiveRecord{Type: ArchiveEventRecord, Data: data}); err != nil {
+					return err
+				}
+			}
+			if len(events) < batchSize {
+				break
+			}
+		}
+	}
+
+	// Blocks and Frames
+	for i := 0; ; i++ {
+		block, err := s.dbGetBlock(i)
+		if err != nil {
+			if isDBKeyNotFound(err) {
+				break
+			}
+			return err
+		}
+		if err := writeRecord(ArchiveBlockRecord, 0, block); err != nil {
+			return err
+		}
+
+		frame, err := s.dbGetFrame(block.RoundReceived())
+		if err != nil {
+			if isDBKeyNotFound(err) {
+				continue
+			}
+			return err
+		}
+		data, err := frame.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(ArchiveRecord{Type: ArchiveFrameRecord, Data: data}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import reads an archive produced by Export and writes its content to the
+// store's database, which must not contain a genesis peer-set already. A node
+// can then be started from the database with the bootstrap option, provided
+// that the archive contains raw events.
+func (s *BadgerStore) Import(r io.Reader) error {
+	if s.maintenanceMode {
+		return fmt.Errorf("Cannot import in maintenance mode")
+	}
+
+	if _, err := s.dbGetPeerSet(0); err == nil {
+		return fmt.Errorf("Database %s is not empty", s.path)
+	}
+
+	dec := json.NewDecoder(r)
+
+	var header ArchiveHeader
+	var record ArchiveRecord
+	if err := dec.Decode(&record); err != nil {
+		return fmt.Errorf("Reading archive header: %v", err)
+	}
+	if record.Type != ArchiveHeaderRecord {
+		return fmt.Errorf("First archive record should be a header, not %s", record.Type)
+	}
+	if err := json.Unmarshal(record.Data, &header); err != nil {
+		return err
+	}
+	if header.Version != ArchiveVersion {
+		return fmt.Errorf("Unsupported archive version %d", header.Version)
+	}
+
+	events := []*Event{}
+	flushEvents := func() error {
+		if len(events) == 0 {
+			return nil
+		}
+		err := s.dbSetEvents(events)
+		events = []*Event{}
+		return err
+	}
+
+	for {
+		var record ArchiveRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.Type != ArchiveEventRecord {
+			if err := flushEvents(); err != nil {
+				return err
+			}
+		}
+
+		switch record.Type {
+		case ArchivePeerSetRecord:
+			var ps []*peers.Peer
+			if err := json.Unmarshal(record.Data, &ps); err != nil {
+				return err
+			}
+			peerSet := peers.NewPeerSet(ps)
+			if err := s.dbSetPeerSet(record.Round, peerSet); err != nil {
+				return err
+			}
+			for _, p := range peerSet.Peers {
+				if err := s.addParticipant(p); err != nil {
+					return err
+				}
+			}
+		case ArchiveEventRecord:
+			event := new(Event)
+			if err := event.UnmarshalDB(record.Data); err != nil {
+				return err
+			}
+			events = append(events, event)
+			if len(events) == 100 {
+				if err := flushEvents(); err != nil {
+					return err
+				}
+			}
+		case ArchiveBlockRecord:
+			block := new(Block)
+			if err := json.Unmarshal(record.Data, block); err != nil {
+				return err
+			}
+			if err := s.dbSetBlock(block); err != nil {
+				return err
+			}
+		case ArchiveFrameRecord:
+			frame := new(Frame)
+			if err := frame.Unmarshal(record.Data); err != nil {
+				return err
+			}
+			if err := s.dbSetFrame(frame); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unknown archive record type %s", record.Type)
+		}
+	}
+
+	return flushEvents()
+}
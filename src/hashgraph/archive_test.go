@@ -0,0 +1,163 @@
+package hashgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestBadgerArchive(t *testing.T) {
+	cacheSize := 100
+
+	store := initBadgerStore(cacheSize, t)
+	defer removeBadgerStore(store, t)
+
+	peerSet, participants := initPeers(3)
+
+	if err := store.SetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// insert a few events from each participant
+	topologicalIndex := 0
+	events := []*Event{}
+	for k := 0; k < 3; k++ {
+		for _, p := range participants {
+			selfParent := ""
+			if k > 0 {
+				selfParent = events[len(events)-len(participants)].Hex()
+			}
+			event := NewEvent(
+				[][]byte{[]byte(fmt.Sprintf("%s_%d", p.hex[:5], k))},
+				[]InternalTransaction{},
+				[]BlockSignature{},
+				[]string{selfParent, ""},
+				p.pubKey,
+				k)
+			event.Sign(p.privKey)
+			event.topologicalIndex = topologicalIndex
+			topologicalIndex++
+
+			if err := store.SetEvent(event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event)
+		}
+	}
+
+	// insert signed blocks with their frames
+	for i := 0; i < 3; i++ {
+		frame := &Frame{
+			Round:  i + 1,
+			Peers:  peerSet.Peers,
+			Roots:  map[string]*Root{},
+			Events: []*FrameEvent{},
+		}
+		if err := store.SetFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+
+		frameHash, err := frame.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		block := NewBlock(i, i+1, frameHash, peerSet.Peers, [][]byte{[]byte(fmt.Sprintf("tx%d", i))}, []InternalTransaction{}, 0)
+		sig, err := block.Sign(participants[0].privKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.SetSignature(sig)
+
+		if err := store.SetBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var archive bytes.Buffer
+	if err := store.Export(&archive, true); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("test_data", "badger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	importStore, err := NewBadgerStore(cacheSize, 0, dir, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeBadgerStore(importStore, t)
+
+	if err := importStore.Import(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("PeerSets", func(t *testing.T) {
+		ps, err := importStore.dbGetPeerSet(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps.Hex() != peerSet.Hex() {
+			t.Fatalf("Imported PeerSet should be %s, not %s", peerSet.Hex(), ps.Hex())
+		}
+
+		for _, p := range participants {
+			if _, err := importStore.dbGetRoot(p.hex); err != nil {
+				t.Fatalf("Root of %s not imported: %v", p.hex, err)
+			}
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		imported, err := importStore.dbTopologicalEvents(0, len(events)+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(imported) != len(events) {
+			t.Fatalf("There should be %d imported events, not %d", len(events), len(imported))
+		}
+		for i, ev := range imported {
+			if ev.Hex() != events[i].Hex() {
+				t.Fatalf("Imported event %d should be %s, not %s", i, events[i].Hex(), ev.Hex())
+			}
+		}
+	})
+
+	t.Run("Blocks and Frames", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			block, err := store.dbGetBlock(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			importedBlock, err := importStore.dbGetBlock(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(importedBlock.Body, block.Body) ||
+				!reflect.DeepEqual(importedBlock.Signatures, block.Signatures) {
+				t.Fatalf("Imported block %d does not match", i)
+			}
+
+			importedFrame, err := importStore.dbGetFrame(block.RoundReceived())
+			if err != nil {
+				t.Fatal(err)
+			}
+			importedFrameHash, err := importedFrame.Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(importedFrameHash, block.FrameHash()) {
+				t.Fatalf("Imported frame %d does not match block", block.RoundReceived())
+			}
+		}
+	})
+
+	t.Run("Import in non-empty database", func(t *testing.T) {
+		if err := importStore.Import(bytes.NewReader(archive.Bytes())); err == nil {
+			t.Fatal("Importing in a non-empty database should fail")
+		}
+	})
+}
@@ -13,9 +13,12 @@ See https://github.com/Kdag-K/kdag-android/issues/20
 */
 
 import (
+	"bytes"
 	"fmt"
+	"strconv"
 
 	cm "github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto"
 	"github.com/Kdag-K/kdag/src/peers"
 	"github.com/jonknight73/badger"
 	badger_options "github.com/jonknight73/badger/options"
@@ -30,22 +33,37 @@ const (
 	topoPrefix       = "topo"
 	blockPrefix      = "block"
 	framePrefix      = "frame"
+	txIndexPrefix    = "txindex"
+	blockTimePrefix  = "blocktime"
+	indexedKey       = "indexed"
+	dataKeyKey       = "datakey"
 )
 
 // BadgerStore contains references to the Badger database and inmem store. If
 // maintenanceMode is activated, data is not written to the Badger database, but
-// only to the caches.
+// only to the caches. If indexes is activated, secondary indexes of
+// transactions and block timestamps are maintained alongside the blocks. If
+// dataKey is set, values are encrypted with it before being written to disk.
 type BadgerStore struct {
 	inmemStore      *InmemStore
 	db              *badger.DB
 	path            string
 	maintenanceMode bool
+	indexes         bool
+	dataKey         []byte
 }
 
 // NewBadgerStore opens an existing database or creates a new one if nothing is
-// found in path. The maintenanceMode option deactivates writing to the
-// persistant database, but adding/updating the inmem-store is preserved.
-func NewBadgerStore(cacheSize int, path string, maintenanceMode bool, logger *logrus.Entry) (*BadgerStore, error) {
+// found in path. The cacheSize and cacheBytes options limit the underlying
+// InmemStore, as in NewSizedInmemStore. The maintenanceMode option deactivates writing to the
+// persistant database, but adding/updating the inmem-store is preserved. The
+// indexes option activates the transaction and block-timestamp indexes; the
+// blocks that were stored while they were not activated are indexed when the
+// store is opened, unless in maintenance mode. If
+// encryptionKey is not nil, the values in the database are encrypted at rest,
+// but not the keys; a new database is initialised with a data-key protected by
+// encryptionKey, and an existing one must have been created with the same key.
+func NewBadgerStore(cacheSize int, cacheBytes int64, path string, maintenanceMode bool, indexes bool, encryptionKey []byte, logger *logrus.Entry) (*BadgerStore, error) {
 
 	opts := badger.DefaultOptions(path).
 		WithSyncWrites(false).
@@ -64,11 +82,25 @@ func NewBadgerStore(cacheSize int, path string, maintenanceMode bool, logger *lo
 	}
 
 	store := &BadgerStore{
-		inmemStore:      NewInmemStore(cacheSize),
+		inmemStore:      NewSizedInmemStore(cacheSize, cacheBytes),
 		db:              handle,
 		path:            path,
 		maintenanceMode: maintenanceMode,
+		indexes:         indexes,
 	}
+
+	if err := store.initEncryption(encryptionKey); err != nil {
+		handle.Close()
+		return nil, err
+	}
+
+	if indexes && !maintenanceMode {
+		if err := store.dbBackfillIndexes(); err != nil {
+			handle.Close()
+			return nil, err
+		}
+	}
+
 	return store, nil
 }
 
@@ -108,6 +140,14 @@ func frameKey(index int) []byte {
 	return []byte(fmt.Sprintf("%s_%09d", framePrefix, index))
 }
 
+func txIndexKey(txHash string) []byte {
+	return []byte(fmt.Sprintf("%s_%s", txIndexPrefix, txHash))
+}
+
+func blockTimeKey(timestamp int64, index int) []byte {
+	return []byte(fmt.Sprintf("%s_%020d_%09d", blockTimePrefix, timestamp, index))
+}
+
 /*******************************************************************************
 Implement the Store interface
 
@@ -133,6 +173,16 @@ func (s *BadgerStore) CacheSize() int {
 	return s.inmemStore.CacheSize()
 }
 
+// CacheBytes gets the inmem cache byte budget
+func (s *BadgerStore) CacheBytes() int64 {
+	return s.inmemStore.CacheBytes()
+}
+
+// CacheStats returns the usage statistics of the inmem caches.
+func (s *BadgerStore) CacheStats() map[string]cm.LRUStats {
+	return s.inmemStore.CacheStats()
+}
+
 // GetRound returns the round with round-number r.
 func (s *BadgerStore) GetRound(r int) (*RoundInfo, error) {
 	return s.inmemStore.GetRound(r)
@@ -368,6 +418,26 @@ func (s *BadgerStore) SetBlock(block *Block) error {
 	return s.dbSetBlock(block)
 }
 
+// GetTxLocation returns the location of a transaction from the transaction
+// index. It falls back to the cached blocks if indexes are not activated.
+func (s *BadgerStore) GetTxLocation(txHash string) (TxLocation, error) {
+	if !s.indexes {
+		return s.inmemStore.GetTxLocation(txHash)
+	}
+	res, err := s.dbGetTxLocation(txHash)
+	return res, mapError(err, "TxIndex", string(txIndexKey(txHash)))
+}
+
+// GetBlockIndexesByTime returns the indexes of blocks whose timestamp is within
+// [start, end], from the block-timestamp index. It falls back to the cached
+// blocks if indexes are not activated.
+func (s *BadgerStore) GetBlockIndexesByTime(start, end int64) ([]int, error) {
+	if !s.indexes {
+		return s.inmemStore.GetBlockIndexesByTime(start, end)
+	}
+	return s.dbGetBlockIndexesByTime(start, end)
+}
+
 // SetFrame creates or updates a Frame in the Store.
 func (s *BadgerStore) SetFrame(frame *Frame) error {
 	if err := s.inmemStore.SetFrame(frame); err != nil {
@@ -436,7 +506,7 @@ func (s *BadgerStore) dbGetRepertoire() (map[string]*peers.Peer, error) {
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
 
-			err := item.Value(func(data []byte) error {
+			err := s.dbValueFunc(item, func(data []byte) error {
 				peer := &peers.Peer{}
 				err := peer.Unmarshal(data)
 				if err != nil {
@@ -473,7 +543,7 @@ func (s *BadgerStore) dbSetRepertoire(peer *peers.Peer) error {
 	}
 
 	//insert [pub] => [Peer]
-	if err := tx.Set(key, val); err != nil {
+	if err := s.dbSet(tx, key, val); err != nil {
 		return err
 	}
 
@@ -488,7 +558,7 @@ func (s *BadgerStore) dbGetPeerSet(round int) (*peers.PeerSet, error) {
 		if err != nil {
 			return err
 		}
-		peerSliceBytes, err = item.ValueCopy(nil)
+		peerSliceBytes, err = s.dbValue(item)
 		return err
 	})
 
@@ -505,6 +575,42 @@ func (s *BadgerStore) dbGetPeerSet(round int) (*peers.PeerSet, error) {
 	return peerSet, nil
 }
 
+func (s *BadgerStore) dbGetPeerSets() (map[int]*peers.PeerSet, error) {
+	peerSets := make(map[int]*peers.PeerSet)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(peerSetPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			var round int
+			if _, err := fmt.Sscanf(string(item.Key()), peerSetPrefix+"_%d", &round); err != nil {
+				return err
+			}
+
+			err := s.dbValueFunc(item, func(data []byte) error {
+				peerSet := new(peers.PeerSet)
+				if err := peerSet.Unmarshal(data); err != nil {
+					return err
+				}
+				peerSets[round] = peerSet
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return peerSets, nil
+}
+
 func (s *BadgerStore) dbSetPeerSet(round int, peerSet *peers.PeerSet) error {
 	tx := s.db.NewTransaction(true)
 	defer tx.Discard()
@@ -516,7 +622,7 @@ func (s *BadgerStore) dbSetPeerSet(round int, peerSet *peers.PeerSet) error {
 	}
 
 	//insert [round_index] => [PeerSet bytes]
-	if err := tx.Set(key, val); err != nil {
+	if err := s.dbSet(tx, key, val); err != nil {
 		return err
 	}
 
@@ -530,7 +636,7 @@ func (s *BadgerStore) dbGetEvent(key string) (*Event, error) {
 		if err != nil {
 			return err
 		}
-		eventBytes, err = item.ValueCopy(nil)
+		eventBytes, err = s.dbValue(item)
 		return err
 	})
 
@@ -563,19 +669,19 @@ func (s *BadgerStore) dbSetEvents(events []*Event) error {
 			new = true
 		}
 		//insert [event hash] => [event bytes]
-		if err := tx.Set([]byte(eventHex), val); err != nil {
+		if err := s.dbSet(tx, []byte(eventHex), val); err != nil {
 			return err
 		}
 
 		if new {
 			//insert [topo_index] => [event hash]
 			topoKey := topologicalEventKey(event.topologicalIndex)
-			if err := tx.Set(topoKey, []byte(eventHex)); err != nil {
+			if err := s.dbSet(tx, topoKey, []byte(eventHex)); err != nil {
 				return err
 			}
 			//insert [participant_index] => [event hash]
 			peKey := participantEventKey(event.Creator(), event.Index())
-			if err := tx.Set(peKey, []byte(eventHex)); err != nil {
+			if err := s.dbSet(tx, peKey, []byte(eventHex)); err != nil {
 				return err
 			}
 		}
@@ -590,7 +696,7 @@ func (s *BadgerStore) dbParticipantEvents(participant string, skip int) ([]strin
 		key := participantEventKey(participant, i)
 		item, errr := txn.Get(key)
 		for errr == nil {
-			v, errrr := item.ValueCopy(nil)
+			v, errrr := s.dbValue(item)
 			if errrr != nil {
 				break
 			}
@@ -618,7 +724,7 @@ func (s *BadgerStore) dbParticipantEvent(participant string, index int) (string,
 		if err != nil {
 			return err
 		}
-		data, err = item.ValueCopy(nil)
+		data, err = s.dbValue(item)
 		return err
 	})
 	if err != nil {
@@ -634,7 +740,7 @@ func (s *BadgerStore) dbTopologicalEvents(start int, count int) ([]*Event, error
 		key := topologicalEventKey(t)
 		item, errr := txn.Get(key)
 		for errr == nil && (t < start+count) {
-			v, errrr := item.ValueCopy(nil)
+			v, errrr := s.dbValue(item)
 			if errrr != nil {
 				break
 			}
@@ -644,7 +750,7 @@ func (s *BadgerStore) dbTopologicalEvents(start int, count int) ([]*Event, error
 			if err != nil {
 				return err
 			}
-			eventBytes, err := eventItem.ValueCopy(nil)
+			eventBytes, err := s.dbValue(eventItem)
 			if err != nil {
 				return err
 			}
@@ -682,7 +788,7 @@ func (s *BadgerStore) dbSetRoot(participant string, root *Root) error {
 	}
 
 	//insert [round_index] => [round bytes]
-	if err := tx.Set(key, val); err != nil {
+	if err := s.dbSet(tx, key, val); err != nil {
 		return err
 	}
 
@@ -697,7 +803,7 @@ func (s *BadgerStore) dbGetRoot(participant string) (*Root, error) {
 		if err != nil {
 			return err
 		}
-		rootBytes, err = item.ValueCopy(nil)
+		rootBytes, err = s.dbValue(item)
 		return err
 	})
 
@@ -721,7 +827,7 @@ func (s *BadgerStore) dbGetRound(index int) (*RoundInfo, error) {
 		if err != nil {
 			return err
 		}
-		roundBytes, err = item.ValueCopy(nil)
+		roundBytes, err = s.dbValue(item)
 		return err
 	})
 
@@ -748,7 +854,7 @@ func (s *BadgerStore) dbSetRound(index int, round *RoundInfo) error {
 	}
 
 	//insert [round_index] => [round bytes]
-	if err := tx.Set(key, val); err != nil {
+	if err := s.dbSet(tx, key, val); err != nil {
 		return err
 	}
 
@@ -763,7 +869,7 @@ func (s *BadgerStore) dbGetBlock(index int) (*Block, error) {
 		if err != nil {
 			return err
 		}
-		blockBytes, err = item.ValueCopy(nil)
+		blockBytes, err = s.dbValue(item)
 		return err
 	})
 
@@ -789,14 +895,179 @@ func (s *BadgerStore) dbSetBlock(block *Block) error {
 		return err
 	}
 
+	//check if it already exists
+	isNew := false
+	_, err = tx.Get(key)
+	if err != nil && isDBKeyNotFound(err) {
+		isNew = true
+	}
+
 	//insert [index] => [block bytes]
-	if err := tx.Set(key, val); err != nil {
+	if err := s.dbSet(tx, key, val); err != nil {
 		return err
 	}
 
+	//blocks are updated when they collect signatures, but their transactions
+	//and timestamp do not change, so the indexes are only written once.
+	if s.indexes && isNew {
+		if err := s.dbIndexBlock(tx, block); err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
+//dbBackfillIndexes indexes the blocks stored after the last indexed block,
+//which were stored while the indexes were not activated, or before they
+//existed.
+func (s *BadgerStore) dbBackfillIndexes() error {
+	from := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(indexedKey))
+		if err != nil {
+			return err
+		}
+		v, err := s.dbValue(item)
+		if err != nil {
+			return err
+		}
+		last, err := strconv.Atoi(string(v))
+		from = last + 1
+		return err
+	})
+	if err != nil && !isDBKeyNotFound(err) {
+		return err
+	}
+
+	//collect the indexes first, so that the blocks are indexed in their own
+	//transactions.
+	pending := []int{}
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(blockPrefix + "_")
+		for it.Seek(blockKey(from)); it.ValidForPrefix(prefix); it.Next() {
+			index, err := strconv.Atoi(string(it.Item().Key()[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			pending = append(pending, index)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, index := range pending {
+		block, err := s.dbGetBlock(index)
+		if err != nil {
+			return err
+		}
+
+		tx := s.db.NewTransaction(true)
+		if err := s.dbIndexBlock(tx, block); err != nil {
+			tx.Discard()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *BadgerStore) dbIndexBlock(tx *badger.Txn, block *Block) error {
+	for i, t := range block.Transactions() {
+		loc := TxLocation{
+			BlockIndex: block.Index(),
+			Position:   i,
+		}
+		val, err := loc.Marshal()
+		if err != nil {
+			return err
+		}
+
+		//insert [tx hash] => [location bytes]
+		if err := s.dbSet(tx, txIndexKey(TxHash(t)), val); err != nil {
+			return err
+		}
+	}
+
+	//insert [timestamp_index] => [index]
+	timeKey := blockTimeKey(block.Timestamp(), block.Index())
+	if err := s.dbSet(tx, timeKey, []byte(strconv.Itoa(block.Index()))); err != nil {
+		return err
+	}
+
+	//blocks are stored in order, so all the blocks up to this one are indexed
+	return s.dbSet(tx, []byte(indexedKey), []byte(strconv.Itoa(block.Index())))
+}
+
+func (s *BadgerStore) dbGetTxLocation(txHash string) (TxLocation, error) {
+	var locBytes []byte
+	key := txIndexKey(txHash)
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		locBytes, err = s.dbValue(item)
+		return err
+	})
+
+	if err != nil {
+		return TxLocation{}, err
+	}
+
+	loc := TxLocation{}
+	if err := loc.Unmarshal(locBytes); err != nil {
+		return TxLocation{}, err
+	}
+
+	return loc, nil
+}
+
+func (s *BadgerStore) dbGetBlockIndexesByTime(start, end int64) ([]int, error) {
+	res := []int{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(blockTimePrefix)
+		//keys are ordered by timestamp, then by index, so the last key for
+		//timestamp "end" is lower than the first key for "end+1".
+		last := blockTimeKey(end+1, 0)
+		for it.Seek(blockTimeKey(start, 0)); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if bytes.Compare(item.Key(), last) >= 0 {
+				break
+			}
+
+			v, err := s.dbValue(item)
+			if err != nil {
+				return err
+			}
+
+			index, err := strconv.Atoi(string(v))
+			if err != nil {
+				return err
+			}
+			res = append(res, index)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (s *BadgerStore) dbGetFrame(index int) (*Frame, error) {
 	var frameBytes []byte
 	key := frameKey(index)
@@ -805,7 +1076,7 @@ func (s *BadgerStore) dbGetFrame(index int) (*Frame, error) {
 		if err != nil {
 			return err
 		}
-		frameBytes, err = item.ValueCopy(nil)
+		frameBytes, err = s.dbValue(item)
 		return err
 	})
 
@@ -832,13 +1103,148 @@ func (s *BadgerStore) dbSetFrame(frame *Frame) error {
 	}
 
 	//insert [index] => [block bytes]
-	if err := tx.Set(key, val); err != nil {
+	if err := s.dbSet(tx, key, val); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*******************************************************************************
+Encryption
+
+Values are encrypted with a random data-key, which is itself stored in the
+database, encrypted with the master key provided to NewBadgerStore. Rotating the
+master key only requires re-encrypting the data-key.
+
+Keys are not encrypted, because lookups and prefix scans rely on them. They
+reveal the hashes of Events and transactions, the public keys of participants,
+round and block indexes, and, with the indexes option, block timestamps.
+*******************************************************************************/
+
+// RotateEncryptionKey re-encrypts the data-key of an encrypted database with a
+// new master key. The database can only be opened with the new key thereafter.
+func (s *BadgerStore) RotateEncryptionKey(newKey []byte) error {
+	if s.dataKey == nil {
+		return fmt.Errorf("Database is not encrypted")
+	}
+	return s.dbSetDataKey(newKey)
+}
+
+func (s *BadgerStore) initEncryption(masterKey []byte) error {
+	var wrappedKey []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(dataKeyKey))
+		if err != nil {
+			return err
+		}
+		wrappedKey, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if err == nil {
+		if masterKey == nil {
+			return fmt.Errorf("Database is encrypted but no encryption key was provided")
+		}
+		dataKey, err := crypto.Open(masterKey, wrappedKey)
+		if err != nil {
+			return fmt.Errorf("Cannot decrypt database: wrong encryption key")
+		}
+		s.dataKey = dataKey
+		return nil
+	}
+
+	if !isDBKeyNotFound(err) {
+		return err
+	}
+
+	if masterKey == nil {
+		return nil
+	}
+
+	empty, err := s.dbIsEmpty()
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return fmt.Errorf("Cannot encrypt an existing unencrypted database")
+	}
+
+	dataKey, err := crypto.GenerateSymmetricKey()
+	if err != nil {
+		return err
+	}
+	s.dataKey = dataKey
+
+	if s.maintenanceMode {
+		return nil
+	}
+
+	return s.dbSetDataKey(masterKey)
+}
+
+func (s *BadgerStore) dbSetDataKey(masterKey []byte) error {
+	wrappedKey, err := crypto.Seal(masterKey, s.dataKey)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.NewTransaction(true)
+	defer tx.Discard()
+
+	//insert [datakey] => [encrypted data-key]
+	if err := tx.Set([]byte(dataKeyKey), wrappedKey); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
+func (s *BadgerStore) dbIsEmpty() (bool, error) {
+	empty := true
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		it.Rewind()
+		empty = !it.Valid()
+		return nil
+	})
+	return empty, err
+}
+
+// dbSet inserts a value in a transaction, encrypting it first if the database
+// is encrypted.
+func (s *BadgerStore) dbSet(tx *badger.Txn, key []byte, val []byte) error {
+	if s.dataKey != nil {
+		sealed, err := crypto.Seal(s.dataKey, val)
+		if err != nil {
+			return err
+		}
+		val = sealed
+	}
+	return tx.Set(key, val)
+}
+
+// dbValue returns a copy of the value of an item, decrypted if the database is
+// encrypted.
+func (s *BadgerStore) dbValue(item *badger.Item) ([]byte, error) {
+	val, err := item.ValueCopy(nil)
+	if err != nil || s.dataKey == nil {
+		return val, err
+	}
+	return crypto.Open(s.dataKey, val)
+}
+
+func (s *BadgerStore) dbValueFunc(item *badger.Item, fn func(data []byte) error) error {
+	val, err := s.dbValue(item)
+	if err != nil {
+		return err
+	}
+	return fn(val)
+}
+
 //++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
 
 func isDBKeyNotFound(err error) bool {
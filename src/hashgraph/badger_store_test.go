@@ -1,13 +1,17 @@
 package hashgraph
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
 
+	cm "github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto"
 	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/dgraph-io/badger"
 )
 
 func initBadgerStore(cacheSize int, t *testing.T) *BadgerStore {
@@ -18,7 +22,7 @@ func initBadgerStore(cacheSize int, t *testing.T) *BadgerStore {
 		t.Fatal(err)
 	}
 
-	store, err := NewBadgerStore(cacheSize, dir, false, nil)
+	store, err := NewBadgerStore(cacheSize, 0, dir, false, false, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -56,7 +60,7 @@ Call DB methods directly
 *******************************************************************************/
 
 func TestDBRepertoireMethods(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -86,7 +90,7 @@ func TestDBRepertoireMethods(t *testing.T) {
 }
 
 func TestDBPeerSetMethods(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -109,7 +113,7 @@ func TestDBPeerSetMethods(t *testing.T) {
 }
 
 func TestDBEventMethods(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 	testSize := 100
 
 	store := initBadgerStore(cacheSize, t)
@@ -211,7 +215,7 @@ func TestDBEventMethods(t *testing.T) {
 }
 
 func TestDBRoundMethods(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -246,7 +250,7 @@ func TestDBRoundMethods(t *testing.T) {
 }
 
 func TestDBBlockMethods(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -268,7 +272,7 @@ func TestDBBlockMethods(t *testing.T) {
 	}
 	frameHash := []byte("this is the frame hash")
 
-	block := NewBlock(index, roundReceived, frameHash, peerSet.Peers, transactions, internalTransactions)
+	block := NewBlock(index, roundReceived, frameHash, peerSet.Peers, transactions, internalTransactions, 0)
 
 	receipts := []InternalTransactionReceipt{}
 	for _, itx := range block.InternalTransactions() {
@@ -333,7 +337,7 @@ func TestDBBlockMethods(t *testing.T) {
 }
 
 func TestDBFrameMethods(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -561,7 +565,7 @@ func TestBadgerEvents(t *testing.T) {
 }
 
 func TestBadgerRounds(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -607,7 +611,7 @@ func TestBadgerRounds(t *testing.T) {
 }
 
 func TestBadgerBlocks(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -632,7 +636,7 @@ func TestBadgerBlocks(t *testing.T) {
 		NewInternalTransaction(PEER_REMOVE, *peers.NewPeer("peer2", "london", "peer2")),
 	}
 	frameHash := []byte("this is the frame hash")
-	block := NewBlock(index, roundReceived, frameHash, []*peers.Peer{}, transactions, internalTransactions)
+	block := NewBlock(index, roundReceived, frameHash, []*peers.Peer{}, transactions, internalTransactions, 0)
 
 	receipts := []InternalTransactionReceipt{}
 	for _, itx := range block.InternalTransactions() {
@@ -697,7 +701,7 @@ func TestBadgerBlocks(t *testing.T) {
 }
 
 func TestBadgerFrames(t *testing.T) {
-	cacheSize := 0
+	cacheSize := 100
 
 	store := initBadgerStore(cacheSize, t)
 	defer removeBadgerStore(store, t)
@@ -751,3 +755,210 @@ func TestBadgerFrames(t *testing.T) {
 		}
 	})
 }
+
+func TestBadgerIndexes(t *testing.T) {
+	cacheSize := 1
+
+	store := initBadgerStore(cacheSize, t)
+	defer removeBadgerStore(store, t)
+	store.indexes = true
+
+	peerSet, _ := initPeers(3)
+
+	if err := store.SetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []*Block{}
+	for i := 0; i < 5; i++ {
+		transactions := [][]byte{
+			[]byte(fmt.Sprintf("block%d_tx0", i)),
+			[]byte(fmt.Sprintf("block%d_tx1", i)),
+		}
+		block := NewBlock(i, i, []byte("framehash"), peerSet.Peers, transactions, []InternalTransaction{}, int64(100+10*i))
+		if err := store.SetBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	t.Run("Transaction index", func(t *testing.T) {
+		loc, err := store.GetTxLocation(TxHash([]byte("block3_tx1")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := TxLocation{BlockIndex: 3, Position: 1}
+		if !reflect.DeepEqual(loc, expected) {
+			t.Fatalf("TxLocation should be %#v, not %#v", expected, loc)
+		}
+
+		if _, err := store.GetTxLocation(TxHash([]byte("unknown"))); !cm.IsStore(err, cm.KeyNotFound) {
+			t.Fatalf("Unknown transaction should return KeyNotFound, not %v", err)
+		}
+	})
+
+	t.Run("Block timestamp index", func(t *testing.T) {
+		indexes, err := store.GetBlockIndexesByTime(110, 130)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int{1, 2, 3}
+		if !reflect.DeepEqual(indexes, expected) {
+			t.Fatalf("Block indexes should be %v, not %v", expected, indexes)
+		}
+	})
+
+	t.Run("Updating a block does not duplicate indexes", func(t *testing.T) {
+		blocks[2].Body.Timestamp = 999
+		if err := store.SetBlock(blocks[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		indexes, err := store.GetBlockIndexesByTime(0, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int{0, 1, 2, 3, 4}
+		if !reflect.DeepEqual(indexes, expected) {
+			t.Fatalf("Block indexes should be %v, not %v", expected, indexes)
+		}
+	})
+}
+
+func TestBadgerIndexesBackfill(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewBadgerStore(10, 0, dir, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerSet, _ := initPeers(3)
+
+	if err := store.SetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		transactions := [][]byte{
+			[]byte(fmt.Sprintf("block%d_tx0", i)),
+			[]byte(fmt.Sprintf("block%d_tx1", i)),
+		}
+		block := NewBlock(i, i, []byte("framehash"), peerSet.Peers, transactions, []InternalTransaction{}, int64(100+10*i))
+		if err := store.SetBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//without indexes, transactions are looked up in the cached blocks
+	loc, err := store.GetTxLocation(TxHash([]byte("block1_tx1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TxLocation{BlockIndex: 1, Position: 1}
+	if !reflect.DeepEqual(loc, expected) {
+		t.Fatalf("TxLocation should be %#v, not %#v", expected, loc)
+	}
+
+	store.Close()
+
+	//reopen with indexes and an empty cache
+	store, err = NewBadgerStore(10, 0, dir, false, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	loc, err = store.GetTxLocation(TxHash([]byte("block2_tx0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = TxLocation{BlockIndex: 2, Position: 0}
+	if !reflect.DeepEqual(loc, expected) {
+		t.Fatalf("TxLocation should be %#v, not %#v", expected, loc)
+	}
+
+	indexes, err := store.GetBlockIndexesByTime(0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indexes, []int{0, 1, 2}) {
+		t.Fatalf("Block indexes should be %v, not %v", []int{0, 1, 2}, indexes)
+	}
+}
+
+func TestBadgerEncryption(t *testing.T) {
+	dir := t.TempDir()
+
+	key, err := crypto.GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewBadgerStore(100, 0, dir, false, false, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerSet, _ := initPeers(3)
+	if err := store.dbSetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	//check that the raw value is not the plaintext
+	plain, err := peerSet.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(peerSetKey(0))
+		if err != nil {
+			return err
+		}
+		raw, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(raw, plain) {
+			t.Fatal("PeerSet should be encrypted on disk")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey, err := crypto.GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RotateEncryptionKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	if _, err := NewBadgerStore(100, 0, dir, false, false, nil, nil); err == nil {
+		t.Fatal("Opening an encrypted database without a key should fail")
+	}
+
+	if _, err := NewBadgerStore(100, 0, dir, false, false, key, nil); err == nil {
+		t.Fatal("Opening an encrypted database with the key it was rotated from should fail")
+	}
+
+	store, err = NewBadgerStore(100, 0, dir, false, false, newKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ps, err := store.dbGetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.Hex() != peerSet.Hex() {
+		t.Fatalf("PeerSet should be %s, not %s", peerSet.Hex(), ps.Hex())
+	}
+}
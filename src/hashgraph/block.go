@@ -353,5 +353,9 @@ func (b *Block) Verify(sig BlockSignature) (bool, error) {
 		return false, err
 	}
 
+	if r == nil || s == nil {
+		return false, nil
+	}
+
 	return keys.Verify(pubKey, signBytes, r, s), nil
 }
@@ -1,6 +1,7 @@
 package hashgraph
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/Kdag-K/kdag/src/crypto/keys"
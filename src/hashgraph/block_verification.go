@@ -0,0 +1,86 @@
+package hashgraph
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// BlockVerification is the result of checking the signatures of a Block
+// against the validator-set that was effective at the Block's RoundReceived.
+type BlockVerification struct {
+	// Index is the index of the Block.
+	Index int
+	// RoundReceived is the round whose validator-set was used.
+	RoundReceived int
+	// Validators contains the public keys of the validators that legitimately
+	// signed the Block, sorted.
+	Validators []string
+	// Invalid contains the public keys of validators whose signature does not
+	// match the Block, sorted.
+	Invalid []string
+	// NonValidators contains the public keys of signers that did not belong to
+	// the validator-set, sorted.
+	NonValidators []string
+	// Weight is the total voting weight of Validators.
+	Weight int
+	// TrustCount is the voting weight that must be exceeded for the Block to
+	// be verified.
+	TrustCount int
+	// Verified is true if Weight is greater than TrustCount.
+	Verified bool
+}
+
+// verifyBlockSignatures checks every signature of a Block against a
+// validator-set, after checking that the Block was produced with that
+// validator-set.
+func verifyBlockSignatures(block *Block, peerSet *peers.PeerSet) (*BlockVerification, error) {
+	psh, err := peerSet.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(psh, block.PeersHash()) {
+		return nil, fmt.Errorf("Wrong PeerSet")
+	}
+
+	res := &BlockVerification{
+		Index:         block.Index(),
+		RoundReceived: block.RoundReceived(),
+		Validators:    []string{},
+		Invalid:       []string{},
+		NonValidators: []string{},
+		TrustCount:    peerSet.TrustCount(),
+	}
+
+	for _, s := range block.GetSignatures() {
+		validatorHex := s.ValidatorHex()
+
+		if _, ok := peerSet.ByPubKey[validatorHex]; !ok {
+			res.NonValidators = append(res.NonValidators, validatorHex)
+			continue
+		}
+
+		valid, err := block.Verify(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if valid {
+			res.Validators = append(res.Validators, validatorHex)
+			res.Weight += peerSet.WeightOf(validatorHex)
+		} else {
+			res.Invalid = append(res.Invalid, validatorHex)
+		}
+	}
+
+	sort.Strings(res.Validators)
+	sort.Strings(res.Invalid)
+	sort.Strings(res.NonValidators)
+
+	res.Verified = res.Weight > res.TrustCount
+
+	return res, nil
+}
@@ -0,0 +1,90 @@
+package hashgraph
+
+// Approximate memory footprints, in bytes, of the parts of cached objects that
+// do not depend on their payload: hashes, signatures, public keys, maps and
+// struct headers. They are only meant to keep the estimated size of the caches
+// in the right order of magnitude.
+const (
+	eventOverhead          = 512
+	internalTxOverhead     = 256
+	blockSignatureOverhead = 256
+	blockOverhead          = 512
+	frameOverhead          = 256
+	frameEventOverhead     = 64
+	peerOverhead           = 256
+	roundOverhead          = 128
+	roundEventOverhead     = 192
+	consensusEntryOverhead = 192
+)
+
+// eventCost estimates the memory footprint of an Event in the event cache.
+func eventCost(value interface{}) int64 {
+	return sizeOfEvent(value.(*Event))
+}
+
+// roundCost estimates the memory footprint of a RoundInfo in the round cache.
+func roundCost(value interface{}) int64 {
+	round := value.(*RoundInfo)
+
+	return roundOverhead +
+		int64(len(round.CreatedEvents)+len(round.ReceivedEvents))*roundEventOverhead
+}
+
+// consensusEntryCost estimates the memory footprint of an entry in the caches
+// of consensus computations, which is dominated by its key of event hashes.
+func consensusEntryCost(value interface{}) int64 {
+	return consensusEntryOverhead
+}
+
+// blockCost estimates the memory footprint of a Block in the block cache.
+func blockCost(value interface{}) int64 {
+	block := value.(*Block)
+
+	cost := int64(blockOverhead)
+	for _, tx := range block.Body.Transactions {
+		cost += int64(len(tx))
+	}
+	cost += int64(len(block.Body.InternalTransactions)) * internalTxOverhead
+	cost += int64(len(block.Signatures)) * blockSignatureOverhead
+
+	return cost
+}
+
+// frameCost estimates the memory footprint of a Frame in the frame cache.
+func frameCost(value interface{}) int64 {
+	frame := value.(*Frame)
+
+	cost := int64(frameOverhead)
+	for _, fe := range frame.Events {
+		cost += sizeOfFrameEvent(fe)
+	}
+	for _, root := range frame.Roots {
+		for _, fe := range root.Events {
+			cost += sizeOfFrameEvent(fe)
+		}
+	}
+	cost += int64(len(frame.Peers)) * peerOverhead
+	for _, ps := range frame.PeerSets {
+		cost += int64(len(ps)) * peerOverhead
+	}
+
+	return cost
+}
+
+func sizeOfEvent(event *Event) int64 {
+	cost := int64(eventOverhead)
+	for _, tx := range event.Body.Transactions {
+		cost += int64(len(tx))
+	}
+	cost += int64(len(event.Body.InternalTransactions)) * internalTxOverhead
+	cost += int64(len(event.Body.BlockSignatures)) * blockSignatureOverhead
+
+	return cost
+}
+
+func sizeOfFrameEvent(fe *FrameEvent) int64 {
+	if fe.Core == nil {
+		return frameEventOverhead
+	}
+	return frameEventOverhead + sizeOfEvent(fe.Core)
+}
@@ -0,0 +1,63 @@
+package hashgraph
+
+import (
+	"github.com/Kdag-K/kdag/src/common"
+)
+
+// ConsensusEvent describes the position of an Event in the consensus order. It
+// is produced for every Event, as soon as its round-received is decided, and
+// before the Block that contains its transactions is committed.
+type ConsensusEvent struct {
+	// Hash is the hex-encoded hash of the Event.
+	Hash string
+	// Creator is the hex-encoded public key of the Event's creator.
+	Creator string
+	// ConsensusTimestamp is the median of the times at which the creators of
+	// the famous witnesses of RoundReceived first received the Event.
+	ConsensusTimestamp int64
+	// RoundReceived is the round in which the Event was received by all the
+	// famous witnesses.
+	RoundReceived int
+	// Position is the index of the Event in the total order of consensus
+	// Events, starting at 0.
+	Position int
+}
+
+// ConsensusEventCallback is called by the Hashgraph for every Event that
+// reaches consensus, in consensus order.
+type ConsensusEventCallback func(event ConsensusEvent)
+
+// consensusTimestamp returns the consensus timestamp of Event x. For every
+// famous witness w of the round-received, the creator of w first received x
+// when it created its first descendant of x, which is a self-ancestor of w. The
+// consensus timestamp is the median of the timestamps of those descendants. It
+// falls back to the Frame timestamp if the coordinates of x are not available,
+// which is the case for Events that were loaded from a Frame.
+func (h *Hashgraph) consensusTimestamp(x string, round *RoundInfo, frame *Frame) int64 {
+	ex, err := h.Store.GetEvent(x)
+	if err != nil || ex.firstDescendants == nil {
+		return frame.Timestamp
+	}
+
+	timestamps := []int64{}
+	for _, fw := range round.FamousWitnesses() {
+		w, err := h.Store.GetEvent(fw)
+		if err != nil {
+			return frame.Timestamp
+		}
+
+		fd, ok := ex.firstDescendants[w.Creator()]
+		if !ok || fd.Index > w.Index() {
+			return frame.Timestamp
+		}
+
+		d, err := h.Store.GetEvent(fd.Hash)
+		if err != nil {
+			return frame.Timestamp
+		}
+
+		timestamps = append(timestamps, d.Timestamp())
+	}
+
+	return common.Median(timestamps)
+}
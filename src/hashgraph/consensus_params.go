@@ -0,0 +1,119 @@
+package hashgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ConsensusParams are the parameters of the fame-voting algorithm. They are
+// part of the genesis configuration of a network, because nodes that use
+// different values decide the fame of witnesses differently, and produce
+// different Blocks.
+type ConsensusParams struct {
+	// CoinRoundFreq is the frequency of coin rounds. Every CoinRoundFreq-th
+	// voting round, witnesses that do not strongly see a supermajority vote
+	// with the middle bit of their hash. It must be greater than 2.
+	CoinRoundFreq int `json:"coin_round_freq"`
+
+	// MaxVotingRounds is the maximum number of rounds during which the fame of
+	// a witness is voted on. A witness that is still undecided after that
+	// many rounds is never decided, which stops consensus instead of risking a
+	// fork. 0 means no limit.
+	MaxVotingRounds int `json:"max_voting_rounds"`
+}
+
+// DefaultConsensusParams returns the ConsensusParams used when a network does
+// not define its own.
+func DefaultConsensusParams() ConsensusParams {
+	return ConsensusParams{
+		CoinRoundFreq:   COIN_ROUND_FREQ,
+		MaxVotingRounds: 0,
+	}
+}
+
+// Validate returns an error if the parameters are not usable.
+func (p ConsensusParams) Validate() error {
+	if p.CoinRoundFreq <= 2 {
+		return fmt.Errorf("coin_round_freq must be greater than 2, not %d", p.CoinRoundFreq)
+	}
+	if p.MaxVotingRounds < 0 {
+		return fmt.Errorf("max_voting_rounds must not be negative, not %d", p.MaxVotingRounds)
+	}
+	return nil
+}
+
+// isCoinRound returns true if the voting round at distance diff from the
+// witness's round is a coin round.
+func (p ConsensusParams) isCoinRound(diff int) bool {
+	return diff%p.CoinRoundFreq == 0
+}
+
+// ReadConsensusParams reads ConsensusParams from a JSON file. Parameters that
+// are not defined in the file keep their default value.
+func ReadConsensusParams(path string) (ConsensusParams, error) {
+	params := DefaultConsensusParams()
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return params, err
+	}
+
+	if err := json.Unmarshal(buf, &params); err != nil {
+		return params, err
+	}
+
+	return params, params.Validate()
+}
+
+// FameStats counts how the fame of witnesses was decided. Only the voting
+// rounds that led to a decision are counted, so the numbers do not depend on
+// how often DecideFame was run.
+type FameStats struct {
+	// DecidedWitnesses is the number of witnesses whose fame was decided.
+	DecidedWitnesses int
+	// VotingRounds maps a number of voting rounds to the number of witnesses
+	// whose fame was decided after that many rounds.
+	VotingRounds map[int]int
+	// TotalVotingRounds is the sum of the voting rounds of all decisions.
+	TotalVotingRounds int
+	// MaxVotingRounds is the largest number of voting rounds that a decision
+	// took.
+	MaxVotingRounds int
+	// CoinRounds is the number of coin rounds that decided witnesses went
+	// through.
+	CoinRounds int
+	// CoinFlips is the number of votes that were cast with the middle bit of a
+	// witness's hash.
+	CoinFlips int
+	// Exhausted is the number of witnesses that were still undecided after
+	// MaxVotingRounds.
+	Exhausted int
+}
+
+// MeanVotingRounds returns the average number of voting rounds that a decision
+// took.
+func (s FameStats) MeanVotingRounds() float64 {
+	if s.DecidedWitnesses == 0 {
+		return 0
+	}
+
+	return float64(s.TotalVotingRounds) / float64(s.DecidedWitnesses)
+}
+
+// recordDecision adds a decision that took diff voting rounds, and the coin
+// flips that were cast in those rounds.
+func (s *FameStats) recordDecision(diff int, params ConsensusParams, coinFlips int) {
+	if s.VotingRounds == nil {
+		s.VotingRounds = make(map[int]int)
+	}
+
+	s.DecidedWitnesses++
+	s.VotingRounds[diff]++
+	s.TotalVotingRounds += diff
+	if diff > s.MaxVotingRounds {
+		s.MaxVotingRounds = diff
+	}
+	s.CoinRounds += diff / params.CoinRoundFreq
+	s.CoinFlips += coinFlips
+}
@@ -0,0 +1,293 @@
+package hashgraph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Kdag-K/kdag/src/common"
+)
+
+// DAGOptions selects the part of the hashgraph that is exported by ExportDAG.
+type DAGOptions struct {
+	// FromRound is the first round whose Events are exported.
+	FromRound int
+	// ToRound is the last round whose Events are exported. A negative value
+	// means the last known round.
+	ToRound int
+	// FromTime and ToTime bound the timestamps of the exported Events, in
+	// seconds since the epoch (Unix time), like Event timestamps. Zero means
+	// no bound.
+	FromTime int64
+	ToTime   int64
+}
+
+// DAG is a compact representation of a window of the hashgraph, annotated with
+// the result of the consensus algorithm. It is meant to be rendered by
+// visualization tools, either directly in JSON, or in Graphviz DOT format
+// through WriteDOT.
+type DAG struct {
+	FromRound int `json:"from_round"`
+	ToRound   int `json:"to_round"`
+	// Participants lists the creators of the Events. DAGEvent.Creator is an
+	// index in this list.
+	Participants []DAGParticipant `json:"participants"`
+	// Events are sorted by round, creator, and index.
+	Events []DAGEvent `json:"events"`
+}
+
+// DAGParticipant identifies the creator of Events.
+type DAGParticipant struct {
+	PubKey  string `json:"pub_key"`
+	Moniker string `json:"moniker,omitempty"`
+}
+
+// DAGEvent is an Event of a DAG. Parents that are not part of the DAG are
+// still referenced by their hash.
+type DAGEvent struct {
+	Hash          string `json:"hash"`
+	Creator       int    `json:"creator"`
+	Index         int    `json:"index"`
+	SelfParent    string `json:"self_parent,omitempty"`
+	OtherParent   string `json:"other_parent,omitempty"`
+	Timestamp     int64  `json:"timestamp"`
+	Transactions  int    `json:"transactions,omitempty"`
+	Round         int    `json:"round"`
+	Witness       bool   `json:"witness,omitempty"`
+	Famous        string `json:"famous,omitempty"`
+	RoundReceived *int   `json:"round_received,omitempty"`
+	Block         *int   `json:"block,omitempty"`
+}
+
+// ExportDAG collects the Events created in a window of rounds, along with their
+// rounds, witness and fame status, round-received, and the Block that contains
+// their transactions. Rounds, Events and Blocks that are no longer in the Store
+// are skipped.
+func ExportDAG(store Store, opts DAGOptions) (*DAG, error) {
+	from := opts.FromRound
+	if from < 0 {
+		from = 0
+	}
+
+	to := opts.ToRound
+	if to < 0 || to > store.LastRound() {
+		to = store.LastRound()
+	}
+
+	dag := &DAG{
+		FromRound:    from,
+		ToRound:      to,
+		Participants: []DAGParticipant{},
+		Events:       []DAGEvent{},
+	}
+
+	creators := make(map[string]int)
+	repertoire := store.RepertoireByPubKey()
+	pubKeys := make([]string, 0, len(repertoire))
+	for pubKey := range repertoire {
+		pubKeys = append(pubKeys, pubKey)
+	}
+	sort.Strings(pubKeys)
+	for i, pubKey := range pubKeys {
+		creators[pubKey] = i
+		dag.Participants = append(dag.Participants, DAGParticipant{
+			PubKey:  pubKey,
+			Moniker: repertoire[pubKey].Moniker,
+		})
+	}
+
+	// Events created in the window are received in the same rounds or later.
+	// Blocks are identified by their RoundReceived.
+	roundReceived := make(map[string]int)
+	for r := from; r <= store.LastRound(); r++ {
+		round, err := store.GetRound(r)
+		if common.IsStore(err, common.KeyNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, e := range round.ReceivedEvents {
+			roundReceived[e] = r
+		}
+	}
+
+	blocks := make(map[int]int)
+	for b := store.LastBlockIndex(); b >= 0; b-- {
+		block, err := store.GetBlock(b)
+		if common.IsStore(err, common.KeyNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if block.RoundReceived() < from {
+			break
+		}
+		blocks[block.RoundReceived()] = b
+	}
+
+	for r := from; r <= to; r++ {
+		round, err := store.GetRound(r)
+		if common.IsStore(err, common.KeyNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for hash, re := range round.CreatedEvents {
+			ev, err := store.GetEvent(hash)
+			if common.IsStore(err, common.KeyNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			if opts.FromTime != 0 && ev.Timestamp() < opts.FromTime {
+				continue
+			}
+			if opts.ToTime != 0 && ev.Timestamp() > opts.ToTime {
+				continue
+			}
+
+			creator, ok := creators[ev.Creator()]
+			if !ok {
+				return nil, fmt.Errorf("Creator of Event %s not in repertoire", hash)
+			}
+
+			de := DAGEvent{
+				Hash:         hash,
+				Creator:      creator,
+				Index:        ev.Index(),
+				SelfParent:   ev.SelfParent(),
+				OtherParent:  ev.OtherParent(),
+				Timestamp:    ev.Timestamp(),
+				Transactions: len(ev.Transactions()),
+				Round:        r,
+				Witness:      re.Witness,
+			}
+
+			if re.Witness {
+				de.Famous = re.Famous.String()
+			}
+
+			if rr, ok := roundReceived[hash]; ok {
+				rr := rr
+				de.RoundReceived = &rr
+				if b, ok := blocks[rr]; ok && de.Transactions+len(ev.InternalTransactions()) > 0 {
+					de.Block = &b
+				}
+			}
+
+			dag.Events = append(dag.Events, de)
+		}
+	}
+
+	sort.Slice(dag.Events, func(i, j int) bool {
+		a, b := dag.Events[i], dag.Events[j]
+		if a.Round != b.Round {
+			return a.Round < b.Round
+		}
+		if a.Creator != b.Creator {
+			return a.Creator < b.Creator
+		}
+		return a.Index < b.Index
+	})
+
+	return dag, nil
+}
+
+// WriteDOT writes the DAG in Graphviz DOT format. Every participant has its own
+// column, and time flows upwards. Witnesses are colored according to their
+// fame, and other-parent edges are dashed.
+func (d *DAG) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("digraph hashgraph {\n")
+	ew.printf("  rankdir=BT;\n")
+	ew.printf("  node [shape=box, style=\"rounded,filled\", fillcolor=white, fontsize=10];\n")
+
+	inDAG := make(map[string]bool)
+	for _, e := range d.Events {
+		inDAG[e.Hash] = true
+	}
+
+	byCreator := make(map[int][]DAGEvent)
+	for _, e := range d.Events {
+		byCreator[e.Creator] = append(byCreator[e.Creator], e)
+	}
+
+	for i, p := range d.Participants {
+		name := p.Moniker
+		if name == "" {
+			name = p.PubKey
+		}
+
+		ew.printf("  subgraph \"cluster_%d\" {\n", i)
+		ew.printf("    label=%q;\n", name)
+		ew.printf("    style=invis;\n")
+
+		for _, e := range byCreator[i] {
+			ew.printf("    %q [label=%q, tooltip=%q, fillcolor=%q];\n",
+				e.Hash,
+				dotLabel(e),
+				dotTooltip(e),
+				dotColor(e))
+		}
+
+		ew.printf("  }\n")
+	}
+
+	for _, e := range d.Events {
+		if inDAG[e.SelfParent] {
+			ew.printf("  %q -> %q;\n", e.SelfParent, e.Hash)
+		}
+		if inDAG[e.OtherParent] {
+			ew.printf("  %q -> %q [style=dashed];\n", e.OtherParent, e.Hash)
+		}
+	}
+
+	ew.printf("}\n")
+
+	return ew.err
+}
+
+func dotLabel(e DAGEvent) string {
+	label := fmt.Sprintf("%d\nr%d", e.Index, e.Round)
+	if e.RoundReceived != nil {
+		label += fmt.Sprintf(" rr%d", *e.RoundReceived)
+	}
+	if e.Block != nil {
+		label += fmt.Sprintf("\nb%d", *e.Block)
+	}
+	return label
+}
+
+func dotTooltip(e DAGEvent) string {
+	return fmt.Sprintf("%s\ntimestamp: %d\ntransactions: %d", e.Hash, e.Timestamp, e.Transactions)
+}
+
+func dotColor(e DAGEvent) string {
+	if !e.Witness {
+		return "white"
+	}
+	switch e.Famous {
+	case common.True.String():
+		return "gold"
+	case common.False.String():
+		return "gray"
+	default:
+		return "lightblue"
+	}
+}
+
+// errWriter keeps the first error returned by a sequence of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -16,6 +16,7 @@ type Frame struct {
 	Roots     map[string]*Root      // Roots on top of which Frame Events can be inserted
 	Events    []*FrameEvent         // Events with RoundReceived = Round
 	PeerSets  map[int][]*peers.Peer // full peer-set history ([round] => Peers)
+	Votes     Votes                 `json:",omitempty"` // votes for pending changes of the validator-set before Round
 	Timestamp int64                 // unix timestamp (median of round-received famous witnesses)
 }
 
@@ -3,9 +3,10 @@ package hashgraph
 import (
 	"fmt"
 	"math"
-	"reflect"
+	"runtime"
 	"sort"
 	"strconv"
+	"sync"
 
 	"github.com/Kdag-K/kdag/src/common"
 	"github.com/Kdag-K/kdag/src/peers"
@@ -21,8 +22,8 @@ const (
 	// Peers rather than hard-coded.
 	ROOT_DEPTH = 10
 
-	// COIN_ROUND_FREQ defines the frequency of coin rounds
-	COIN_ROUND_FREQ = float64(4)
+	// COIN_ROUND_FREQ defines the default frequency of coin rounds
+	COIN_ROUND_FREQ = 4
 )
 
 // Hashgraph is a DAG of Events. It also contains methods to extract a consensus
@@ -39,9 +40,29 @@ type Hashgraph struct {
 	LastCommitedRoundEvents int                    // number of events in round before LastConsensusRound
 	ConsensusTransactions   int                    // number of consensus transactions
 	PendingLoadedEvents     int                    // number of loaded events that are not yet committed
+	InvalidSignatures       int                    // number of block signatures that did not match their block
+	DuplicateSignatures     int                    // number of blocks signed more than once by the same validator, counted once per validator and block
+	NonValidatorSignatures  int                    // number of block signatures from outside the block's validator-set
+	Votes                   Votes                  // votes for pending changes of the validator-set (part of the consensus state)
+	FameStats               FameStats              // how the fame of witnesses was decided
+	params                  ConsensusParams        // fame-voting parameters
+	exhaustedWitnesses      map[string]bool        // witnesses that reached params.MaxVotingRounds undecided
+	duplicateSigners        map[string]bool        // [block index:validator] => already counted in DuplicateSignatures
+	tracer                  *roundTracer           // optional tracing of the consensus methods
 	commitCallback          InternalCommitCallback // commit block callback
+	consensusEventCallback  ConsensusEventCallback // optional consensus event callback
 	topologicalIndex        int                    // counter used to order events in topological order (only local)
 
+	// Incremental consensus. DivideRounds only processes the events inserted
+	// since its last pass. The fame of witnesses can only change when new
+	// witnesses are created, or when a round is processed (which may change
+	// future peer-sets), and events can only be received when a round is
+	// decided, so DecideFame and DecideRoundReceived are skipped by
+	// RunConsensus when they cannot make progress.
+	undividedEvents  []string // events inserted since the last DivideRounds
+	fameChanged      bool     // DecideFame should be run
+	decisionsChanged bool     // DecideRoundReceived should be run
+
 	ancestorCache     *common.LRU
 	selfAncestorCache *common.LRU
 	stronglySeeCache  *common.LRU
@@ -61,24 +82,65 @@ func NewHashgraph(store Store, commitCallback InternalCommitCallback, logger *lo
 		logger = logrus.NewEntry(log)
 	}
 
-	cacheSize := store.CacheSize()
 	hashgraph := Hashgraph{
 		Store:             store,
 		PendingRounds:     NewPendingRoundsCache(),
 		PendingSignatures: NewSigPool(),
 		commitCallback:    commitCallback,
-		ancestorCache:     common.NewLRU(cacheSize, nil),
-		selfAncestorCache: common.NewLRU(cacheSize, nil),
-		stronglySeeCache:  common.NewLRU(cacheSize, nil),
-		roundCache:        common.NewLRU(cacheSize, nil),
-		timestampCache:    common.NewLRU(cacheSize, nil),
-		witnessCache:      common.NewLRU(cacheSize, nil),
+		params:            DefaultConsensusParams(),
+		ancestorCache:     newConsensusCache(store),
+		selfAncestorCache: newConsensusCache(store),
+		stronglySeeCache:  newConsensusCache(store),
+		roundCache:        newConsensusCache(store),
+		timestampCache:    newConsensusCache(store),
+		witnessCache:      newConsensusCache(store),
+		Votes:             make(Votes),
 		logger:            logger,
 	}
 
 	return &hashgraph
 }
 
+// newConsensusCache creates one of the caches of consensus computations, with
+// the same limits as the caches of the store.
+func newConsensusCache(store Store) *common.LRU {
+	return common.NewSizedLRU(store.CacheSize(), store.CacheBytes(), consensusEntryCost, nil)
+}
+
+// CacheStats returns the usage statistics of the caches of consensus
+// computations.
+func (h *Hashgraph) CacheStats() map[string]common.LRUStats {
+	return map[string]common.LRUStats{
+		"ancestors":      h.ancestorCache.Stats(),
+		"self_ancestors": h.selfAncestorCache.Stats(),
+		"strongly_see":   h.stronglySeeCache.Stats(),
+		"event_rounds":   h.roundCache.Stats(),
+		"timestamps":     h.timestampCache.Stats(),
+		"witnesses":      h.witnessCache.Stats(),
+	}
+}
+
+// SetConsensusEventCallback sets an optional callback that is called, in
+// consensus order, for every Event that reaches consensus.
+func (h *Hashgraph) SetConsensusEventCallback(callback ConsensusEventCallback) {
+	h.consensusEventCallback = callback
+}
+
+// SetConsensusParams sets the fame-voting parameters. It must be called before
+// any Event is inserted.
+func (h *Hashgraph) SetConsensusParams(params ConsensusParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	h.params = params
+	return nil
+}
+
+// ConsensusParams returns the fame-voting parameters.
+func (h *Hashgraph) ConsensusParams() ConsensusParams {
+	return h.params
+}
+
 // Init sets the initial PeerSet, which also creates the corresponding Roots and
 // updates the Repertoire.
 func (h *Hashgraph) Init(peerSet *peers.PeerSet) error {
@@ -194,17 +256,35 @@ func (h *Hashgraph) _stronglySee(x, y string, peers *peers.PeerSet) (bool, error
 	}
 
 	c := 0
-	for p := range peers.ByPubKey {
+	for p, peer := range peers.ByPubKey {
 		xla, xlaok := ex.lastAncestors[p]
 		yfd, yfdok := ey.firstDescendants[p]
 		if xlaok && yfdok && xla.Index >= yfd.Index {
-			c++
+			c += peer.VotingWeight()
 		}
 	}
 
 	return c >= peers.SuperMajority(), nil
 }
 
+//creatorWeight returns the voting weight of the creator of x in a PeerSet, or 0
+//if the creator does not belong to the PeerSet or x is not found.
+func (h *Hashgraph) creatorWeight(x string, peerSet *peers.PeerSet) int {
+	ex, err := h.Store.GetEvent(x)
+	if err != nil {
+		return 0
+	}
+	return peerSet.WeightOf(ex.Creator())
+}
+
+//weightFunc returns a function that computes the voting weight of the creator
+//of an Event in a PeerSet.
+func (h *Hashgraph) weightFunc(peerSet *peers.PeerSet) func(string) int {
+	return func(x string) int {
+		return h.creatorWeight(x, peerSet)
+	}
+}
+
 func (h *Hashgraph) round(x string) (int, error) {
 	if c, ok := h.roundCache.Get(x); ok {
 		return c.(int), nil
@@ -268,12 +348,12 @@ func (h *Hashgraph) _round(x string) (int, error) {
 			return math.MinInt32, err
 		}
 		if ss {
-			c++
+			c += h.creatorWeight(w, parentRoundPeerSet)
 		}
 	}
 
-	// If there is a super-majority of strongly-seen witnesses, increment the
-	// round
+	// If there is a super-majority (by voting weight) of strongly-seen
+	// witnesses, increment the round
 	if c >= parentRoundPeerSet.SuperMajority() {
 		round++
 	}
@@ -648,29 +728,108 @@ func (h *Hashgraph) InsertEventAndRunConsensus(event *Event, setWireInfo bool) e
 		}
 		return err
 	}
+	return h.RunConsensus()
+}
+
+//RunConsensus calls the consensus methods on the events inserted since the
+//last pass. DecideFame and DecideRoundReceived are skipped when nothing that
+//they depend on has changed, so the cost of a pass does not grow with the
+//number of undetermined events.
+func (h *Hashgraph) RunConsensus() error {
 	if err := h.DivideRounds(); err != nil {
 		h.logger.WithError(err).Errorf("DivideRounds")
 		return err
 	}
-	if err := h.DecideFame(); err != nil {
-		h.logger.WithError(err).Errorf("DecideFame")
-		return err
+	if h.fameChanged {
+		if err := h.DecideFame(); err != nil {
+			h.logger.WithError(err).Errorf("DecideFame")
+			return err
+		}
 	}
-	if err := h.DecideRoundReceived(); err != nil {
-		h.logger.WithError(err).Errorf("DecideRoundReceived")
-		return err
+	if h.decisionsChanged {
+		if err := h.DecideRoundReceived(); err != nil {
+			h.logger.WithError(err).Errorf("DecideRoundReceived")
+			return err
+		}
 	}
 	if err := h.ProcessDecidedRounds(); err != nil {
 		h.logger.WithError(err).Errorf("ProcessDecidedRounds")
 		return err
 	}
+	if err := h.traceBlockedEvents(); err != nil {
+		h.logger.WithError(err).Errorf("traceBlockedEvents")
+		return err
+	}
 	return nil
 }
 
+//InsertEventsAndRunConsensus inserts a batch of Events, given in topological
+//order, and runs the consensus methods once for the whole batch. The signatures
+//of all the Events are verified in parallel before insertion. Events that are
+//already known are skipped, and insertion stops at the first invalid Event, but
+//the Events inserted until then are still processed by consensus.
+//
+//Peer-set changes take effect a fixed number of rounds after the corresponding
+//block is committed, so the consensus methods are also run whenever an Event of
+//the batch opens a new round. That way, blocks are not committed later, in
+//terms of rounds, than if the Events were inserted one by one.
+//
+//It returns a slice with the same length as events, where the Events that were
+//not inserted are nil.
+func (h *Hashgraph) InsertEventsAndRunConsensus(events []*Event, setWireInfo bool) ([]*Event, error) {
+	inserted := make([]*Event, len(events))
+
+	verifyErrors := h.verifyEvents(events)
+
+	var insertErr error
+	for i, event := range events {
+		if verifyErrors[i] != nil {
+			insertErr = verifyErrors[i]
+			break
+		}
+
+		if err := h.insertEvent(event, setWireInfo); err != nil {
+			if IsNormalSelfParentError(err) {
+				continue
+			}
+			h.logger.WithError(err).Errorf("InsertEvent")
+			insertErr = err
+			break
+		}
+
+		inserted[i] = event
+
+		lastRound := h.Store.LastRound()
+		if err := h.DivideRounds(); err != nil {
+			h.logger.WithError(err).Errorf("DivideRounds")
+			return inserted, err
+		}
+		if h.Store.LastRound() > lastRound {
+			if err := h.RunConsensus(); err != nil {
+				return inserted, err
+			}
+		}
+	}
+
+	if err := h.RunConsensus(); err != nil {
+		return inserted, err
+	}
+
+	return inserted, insertErr
+}
+
 //InsertEvent attempts to insert an Event in the DAG. It verifies the signature,
 //checks the ancestors are known, and prevents the introduction of forks.
 func (h *Hashgraph) InsertEvent(event *Event, setWireInfo bool) error {
-	//verify signature
+	if err := h.verifyEvent(event); err != nil {
+		return err
+	}
+	return h.insertEvent(event, setWireInfo)
+}
+
+//verifyEvent checks the signature of an Event and of its internal
+//transactions.
+func (h *Hashgraph) verifyEvent(event *Event) error {
 	if ok, err := event.Verify(); !ok {
 		if err != nil {
 			return err
@@ -685,7 +844,38 @@ func (h *Hashgraph) InsertEvent(event *Event, setWireInfo bool) error {
 
 		return fmt.Errorf("Invalid Event signature %s", event.Hex())
 	}
+	return nil
+}
+
+//verifyEvents calls verifyEvent on a batch of Events in parallel, and returns
+//the corresponding errors.
+func (h *Hashgraph) verifyEvents(events []*Event) []error {
+	errs := make([]error, len(events))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = h.verifyEvent(events[i])
+			}
+		}()
+	}
+
+	for i := range events {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
 
+	return errs
+}
+
+//insertEvent inserts an Event whose signature was already verified.
+func (h *Hashgraph) insertEvent(event *Event, setWireInfo bool) error {
 	// checkSelfParent can return normal errors (expected when the hasghraph is
 	// accessed by multiple concurrent go-routines). Normal errors are only
 	// logged at the Trace level, which helps to keep logs clean in normal
@@ -736,6 +926,7 @@ func (h *Hashgraph) InsertEvent(event *Event, setWireInfo bool) error {
 	}
 
 	h.UndeterminedEvents = append(h.UndeterminedEvents, event.Hex())
+	h.undividedEvents = append(h.undividedEvents, event.Hex())
 
 	if event.IsLoaded() {
 		h.PendingLoadedEvents++
@@ -801,11 +992,12 @@ func (h *Hashgraph) InsertFrameEvent(frameEvent *FrameEvent) error {
 	return nil
 }
 
-//DivideRounds assigns a Round and LamportTimestamp to Events, and flags them as
-//witnesses if necessary. Pushes Rounds in the PendingRounds queue if necessary.
+//DivideRounds assigns a Round and LamportTimestamp to the Events inserted since
+//the last call, and flags them as witnesses if necessary. Pushes Rounds in the
+//PendingRounds queue if necessary.
 func (h *Hashgraph) DivideRounds() error {
 
-	for _, hash := range h.UndeterminedEvents {
+	for _, hash := range h.undividedEvents {
 		ev, err := h.Store.GetEvent(hash)
 		if err != nil {
 			return err
@@ -844,6 +1036,10 @@ func (h *Hashgraph) DivideRounds() error {
 				return err
 			}
 
+			if witness {
+				h.fameChanged = true
+			}
+
 			roundInfo.AddCreatedEvent(hash, witness)
 
 			err = h.Store.SetRound(roundNumber, roundInfo)
@@ -867,6 +1063,8 @@ func (h *Hashgraph) DivideRounds() error {
 		}
 	}
 
+	h.undividedEvents = nil
+
 	return nil
 }
 
@@ -900,8 +1098,26 @@ func (h *Hashgraph) DecideFame() error {
 			if rRoundInfo.IsDecided(x) {
 				continue
 			}
+
+			lastVotingRound := h.Store.LastRound()
+			capped := false
+			if max := h.params.MaxVotingRounds; max > 0 && roundIndex+max < lastVotingRound {
+				lastVotingRound = roundIndex + max
+				capped = true
+			}
+
+			var trace *WitnessTrace
+			if h.tracer != nil {
+				ex, err := h.Store.GetEvent(x)
+				if err != nil {
+					return err
+				}
+				trace = h.tracer.startVoting(roundIndex, x, ex.Creator())
+			}
+
+			coinFlips := 0
 		VOTE_LOOP:
-			for j := roundIndex + 1; j <= h.Store.LastRound(); j++ {
+			for j := roundIndex + 1; j <= lastVotingRound; j++ {
 				jRoundInfo, err := h.Store.GetRound(j)
 				if err != nil {
 					return err
@@ -920,6 +1136,7 @@ func (h *Hashgraph) DecideFame() error {
 							return err
 						}
 						setVote(votes, y, x, ycx)
+						trace.vote(VoteTrace{Round: j, Voter: y, Vote: ycx})
 					} else {
 						jPrevRoundInfo, err := h.Store.GetRound(j - 1)
 						if err != nil {
@@ -944,14 +1161,15 @@ func (h *Hashgraph) DecideFame() error {
 							}
 						}
 
-						//Collect votes from these witnesses.
+						//Collect votes from these witnesses, weighted by the
+						//voting weight of their creators.
 						yays := 0
 						nays := 0
 						for _, w := range ssWitnesses {
 							if votes[w][x] {
-								yays++
+								yays += h.creatorWeight(w, jPrevPeerSet)
 							} else {
-								nays++
+								nays += h.creatorWeight(w, jPrevPeerSet)
 							}
 						}
 						v := false
@@ -961,30 +1179,47 @@ func (h *Hashgraph) DecideFame() error {
 							t = yays
 						}
 
+						vt := VoteTrace{Round: j, Voter: y, Vote: v, Yays: yays, Nays: nays}
+
 						//normal round
-						if math.Mod(float64(diff), COIN_ROUND_FREQ) > 0 {
+						if !h.params.isCoinRound(diff) {
 							if t >= jPeerSet.SuperMajority() {
 								rRoundInfo.SetFame(x, v)
 								setVote(votes, y, x, v)
+								h.FameStats.recordDecision(diff, h.params, coinFlips)
+								trace.vote(vt)
+								trace.decide(v, diff)
 								break VOTE_LOOP //break out of j loop
 							} else {
 								setVote(votes, y, x, v)
 							}
 						} else { //coin round
+							vt.CoinRound = true
 							if t >= jPeerSet.SuperMajority() {
 								setVote(votes, y, x, v)
 							} else {
-								setVote(votes, y, x, middleBit(y)) //middle bit of y's hash
+								vt.Vote = middleBit(y) //middle bit of y's hash
+								vt.CoinFlip = true
+								setVote(votes, y, x, vt.Vote)
+								coinFlips++
 							}
 						}
+						trace.vote(vt)
 					}
 				}
 			}
+
+			if capped && !rRoundInfo.IsDecided(x) {
+				h.exhaustWitness(x, roundIndex)
+				trace.exhaust()
+			}
 		}
 
-		if rRoundInfo.WitnessesDecided(rPeerSet) {
+		decided := rRoundInfo.WitnessesDecided(rPeerSet, h.weightFunc(rPeerSet))
+		if decided {
 			decidedRounds = append(decidedRounds, roundIndex)
 		}
+		h.tracer.decideRound(roundIndex, rRoundInfo, rPeerSet, h.weightFunc(rPeerSet), decided)
 
 		err = h.Store.SetRound(roundIndex, rRoundInfo)
 		if err != nil {
@@ -992,6 +1227,11 @@ func (h *Hashgraph) DecideFame() error {
 		}
 	}
 
+	if len(decidedRounds) > 0 {
+		h.decisionsChanged = true
+	}
+	h.fameChanged = false
+
 	h.PendingRounds.Update(decidedRounds)
 	return nil
 }
@@ -1038,7 +1278,7 @@ func (h *Hashgraph) DecideRoundReceived() error {
 				below this round are either already committed or will be
 				received later, so just continue through the i loop.
 			*/
-			if !(tr.WitnessesDecided(tPeers)) {
+			if !(tr.WitnessesDecided(tPeers, h.weightFunc(tPeers))) {
 				if h.roundLowerBound == nil || *h.roundLowerBound < i {
 					break
 				} else {
@@ -1047,8 +1287,9 @@ func (h *Hashgraph) DecideRoundReceived() error {
 			}
 
 			fws := tr.FamousWitnesses()
-			//set of famous witnesses that see x
+			//set of famous witnesses that see x, and their voting weight
 			s := []string{}
+			sWeight := 0
 			for _, w := range fws {
 				see, err := h.see(w, x)
 				if err != nil {
@@ -1056,10 +1297,11 @@ func (h *Hashgraph) DecideRoundReceived() error {
 				}
 				if see {
 					s = append(s, w)
+					sWeight += h.creatorWeight(w, tPeers)
 				}
 			}
 
-			if len(s) == len(fws) && len(s) >= tPeers.SuperMajority() {
+			if len(s) == len(fws) && sWeight >= tPeers.SuperMajority() {
 				received = true
 
 				ex, err := h.Store.GetEvent(x)
@@ -1091,6 +1333,7 @@ func (h *Hashgraph) DecideRoundReceived() error {
 	}
 
 	h.UndeterminedEvents = newUndeterminedEvents
+	h.decisionsChanged = false
 
 	return nil
 }
@@ -1112,6 +1355,7 @@ func (h *Hashgraph) ProcessDecidedRounds() error {
 		//round, we should NEVER process a decided round before all the earlier
 		//rounds are processed.
 		if !r.Decided {
+			h.traceWaitingRounds(r.Index)
 			break
 		}
 
@@ -1137,11 +1381,23 @@ func (h *Hashgraph) ProcessDecidedRounds() error {
 
 		if len(frame.Events) > 0 {
 			for _, e := range frame.Events {
+				position := h.Store.ConsensusEventsCount()
+
 				err := h.Store.AddConsensusEvent(e.Core)
 				if err != nil {
 					return err
 				}
 
+				if h.consensusEventCallback != nil {
+					h.consensusEventCallback(ConsensusEvent{
+						Hash:               e.Core.Hex(),
+						Creator:            e.Core.Creator(),
+						ConsensusTimestamp: h.consensusTimestamp(e.Core.Hex(), round, frame),
+						RoundReceived:      r.Index,
+						Position:           position,
+					})
+				}
+
 				h.ConsensusTransactions += len(e.Core.Transactions())
 
 				if e.Core.IsLoaded() {
@@ -1174,6 +1430,11 @@ func (h *Hashgraph) ProcessDecidedRounds() error {
 		}
 
 		processedRounds = append(processedRounds, r.Index)
+		h.tracer.process(r.Index)
+
+		//committing a block may change the peer-sets of future rounds, which
+		//affects the fame of their witnesses.
+		h.fameChanged = true
 
 		if h.LastConsensusRound == nil || r.Index > *h.LastConsensusRound {
 			h.setLastConsensusRound(r.Index)
@@ -1283,6 +1544,7 @@ func (h *Hashgraph) GetFrame(roundReceived int) (*Frame, error) {
 		Roots:     roots,
 		Events:    events,
 		PeerSets:  allPeerSets,
+		Votes:     h.Votes.Copy(),
 		Timestamp: frameTimestamp,
 	}
 
@@ -1320,7 +1582,8 @@ func (h *Hashgraph) ProcessSigPool() error {
 			continue
 		}
 
-		//check if validator belongs to list of participants
+		//check if validator belongs to list of participants. The PeerSet of a
+		//round never changes, so the signature will never become valid.
 		if _, ok := peerSet.ByPubKey[bs.ValidatorHex()]; !ok {
 			h.logger.WithFields(logrus.Fields{
 				"index":     bs.Index,
@@ -1329,6 +1592,28 @@ func (h *Hashgraph) ProcessSigPool() error {
 				"peers":     peerSet.Peers,
 			}).Warning("Verifying Block signature. Validator does not belong to Block's PeerSet")
 
+			h.NonValidatorSignatures++
+			h.PendingSignatures.Remove(bs.Key())
+			continue
+		}
+
+		//a validator's own signature is already set on the block when it comes
+		//back through the pool; any other signature from the same validator is
+		//a duplicate. ECDSA signatures differ every time a block is signed, so
+		//a validator that signs a block again, after a restart for example,
+		//produces a new signature. Duplicates are therefore counted once per
+		//validator and block.
+		if sig, ok := block.Signatures[bs.ValidatorHex()]; ok {
+			if sig != bs.Signature {
+				h.logger.WithFields(logrus.Fields{
+					"index":     bs.Index,
+					"validator": bs.ValidatorHex(),
+				}).Warning("Verifying Block signature. Duplicate signature")
+
+				h.countDuplicateSignature(bs)
+			}
+
+			h.PendingSignatures.Remove(bs.Key())
 			continue
 		}
 
@@ -1347,6 +1632,9 @@ func (h *Hashgraph) ProcessSigPool() error {
 				"validator": peerSet.ByPubKey[bs.ValidatorHex()],
 				"block":     string(bytesBlock),
 			}).Warning("Verifying Block signature. Invalid signature")
+
+			h.InvalidSignatures++
+			h.PendingSignatures.Remove(bs.Key())
 			continue
 		}
 
@@ -1371,6 +1659,23 @@ func (h *Hashgraph) ProcessSigPool() error {
 	return nil
 }
 
+// countDuplicateSignature increments DuplicateSignatures the first time that a
+// validator signs a block more than once.
+func (h *Hashgraph) countDuplicateSignature(bs BlockSignature) {
+	key := fmt.Sprintf("%d:%s", bs.Index, bs.ValidatorHex())
+
+	if h.duplicateSigners[key] {
+		return
+	}
+
+	if h.duplicateSigners == nil {
+		h.duplicateSigners = make(map[string]bool)
+	}
+
+	h.duplicateSigners[key] = true
+	h.DuplicateSignatures++
+}
+
 /*
 SetAnchorBlock sets the AnchorBlock index if the proposed block has collected
 enough signatures (+1/3) and is above the current AnchorBlock. The AnchorBlock
@@ -1384,7 +1689,14 @@ func (h *Hashgraph) SetAnchorBlock(block *Block) error {
 		return err
 	}
 
-	if len(block.Signatures) > peerSet.TrustCount() &&
+	//signatures are verified before being added to the block, so only their
+	//weight is counted here.
+	signedWeight := 0
+	for validator := range block.Signatures {
+		signedWeight += peerSet.WeightOf(validator)
+	}
+
+	if signedWeight > peerSet.TrustCount() &&
 		(h.AnchorBlock == nil ||
 			block.Index() > *h.AnchorBlock) {
 
@@ -1392,6 +1704,7 @@ func (h *Hashgraph) SetAnchorBlock(block *Block) error {
 		h.logger.WithFields(logrus.Fields{
 			"block_index": block.Index(),
 			"signatures":  len(block.Signatures),
+			"weight":      signedWeight,
 			"trustCount":  peerSet.TrustCount(),
 		}).Debug("Setting AnchorBlock")
 	} else {
@@ -1404,6 +1717,7 @@ func (h *Hashgraph) SetAnchorBlock(block *Block) error {
 		h.logger.WithFields(logrus.Fields{
 			"index":        block.Index(),
 			"sigs":         len(block.Signatures),
+			"weight":       signedWeight,
 			"trust_count":  peerSet.TrustCount(),
 			"anchor_block": msg,
 		}).Debug("Block is not a suitable Anchor")
@@ -1440,16 +1754,19 @@ func (h *Hashgraph) Reset(block *Block, frame *Frame) error {
 	h.AnchorBlock = nil
 
 	h.UndeterminedEvents = []string{}
+	h.undividedEvents = nil
+	h.fameChanged = true
+	h.decisionsChanged = true
 	h.PendingRounds = NewPendingRoundsCache()
 	h.PendingLoadedEvents = 0
 	h.topologicalIndex = 0
+	h.Votes = frame.Votes.Copy()
 
-	cacheSize := h.Store.CacheSize()
-	h.ancestorCache = common.NewLRU(cacheSize, nil)
-	h.selfAncestorCache = common.NewLRU(cacheSize, nil)
-	h.stronglySeeCache = common.NewLRU(cacheSize, nil)
-	h.roundCache = common.NewLRU(cacheSize, nil)
-	h.witnessCache = common.NewLRU(cacheSize, nil)
+	h.ancestorCache = newConsensusCache(h.Store)
+	h.selfAncestorCache = newConsensusCache(h.Store)
+	h.stronglySeeCache = newConsensusCache(h.Store)
+	h.roundCache = newConsensusCache(h.Store)
+	h.witnessCache = newConsensusCache(h.Store)
 
 	//Initialize new Roots
 	if err := h.Store.Reset(frame); err != nil {
@@ -1505,7 +1822,7 @@ func (h *Hashgraph) Bootstrap() error {
 		badgerStore.inmemStore.SetPeerSet(0, peerSet)
 
 		// Retrieve the Events from the underlying DB, in batches of 100, and
-		// insert them sequentially into the hashgraph.
+		// insert them into the hashgraph batch by batch.
 		index := 0
 		batchSize := 100
 		for {
@@ -1515,10 +1832,8 @@ func (h *Hashgraph) Bootstrap() error {
 			}
 
 			// Insert the Events in the Hashgraph
-			for _, e := range topologicalEvents {
-				if err := h.InsertEventAndRunConsensus(e, true); err != nil {
-					return err
-				}
+			if _, err := h.InsertEventsAndRunConsensus(topologicalEvents, true); err != nil {
+				return err
 			}
 
 			// ProcessSigPool
@@ -1542,10 +1857,86 @@ func (h *Hashgraph) Bootstrap() error {
 //ReadWireInfo converts a WireEvent to an Event by replacing int IDs with the
 //corresponding public keys.
 func (h *Hashgraph) ReadWireInfo(we WireEvent) (*Event, error) {
+	return h.readWireInfo(we, nil)
+}
+
+//ReadWireEvents converts a batch of WireEvents, as contained in a SyncResponse,
+//to Events. Contrary to ReadWireInfo, parents are also looked up among the
+//previous Events of the batch, so the whole batch can be converted before it is
+//inserted. In case of error, it returns the Events converted until then.
+//
+//Creators are resolved against the current repertoire, so a batch that
+//contains the Events of a validator that joins within the batch can only be
+//converted in parts; cf. InsertWireEventsAndRunConsensus.
+func (h *Hashgraph) ReadWireEvents(wireEvents []WireEvent) ([]*Event, error) {
+	return h.readWireEvents(wireEvents, make(map[wireEventKey]string))
+}
+
+//InsertWireEventsAndRunConsensus converts a batch of WireEvents, as contained in
+//a SyncResponse, and inserts them with InsertEventsAndRunConsensus. When an
+//Event cannot be converted, typically because its creator is not in the
+//repertoire yet, the Events converted until then are inserted first, which can
+//commit the block that adds the creator to a peer-set, and the conversion
+//resumes from that Event. That way, a batch can contain the join of a new
+//validator followed by its first Events.
+//
+//It returns a slice of the inserted Events, in the same order as wireEvents,
+//where the Events that were not inserted are nil. The slice is shorter than
+//wireEvents if the batch could not be converted entirely.
+func (h *Hashgraph) InsertWireEventsAndRunConsensus(wireEvents []WireEvent) ([]*Event, error) {
+	inserted := make([]*Event, 0, len(wireEvents))
+	batch := make(map[wireEventKey]string)
+
+	for len(inserted) < len(wireEvents) {
+		events, readErr := h.readWireEvents(wireEvents[len(inserted):], batch)
+		if len(events) == 0 {
+			return inserted, readErr
+		}
+
+		res, err := h.InsertEventsAndRunConsensus(events, false)
+		inserted = append(inserted, res...)
+		if err != nil {
+			return inserted, err
+		}
+	}
+
+	return inserted, nil
+}
+
+func (h *Hashgraph) readWireEvents(wireEvents []WireEvent, batch map[wireEventKey]string) ([]*Event, error) {
+	events := make([]*Event, 0, len(wireEvents))
+
+	for _, we := range wireEvents {
+		ev, err := h.readWireInfo(we, batch)
+		if err != nil {
+			return events, err
+		}
+
+		batch[wireEventKey{we.Body.CreatorID, we.Body.Index}] = ev.Hex()
+		events = append(events, ev)
+	}
+
+	return events, nil
+}
+
+//wireEventKey identifies an Event by creator ID and index.
+type wireEventKey struct {
+	creatorID uint32
+	index     int
+}
+
+func (h *Hashgraph) readWireInfo(we WireEvent, batch map[wireEventKey]string) (*Event, error) {
 	selfParent := ""
 	otherParent := ""
 	var err error
 
+	participantEvent := func(creatorID uint32, creator string, index int) (string, error) {
+		if hash, ok := batch[wireEventKey{creatorID, index}]; ok {
+			return hash, nil
+		}
+		return h.Store.ParticipantEvent(creator, index)
+	}
+
 	creator, ok := h.Store.RepertoireByID()[we.Body.CreatorID]
 	if !ok {
 		return nil, fmt.Errorf("Creator %d not found", we.Body.CreatorID)
@@ -1557,7 +1948,7 @@ func (h *Hashgraph) ReadWireInfo(we WireEvent) (*Event, error) {
 	}
 
 	if we.Body.SelfParentIndex >= 0 {
-		selfParent, err = h.Store.ParticipantEvent(creator.PubKeyString(), we.Body.SelfParentIndex)
+		selfParent, err = participantEvent(we.Body.CreatorID, creator.PubKeyString(), we.Body.SelfParentIndex)
 		if err != nil {
 			return nil, err
 		}
@@ -1569,7 +1960,7 @@ func (h *Hashgraph) ReadWireInfo(we WireEvent) (*Event, error) {
 			return nil, fmt.Errorf("Participant %d not found", we.Body.OtherParentCreatorID)
 		}
 
-		otherParent, err = h.Store.ParticipantEvent(otherParentCreator.PubKeyString(), we.Body.OtherParentIndex)
+		otherParent, err = participantEvent(we.Body.OtherParentCreatorID, otherParentCreator.PubKeyString(), we.Body.OtherParentIndex)
 		if err != nil {
 			return nil, fmt.Errorf("OtherParent (creator: %d, index: %d) not found", we.Body.OtherParentCreatorID, we.Body.OtherParentIndex)
 		}
@@ -1582,6 +1973,7 @@ func (h *Hashgraph) ReadWireInfo(we WireEvent) (*Event, error) {
 		Parents:              []string{selfParent, otherParent},
 		Creator:              creatorBytes,
 		Index:                we.Body.Index,
+		Timestamp:            we.Body.Timestamp,
 
 		selfParentIndex:      we.Body.SelfParentIndex,
 		otherParentCreatorID: we.Body.OtherParentCreatorID,
@@ -1600,36 +1992,46 @@ func (h *Hashgraph) ReadWireInfo(we WireEvent) (*Event, error) {
 //CheckBlock returns an error if the Block does not contain valid signatures
 //from MORE than 1/3 of participants
 func (h *Hashgraph) CheckBlock(block *Block, peerSet *peers.PeerSet) error {
-	psh, err := peerSet.Hash()
+	verification, err := verifyBlockSignatures(block, peerSet)
 	if err != nil {
 		return err
 	}
 
-	if !reflect.DeepEqual(psh, block.PeersHash()) {
-		return fmt.Errorf("Wrong PeerSet")
+	if len(verification.NonValidators) > 0 || len(verification.Invalid) > 0 {
+		h.logger.WithFields(logrus.Fields{
+			"non_validators": verification.NonValidators,
+			"invalid":        verification.Invalid,
+		}).Warning("Verifying Block signatures. Ignoring bad signatures")
 	}
 
-	validSignatures := 0
-	for _, s := range block.GetSignatures() {
-		validatorHex := s.ValidatorHex()
-		if _, ok := peerSet.ByPubKey[validatorHex]; !ok {
-			h.logger.WithFields(logrus.Fields{
-				"validator": validatorHex,
-			}).Warning("Verifying Block signature. Unknown validator")
-			continue
-		}
-		ok, _ := block.Verify(s)
-		if ok {
-			validSignatures++
-		}
+	if !verification.Verified {
+		return fmt.Errorf("Not enough valid signatures: got weight %d, need more than %d", verification.Weight, peerSet.TrustCount())
 	}
 
-	if validSignatures <= peerSet.TrustCount() {
-		return fmt.Errorf("Not enough valid signatures: got %d, need %d", validSignatures, peerSet.TrustCount())
+	h.logger.WithFields(logrus.Fields{
+		"valid_signatures": len(verification.Validators),
+		"weight":           verification.Weight,
+	}).Debug("CheckBlock")
+	return nil
+}
+
+//VerifyBlock checks the signatures of a stored Block against the
+//validator-set that was effective at the Block's RoundReceived, even if some of
+//those validators have since been removed. It reports which validators
+//legitimately signed the Block, and which signatures are invalid or come from
+//outside the validator-set.
+func (h *Hashgraph) VerifyBlock(index int) (*BlockVerification, error) {
+	block, err := h.Store.GetBlock(index)
+	if err != nil {
+		return nil, err
 	}
 
-	h.logger.WithField("valid_signatures", validSignatures).Debug("CheckBlock")
-	return nil
+	peerSet, err := h.Store.GetPeerSet(block.RoundReceived())
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyBlockSignatures(block, peerSet)
 }
 
 /*******************************************************************************
@@ -1666,6 +2068,27 @@ func (h *Hashgraph) setAnchorBlock(i int) {
    Helpers
 *******************************************************************************/
 
+// exhaustWitness records, once, that the fame of a witness could not be decided
+// within params.MaxVotingRounds.
+func (h *Hashgraph) exhaustWitness(x string, round int) {
+	if h.exhaustedWitnesses[x] {
+		return
+	}
+
+	if h.exhaustedWitnesses == nil {
+		h.exhaustedWitnesses = make(map[string]bool)
+	}
+
+	h.exhaustedWitnesses[x] = true
+	h.FameStats.Exhausted++
+
+	h.logger.WithFields(logrus.Fields{
+		"witness":           x,
+		"round":             round,
+		"max_voting_rounds": h.params.MaxVotingRounds,
+	}).Error("Fame of witness not decided within max voting rounds")
+}
+
 func middleBit(ehex string) bool {
 	hash, err := common.DecodeFromString(ehex)
 	if err != nil {
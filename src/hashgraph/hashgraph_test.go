@@ -1,7 +1,9 @@
 package hashgraph
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
@@ -116,7 +118,7 @@ func createHashgraph(db bool, orderedEvents *[]*Event, peerSet *peers.PeerSet, t
 	var store Store
 	if db {
 		var err error
-		store, err = NewBadgerStore(cacheSize, badgerDir, false, nil)
+		store, err = NewBadgerStore(cacheSize, 0, badgerDir, false, false, nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -647,6 +649,49 @@ func TestStronglySee(t *testing.T) {
 	}
 }
 
+func TestWeightedStronglySee(t *testing.T) {
+	h, index := initRoundHashgraph(t)
+
+	peerSet, err := h.Store.GetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//give a weight of 2 to the creators of e0 and e1. The super-majority is
+	//then 4 out of 5.
+	for _, name := range []string{"e0", "e1"} {
+		ev, err := h.Store.GetEvent(index[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		peer := *peerSet.ByPubKey[ev.Creator()]
+		peer.Weight = 2
+		peerSet = peerSet.WithPeerWeight(&peer)
+	}
+
+	if sm := peerSet.SuperMajority(); sm != 4 {
+		t.Fatalf("SuperMajority should be 4, not %d", sm)
+	}
+
+	expected := []ancestryItem{
+		//only seen through the creators of e0 and e1
+		{"e10", "e0", true, false},
+		{"f1", "e0", true, false},
+		//only seen through the creators of e1 and e2
+		{"e21", "e1", false, false},
+	}
+
+	for _, exp := range expected {
+		a, err := h.stronglySee(index[exp.descendant], index[exp.ancestor], peerSet)
+		if err != nil && !exp.err {
+			t.Fatalf("Error computing stronglySee(%s, %s). Err: %v", exp.descendant, exp.ancestor, err)
+		}
+		if a != exp.val {
+			t.Fatalf("weighted stronglySee(%s, %s) should be %v, not %v", exp.descendant, exp.ancestor, exp.val, a)
+		}
+	}
+}
+
 func TestWitness(t *testing.T) {
 	h, index := initRoundHashgraph(t)
 
@@ -911,6 +956,101 @@ func initBlockHashgraph(t *testing.T) (*Hashgraph, []TestNode, map[string]string
 	return hashgraph, nodes, index
 }
 
+func TestVerifyBlock(t *testing.T) {
+	nodes, _, _, peerSet := initHashgraphNodes(n)
+
+	h := NewHashgraph(NewInmemStore(cacheSize), DummyInternalCommitCallback, testLogger(t))
+	h.Init(peerSet)
+
+	//nodes[2] is removed from round 5
+	if err := h.Store.SetPeerSet(5, peerSet.WithRemovedPeer(peerSet.Peers[2])); err != nil {
+		t.Fatal(err)
+	}
+
+	block := NewBlock(0, 2, []byte("framehash"), peerSet.Peers, [][]byte{[]byte("tx")}, []InternalTransaction{}, 0)
+	otherBlock := NewBlock(0, 3, []byte("otherhash"), peerSet.Peers, [][]byte{[]byte("tx")}, []InternalTransaction{}, 0)
+
+	sig0, _ := block.Sign(nodes[0].Key)
+	sig2, _ := block.Sign(nodes[2].Key)
+	block.SetSignature(sig0)
+	block.SetSignature(sig2)
+
+	//signature of another block
+	invalidSig, _ := otherBlock.Sign(nodes[1].Key)
+	block.SetSignature(invalidSig)
+
+	key, _ := bkeys.GenerateECDSAKey()
+	outsiderSig, _ := block.Sign(key)
+	block.SetSignature(outsiderSig)
+
+	if err := h.Store.SetBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("VerifyBlock", func(t *testing.T) {
+		verification, err := h.VerifyBlock(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedValidators := []string{nodes[0].PubHex, nodes[2].PubHex}
+		sort.Strings(expectedValidators)
+
+		if !reflect.DeepEqual(verification.Validators, expectedValidators) {
+			t.Fatalf("Validators should be %v, not %v", expectedValidators, verification.Validators)
+		}
+
+		if !reflect.DeepEqual(verification.Invalid, []string{nodes[1].PubHex}) {
+			t.Fatalf("Invalid should be [%s], not %v", nodes[1].PubHex, verification.Invalid)
+		}
+
+		if !reflect.DeepEqual(verification.NonValidators, []string{outsiderSig.ValidatorHex()}) {
+			t.Fatalf("NonValidators should be [%s], not %v", outsiderSig.ValidatorHex(), verification.NonValidators)
+		}
+
+		if !verification.Verified {
+			t.Fatal("Block should be verified")
+		}
+	})
+
+	t.Run("ProcessSigPool counts bad signatures", func(t *testing.T) {
+		cleanBlock := NewBlock(0, 2, []byte("framehash"), peerSet.Peers, [][]byte{[]byte("tx")}, []InternalTransaction{}, 0)
+		cleanBlock.SetSignature(sig0)
+		if err := h.Store.SetBlock(cleanBlock); err != nil {
+			t.Fatal(err)
+		}
+
+		//nodes[0] signs the block twice more, which only counts as one
+		//duplicate
+		duplicateSig, _ := cleanBlock.Sign(nodes[0].Key)
+		secondDuplicateSig, _ := cleanBlock.Sign(nodes[0].Key)
+
+		for _, bs := range []BlockSignature{sig0, duplicateSig, secondDuplicateSig} {
+			h.PendingSignatures.Add(bs)
+			h.ProcessSigPool()
+		}
+		h.PendingSignatures.Add(invalidSig)
+		h.PendingSignatures.Add(outsiderSig)
+		h.ProcessSigPool()
+
+		if h.DuplicateSignatures != 1 {
+			t.Fatalf("DuplicateSignatures should be 1, not %d", h.DuplicateSignatures)
+		}
+
+		if h.InvalidSignatures != 1 {
+			t.Fatalf("InvalidSignatures should be 1, not %d", h.InvalidSignatures)
+		}
+
+		if h.NonValidatorSignatures != 1 {
+			t.Fatalf("NonValidatorSignatures should be 1, not %d", h.NonValidatorSignatures)
+		}
+
+		if l := h.PendingSignatures.Len(); l != 0 {
+			t.Fatalf("SigPool should be empty, not %d", l)
+		}
+	})
+}
+
 func TestInsertEventsWithBlockSignatures(t *testing.T) {
 	h, nodes, index := initBlockHashgraph(t)
 
@@ -1422,6 +1562,221 @@ func TestDecideRoundReceived(t *testing.T) {
 	}
 }
 
+func TestConsensusEventCallback(t *testing.T) {
+	h, index := initConsensusHashgraph(false, t)
+
+	received := []ConsensusEvent{}
+	h.SetConsensusEventCallback(func(event ConsensusEvent) {
+		received = append(received, event)
+	})
+
+	h.DivideRounds()
+	h.DecideFame()
+	h.DecideRoundReceived()
+	if err := h.ProcessDecidedRounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	consensusEvents := h.Store.ConsensusEvents()
+
+	if l := len(received); l != len(consensusEvents) {
+		t.Fatalf("Callback should be called %d times, not %d", len(consensusEvents), l)
+	}
+
+	for i, ce := range received {
+		if ce.Position != i {
+			t.Fatalf("received[%d].Position should be %d, not %d", i, i, ce.Position)
+		}
+
+		if ce.Hash != consensusEvents[i] {
+			t.Fatalf("received[%d] should be %s, not %s", i, getName(index, consensusEvents[i]), getName(index, ce.Hash))
+		}
+
+		ev, err := h.Store.GetEvent(ce.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ce.Creator != ev.Creator() {
+			t.Fatalf("received[%d].Creator should be %s, not %s", i, ev.Creator(), ce.Creator)
+		}
+
+		if ev.roundReceived == nil || ce.RoundReceived != *ev.roundReceived {
+			t.Fatalf("received[%d].RoundReceived should match the Event's", i)
+		}
+
+		if ce.ConsensusTimestamp < ev.Timestamp() {
+			t.Fatalf("received[%d].ConsensusTimestamp %d should not be before the Event's timestamp %d", i, ce.ConsensusTimestamp, ev.Timestamp())
+		}
+	}
+}
+
+func TestFameStats(t *testing.T) {
+	h, _ := initConsensusHashgraph(false, t)
+
+	h.DivideRounds()
+	if err := h.DecideFame(); err != nil {
+		t.Fatal(err)
+	}
+
+	//Running DecideFame again should not count the same decisions twice
+	if err := h.DecideFame(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := h.FameStats
+
+	if stats.DecidedWitnesses != 9 {
+		t.Fatalf("DecidedWitnesses should be 9, not %d", stats.DecidedWitnesses)
+	}
+
+	total := 0
+	for _, count := range stats.VotingRounds {
+		total += count
+	}
+	if total != stats.DecidedWitnesses {
+		t.Fatalf("VotingRounds should count %d decisions, not %d", stats.DecidedWitnesses, total)
+	}
+
+	if stats.MaxVotingRounds < 2 || stats.MaxVotingRounds >= COIN_ROUND_FREQ {
+		t.Fatalf("MaxVotingRounds should be between 2 and %d, not %d", COIN_ROUND_FREQ-1, stats.MaxVotingRounds)
+	}
+
+	if stats.CoinRounds != 0 || stats.CoinFlips != 0 || stats.Exhausted != 0 {
+		t.Fatalf("There should be no coin rounds and no exhausted witnesses: %+v", stats)
+	}
+}
+
+func TestMaxVotingRounds(t *testing.T) {
+	h, _ := initConsensusHashgraph(false, t)
+
+	if err := h.SetConsensusParams(ConsensusParams{CoinRoundFreq: 2}); err == nil {
+		t.Fatal("SetConsensusParams should reject a CoinRoundFreq of 2")
+	}
+
+	//Fame is never decided in the first voting round
+	if err := h.SetConsensusParams(ConsensusParams{CoinRoundFreq: 4, MaxVotingRounds: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.DivideRounds()
+	h.DecideFame()
+	h.DecideFame()
+
+	if h.FameStats.DecidedWitnesses != 0 {
+		t.Fatalf("No witness should be decided, not %d", h.FameStats.DecidedWitnesses)
+	}
+
+	//Witnesses of rounds 0, 1 and 2 are exhausted
+	if h.FameStats.Exhausted != 9 {
+		t.Fatalf("Exhausted should be 9, not %d", h.FameStats.Exhausted)
+	}
+
+	if len(h.PendingRounds.GetOrderedPendingRounds()) == 0 || h.PendingRounds.GetOrderedPendingRounds()[0].Decided {
+		t.Fatal("The first pending round should not be decided")
+	}
+}
+
+func TestRoundTrace(t *testing.T) {
+	h, _ := initConsensusHashgraph(false, t)
+
+	if _, err := h.GetRoundTrace(0); err == nil {
+		t.Fatal("GetRoundTrace should fail when tracing is disabled")
+	}
+
+	h.SetRoundTracing(10)
+
+	if err := h.RunConsensus(); err != nil {
+		t.Fatal(err)
+	}
+
+	r0, err := h.GetRoundTrace(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r0.Decided || !r0.Processed || r0.PendingReason != "" {
+		t.Fatalf("Round 0 should be decided and processed: %+v", r0)
+	}
+
+	if len(r0.Witnesses) != 3 {
+		t.Fatalf("Round 0 should have 3 witnesses, not %d", len(r0.Witnesses))
+	}
+
+	for _, w := range r0.Witnesses {
+		if w.Famous != common.True.String() {
+			t.Fatalf("Witness %s should be famous, not %s", w.Hash, w.Famous)
+		}
+		if w.VotingRounds != 2 {
+			t.Fatalf("Witness %s should be decided in 2 rounds, not %d", w.Hash, w.VotingRounds)
+		}
+		last := w.Votes[len(w.Votes)-1]
+		if !last.Decisive || last.Round != 2 {
+			t.Fatalf("Last vote on witness %s should be decisive, in round 2: %+v", w.Hash, last)
+		}
+		for _, v := range w.Votes[:len(w.Votes)-1] {
+			if v.Decisive {
+				t.Fatalf("Only the last vote on witness %s should be decisive", w.Hash)
+			}
+		}
+	}
+
+	r3, err := h.GetRoundTrace(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r3.Decided || r3.Processed {
+		t.Fatalf("Round 3 should be pending: %+v", r3)
+	}
+
+	if r3.PendingReason != "fame of 3 witnesses undecided" {
+		t.Fatalf("Round 3 pending reason should be about undecided witnesses, not %q", r3.PendingReason)
+	}
+
+	if r3.BlockedEvents == 0 {
+		t.Fatal("Round 3 should block undetermined events")
+	}
+
+	// The blocked events are recounted at every pass, even when no decision
+	// changes and DecideRoundReceived is skipped
+	undetermined := h.UndeterminedEvents
+	h.UndeterminedEvents = []string{}
+
+	if err := h.RunConsensus(); err != nil {
+		t.Fatal(err)
+	}
+
+	if r3, _ = h.GetRoundTrace(3); r3.BlockedEvents != 0 {
+		t.Fatalf("Round 3 should not block any event, not %d", r3.BlockedEvents)
+	}
+	h.UndeterminedEvents = undetermined
+
+	if _, err := h.GetRoundTrace(100); !common.IsStore(err, common.KeyNotFound) {
+		t.Fatalf("GetRoundTrace of an untraced round should return KeyNotFound, not %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := h.DumpRoundTraces(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	var traces []*RoundTrace
+	if err := json.Unmarshal(b.Bytes(), &traces); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, rt := range traces {
+		if rt.Round != i {
+			t.Fatalf("Dumped trace %d should be for round %d, not %d", i, i, rt.Round)
+		}
+	}
+
+	if len(traces) < 4 {
+		t.Fatalf("At least 4 rounds should be dumped, not %d", len(traces))
+	}
+}
+
 func TestProcessDecidedRounds(t *testing.T) {
 	h, index := initConsensusHashgraph(false, t)
 
@@ -1524,6 +1879,83 @@ func TestProcessDecidedRounds(t *testing.T) {
 	}
 }
 
+func TestInsertEventsAndRunConsensus(t *testing.T) {
+	h, index := initConsensusHashgraph(false, t)
+
+	if err := h.RunConsensus(); err != nil {
+		t.Fatal(err)
+	}
+
+	//collect the events in topological order, as in a SyncResponse
+	events := []*Event{}
+	for _, hash := range index {
+		ev, err := h.Store.GetEvent(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].topologicalIndex < events[j].topologicalIndex
+	})
+
+	wireEvents := []WireEvent{}
+	for _, ev := range events {
+		wireEvents = append(wireEvents, ev.ToWire())
+	}
+
+	peerSet, err := h.Store.GetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h2 := NewHashgraph(NewInmemStore(cacheSize), DummyInternalCommitCallback, testLogger(t))
+	if err := h2.Init(peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := h2.ReadWireEvents(wireEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted, err := h2.InsertEventsAndRunConsensus(batch, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, ev := range inserted {
+		if ev == nil || ev.Hex() != events[i].Hex() {
+			t.Fatalf("Event %d should have been inserted", i)
+		}
+	}
+
+	if !reflect.DeepEqual(h2.Store.ConsensusEvents(), h.Store.ConsensusEvents()) {
+		t.Fatalf("ConsensusEvents should be %v, not %v", h.Store.ConsensusEvents(), h2.Store.ConsensusEvents())
+	}
+
+	if h2.Store.LastBlockIndex() != h.Store.LastBlockIndex() {
+		t.Fatalf("LastBlockIndex should be %d, not %d", h.Store.LastBlockIndex(), h2.Store.LastBlockIndex())
+	}
+
+	//known events are skipped
+	batch, err = h2.ReadWireEvents(wireEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted, err = h2.InsertEventsAndRunConsensus(batch, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, ev := range inserted {
+		if ev != nil {
+			t.Fatalf("Event %d should have been skipped", i)
+		}
+	}
+}
+
 func BenchmarkConsensus(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		//we do not want to benchmark the initialization code
@@ -1538,6 +1970,130 @@ func BenchmarkConsensus(b *testing.B) {
 	}
 }
 
+/*
+BenchmarkConsensusBacklog measures the cost of inserting an event and running
+the consensus methods on top of a growing backlog of events. The four peers
+gossip in turn, each event taking the previous one as other-parent, so new
+rounds and witnesses keep forming and DecideFame and DecideRoundReceived keep
+deciding rounds. The cost per event should not depend on the size of the
+backlog.
+*/
+func BenchmarkConsensusBacklog(b *testing.B) {
+	for _, backlog := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("backlog=%d", backlog), func(b *testing.B) {
+			benchmarkConsensusBacklog(backlog, b)
+		})
+	}
+}
+
+func benchmarkConsensusBacklog(backlog int, b *testing.B) {
+	nodes, _, _, peerSet := initHashgraphNodes(4)
+
+	events := make([]*Event, 0, backlog+b.N)
+	last := make([]string, len(nodes))
+	previous := ""
+	for i := 0; i < backlog+b.N; i++ {
+		creator := i % len(nodes)
+		event := NewEvent(nil,
+			nil,
+			nil,
+			[]string{last[creator], previous},
+			nodes[creator].PubBytes,
+			i/len(nodes))
+		event.Sign(nodes[creator].Key)
+		last[creator] = event.Hex()
+		previous = event.Hex()
+		events = append(events, event)
+	}
+
+	h := NewHashgraph(NewInmemStore(len(events)), DummyInternalCommitCallback, testLogger(b))
+	if err := h.Init(peerSet); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, e := range events[:backlog] {
+		if err := h.InsertEventAndRunConsensus(e, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+
+	for _, e := range events[backlog:] {
+		if err := h.InsertEventAndRunConsensus(e, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestExportDAG(t *testing.T) {
+	h, index := initConsensusHashgraph(false, t)
+
+	if err := h.RunConsensus(); err != nil {
+		t.Fatal(err)
+	}
+
+	dag, err := ExportDAG(h.Store, DAGOptions{FromRound: 1, ToRound: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedEvents := 0
+	for r := 1; r <= 2; r++ {
+		round, err := h.Store.GetRound(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedEvents += len(round.CreatedEvents)
+	}
+
+	if l := len(dag.Events); l != expectedEvents {
+		t.Fatalf("DAG should contain %d events, not %d", expectedEvents, l)
+	}
+
+	if l := len(dag.Participants); l != 3 {
+		t.Fatalf("DAG should have 3 participants, not %d", l)
+	}
+
+	events := make(map[string]DAGEvent)
+	for i, e := range dag.Events {
+		if e.Round < 1 || e.Round > 2 {
+			t.Fatalf("%s should not be in the DAG", getName(index, e.Hash))
+		}
+		if i > 0 && e.Round < dag.Events[i-1].Round {
+			t.Fatal("Events should be sorted by round")
+		}
+		events[e.Hash] = e
+	}
+
+	f1 := events[index["f1"]]
+	if !f1.Witness || f1.Famous != common.True.String() {
+		t.Fatalf("f1 should be a famous witness, not %+v", f1)
+	}
+
+	if f1.RoundReceived == nil {
+		t.Fatalf("f1 should have a round-received")
+	}
+
+	var buf bytes.Buffer
+	if err := dag.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph hashgraph {") {
+		t.Fatalf("DOT output should start with the graph declaration")
+	}
+
+	// f1 is in the DAG, but its self-parent e1 is in round 0
+	if !strings.Contains(dot, fmt.Sprintf("%q [", index["f1"])) {
+		t.Fatal("DOT output should declare f1")
+	}
+	if strings.Contains(dot, fmt.Sprintf("%q -> %q", index["e1"], index["f1"])) {
+		t.Fatal("DOT output should not contain edges from events outside the window")
+	}
+}
+
 func TestKnown(t *testing.T) {
 	h, _ := initConsensusHashgraph(false, t)
 
@@ -1952,7 +2508,7 @@ func TestBootstrap(t *testing.T) {
 
 	//Now we want to create a new Hashgraph based on the database of the previous
 	//Hashgraph and see if we can boostrap it to the same state.
-	recycledStore, _ := NewBadgerStore(cacheSize, badgerDir, false, nil)
+	recycledStore, _ := NewBadgerStore(cacheSize, 0, badgerDir, false, false, nil, nil)
 
 	nh := NewHashgraph(recycledStore, DummyInternalCommitCallback, logrus.New().WithField("id", "bootstrapped"))
 
@@ -2590,3 +3146,23 @@ func disp(index map[string]string, events []string) string {
 func create(x int) *int {
 	return &x
 }
+
+func TestConsensusCacheBytes(t *testing.T) {
+	store := NewSizedInmemStore(100, 10*consensusEntryOverhead)
+	hashgraph := NewHashgraph(store, DummyInternalCommitCallback, testLogger(t))
+
+	for i := 0; i < 20; i++ {
+		hashgraph.ancestorCache.Add(i, true)
+	}
+
+	stats := hashgraph.CacheStats()["ancestors"]
+	if stats.Items != 10 {
+		t.Fatalf("Ancestor cache should contain 10 items, not %d", stats.Items)
+	}
+	if stats.Bytes != 10*consensusEntryOverhead {
+		t.Fatalf("Ancestor cache should weigh %d bytes, not %d", 10*consensusEntryOverhead, stats.Bytes)
+	}
+	if stats.Evictions != 10 {
+		t.Fatalf("Ancestor cache should have evicted 10 items, not %d", stats.Evictions)
+	}
+}
@@ -0,0 +1,42 @@
+package hashgraph
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto"
+)
+
+// TxLocation records where a transaction was committed, ie. the index of the
+// Block that contains it and its position within the Block's transactions.
+type TxLocation struct {
+	BlockIndex int
+	Position   int
+}
+
+// Marshal produces the JSON encoding of a TxLocation.
+func (l *TxLocation) Marshal() ([]byte, error) {
+	bf := bytes.NewBuffer([]byte{})
+	enc := json.NewEncoder(bf)
+	if err := enc.Encode(l); err != nil {
+		return nil, err
+	}
+	return bf.Bytes(), nil
+}
+
+// Unmarshal parses a JSON encoded TxLocation.
+func (l *TxLocation) Unmarshal(data []byte) error {
+	b := bytes.NewBuffer(data)
+	dec := json.NewDecoder(b) //will read from b
+	if err := dec.Decode(l); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TxHash returns the hex representation of the SHA256 hash of a transaction.
+// It is the key used by the transaction index.
+func TxHash(tx []byte) string {
+	return common.EncodeToString(crypto.SHA256(tx))
+}
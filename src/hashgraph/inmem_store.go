@@ -1,6 +1,7 @@
 package hashgraph
 
 import (
+	"sort"
 	"strconv"
 
 	cm "github.com/Kdag-K/kdag/src/common"
@@ -13,6 +14,7 @@ import (
 // beginning of a hashgraph.
 type InmemStore struct {
 	cacheSize              int
+	cacheBytes             int64
 	eventCache             *cm.LRU          //hash => Event
 	roundCache             *cm.LRU          //round number => Round
 	blockCache             *cm.LRU          //index => Block
@@ -30,12 +32,21 @@ type InmemStore struct {
 // NewInmemStore creates a new InmemStore where all caches are limited by
 // cacheSize items.
 func NewInmemStore(cacheSize int) *InmemStore {
+	return NewSizedInmemStore(cacheSize, 0)
+}
+
+// NewSizedInmemStore creates a new InmemStore where all caches are limited by
+// cacheSize items, and where the event, round, block and frame caches are also
+// limited by an estimated memory footprint of cacheBytes each. A cacheBytes of
+// zero disables the byte limit.
+func NewSizedInmemStore(cacheSize int, cacheBytes int64) *InmemStore {
 	store := &InmemStore{
 		cacheSize:              cacheSize,
-		eventCache:             cm.NewLRU(cacheSize, nil),
-		roundCache:             cm.NewLRU(cacheSize, nil),
-		blockCache:             cm.NewLRU(cacheSize, nil),
-		frameCache:             cm.NewLRU(cacheSize, nil),
+		cacheBytes:             cacheBytes,
+		eventCache:             cm.NewSizedLRU(cacheSize, cacheBytes, eventCost, nil),
+		roundCache:             cm.NewSizedLRU(cacheSize, cacheBytes, roundCost, nil),
+		blockCache:             cm.NewSizedLRU(cacheSize, cacheBytes, blockCost, nil),
+		frameCache:             cm.NewSizedLRU(cacheSize, cacheBytes, frameCost, nil),
 		consensusCache:         cm.NewRollingIndex("ConsensusCache", cacheSize),
 		peerSetCache:           NewPeerSetCache(),
 		participantEventsCache: NewParticipantEventsCache(cacheSize),
@@ -54,6 +65,23 @@ func (s *InmemStore) CacheSize() int {
 	return s.cacheSize
 }
 
+// CacheBytes returns the byte budget that was provided to all the caches that
+// make up the InmemStore.
+func (s *InmemStore) CacheBytes() int64 {
+	return s.cacheBytes
+}
+
+// CacheStats returns the usage statistics of the event, round, block and
+// frame caches.
+func (s *InmemStore) CacheStats() map[string]cm.LRUStats {
+	return map[string]cm.LRUStats{
+		"events": s.eventCache.Stats(),
+		"rounds": s.roundCache.Stats(),
+		"blocks": s.blockCache.Stats(),
+		"frames": s.frameCache.Stats(),
+	}
+}
+
 // GetPeerSet ...
 func (s *InmemStore) GetPeerSet(round int) (*peers.PeerSet, error) {
 	return s.peerSetCache.Get(round)
@@ -262,6 +290,42 @@ func (s *InmemStore) LastBlockIndex() int {
 	return s.lastBlock
 }
 
+// GetTxLocation looks for a transaction in the cached blocks. Blocks that were
+// evicted from the cache are not searched.
+func (s *InmemStore) GetTxLocation(txHash string) (TxLocation, error) {
+	for _, k := range s.blockCache.Keys() {
+		b, ok := s.blockCache.Peek(k)
+		if !ok {
+			continue
+		}
+		block := b.(*Block)
+		for i, tx := range block.Transactions() {
+			if TxHash(tx) == txHash {
+				return TxLocation{BlockIndex: block.Index(), Position: i}, nil
+			}
+		}
+	}
+	return TxLocation{}, cm.NewStoreErr("TxIndex", cm.KeyNotFound, txHash)
+}
+
+// GetBlockIndexesByTime returns the sorted indexes of cached blocks whose
+// timestamp is within [start, end].
+func (s *InmemStore) GetBlockIndexesByTime(start, end int64) ([]int, error) {
+	res := []int{}
+	for _, k := range s.blockCache.Keys() {
+		b, ok := s.blockCache.Peek(k)
+		if !ok {
+			continue
+		}
+		block := b.(*Block)
+		if block.Timestamp() >= start && block.Timestamp() <= end {
+			res = append(res, block.Index())
+		}
+	}
+	sort.Ints(res)
+	return res, nil
+}
+
 // GetFrame ...
 func (s *InmemStore) GetFrame(index int) (*Frame, error) {
 	res, ok := s.frameCache.Get(index)
@@ -286,10 +350,10 @@ func (s *InmemStore) SetFrame(frame *Frame) error {
 func (s *InmemStore) Reset(frame *Frame) error {
 	//Clear all caches
 	s.peerSetCache = NewPeerSetCache()
-	s.eventCache = cm.NewLRU(s.cacheSize, nil)
-	s.roundCache = cm.NewLRU(s.cacheSize, nil)
-	s.blockCache = cm.NewLRU(s.cacheSize, nil)
-	s.frameCache = cm.NewLRU(s.cacheSize, nil)
+	s.eventCache = cm.NewSizedLRU(s.cacheSize, s.cacheBytes, eventCost, nil)
+	s.roundCache = cm.NewSizedLRU(s.cacheSize, s.cacheBytes, roundCost, nil)
+	s.blockCache = cm.NewSizedLRU(s.cacheSize, s.cacheBytes, blockCost, nil)
+	s.frameCache = cm.NewSizedLRU(s.cacheSize, s.cacheBytes, frameCost, nil)
 	s.participantEventsCache = NewParticipantEventsCache(s.cacheSize)
 	s.roots = make(map[string]*Root)
 	s.lastRound = -1
@@ -216,7 +216,7 @@ func TestInmemBlocks(t *testing.T) {
 	}
 	frameHash := []byte("this is the frame hash")
 
-	block := NewBlock(index, roundReceived, frameHash, []*peers.Peer{}, transactions, internalTransactions)
+	block := NewBlock(index, roundReceived, frameHash, []*peers.Peer{}, transactions, internalTransactions, 0)
 
 	sig1, err := block.Sign(participants[0].privKey)
 	if err != nil {
@@ -269,3 +269,27 @@ func TestInmemBlocks(t *testing.T) {
 		}
 	})
 }
+
+func TestInmemCacheBytes(t *testing.T) {
+	// room for two blocks with a 1000-byte transaction each
+	store := NewSizedInmemStore(100, 2*(blockOverhead+1000))
+
+	for i := 0; i < 3; i++ {
+		block := NewBlock(i, i+1, []byte{}, []*peers.Peer{}, [][]byte{make([]byte, 1000)}, []InternalTransaction{}, 0)
+		if err := store.SetBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.GetBlock(0); err == nil {
+		t.Fatal("Block 0 should have been evicted")
+	}
+	if _, err := store.GetBlock(2); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := store.CacheStats()["blocks"]
+	if stats.Items != 2 || stats.Bytes != 2*(blockOverhead+1000) || stats.Evictions != 1 {
+		t.Fatalf("wrong block cache stats: %+v", stats)
+	}
+}
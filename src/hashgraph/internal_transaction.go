@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/Kdag-K/kdag/src/common"
 	"github.com/Kdag-K/kdag/src/crypto"
 	"github.com/Kdag-K/kdag/src/crypto/keys"
 	"github.com/Kdag-K/kdag/src/peers"
@@ -22,6 +26,12 @@ const (
 	PEER_ADD TransactionType = iota
 	// PEER_REMOVE ...
 	PEER_REMOVE
+	// PEER_WEIGHT changes the voting weight of a validator to Peer.Weight
+	PEER_WEIGHT
+	// PEER_ROTATE_KEY replaces the public key of a validator with NewPubKey
+	PEER_ROTATE_KEY
+	// PEER_UPDATE changes the NetAddr and Moniker of a validator
+	PEER_UPDATE
 )
 
 // String ...
@@ -31,6 +41,12 @@ func (t TransactionType) String() string {
 		return "PEER_ADD"
 	case PEER_REMOVE:
 		return "PEER_REMOVE"
+	case PEER_WEIGHT:
+		return "PEER_WEIGHT"
+	case PEER_ROTATE_KEY:
+		return "PEER_ROTATE_KEY"
+	case PEER_UPDATE:
+		return "PEER_UPDATE"
 	default:
 		return "Unknown TransactionType"
 	}
@@ -40,6 +56,27 @@ func (t TransactionType) String() string {
 type InternalTransactionBody struct {
 	Type TransactionType
 	Peer peers.Peer
+
+	// Proposer is the public key of a validator that proposes to remove
+	// another peer with a PEER_REMOVE, or to change the weight of a peer with
+	// a PEER_WEIGHT. It is empty when the transaction is created by the peer
+	// itself. The transaction is then signed by the Proposer instead of the
+	// Peer.
+	Proposer string `json:",omitempty"`
+
+	// Round is the round from which the Peer has held its place in the
+	// validator-set (cf. ValidatorRound) in a PEER_REMOVE or a PEER_WEIGHT.
+	// It prevents the transaction from being replayed
+	// once the Peer has left and rejoined, or once its weight has changed.
+	Round int `json:",omitempty"`
+
+	// Invite is a token, created with NewJoinInvite by an existing validator,
+	// that a PEER_ADD may carry to be authorized by the validators.
+	Invite string `json:",omitempty"`
+
+	// NewPubKey is the public key that replaces the Peer's key in a
+	// PEER_ROTATE_KEY.
+	NewPubKey string `json:",omitempty"`
 }
 
 //Marshal - json encoding of body
@@ -68,6 +105,10 @@ func (i *InternalTransactionBody) Hash() ([]byte, error) {
 type InternalTransaction struct {
 	Body      InternalTransactionBody
 	Signature string
+
+	// RotationSignature is the signature of the body by the new key of a
+	// PEER_ROTATE_KEY, which proves that the validator owns it.
+	RotationSignature string `json:",omitempty"`
 }
 
 // NewInternalTransaction ...
@@ -82,9 +123,50 @@ func NewInternalTransactionJoin(peer peers.Peer) InternalTransaction {
 	return NewInternalTransaction(PEER_ADD, peer)
 }
 
-// NewInternalTransactionLeave ...
-func NewInternalTransactionLeave(peer peers.Peer) InternalTransaction {
-	return NewInternalTransaction(PEER_REMOVE, peer)
+// NewInternalTransactionLeave creates an InternalTransaction by which a
+// validator leaves. round is the round from which the validator has held its
+// place (cf. ValidatorRound).
+func NewInternalTransactionLeave(peer peers.Peer, round int) InternalTransaction {
+	itx := NewInternalTransaction(PEER_REMOVE, peer)
+	itx.Body.Round = round
+	return itx
+}
+
+// NewInternalTransactionRemoval creates an InternalTransaction by which the
+// validator identified by proposer votes to remove another peer. round is the
+// round from which the peer has been a validator (cf. ValidatorRound).
+func NewInternalTransactionRemoval(peer peers.Peer, proposer string, round int) InternalTransaction {
+	itx := NewInternalTransaction(PEER_REMOVE, peer)
+	itx.Body.Proposer = proposer
+	itx.Body.Round = round
+	return itx
+}
+
+// NewInternalTransactionWeight creates an InternalTransaction by which the
+// validator identified by proposer votes to change the voting weight of a
+// validator. round is the round from which the validator has held its place
+// and weight (cf. ValidatorRound).
+func NewInternalTransactionWeight(peer peers.Peer, weight int, proposer string, round int) InternalTransaction {
+	peer.Weight = weight
+	itx := NewInternalTransaction(PEER_WEIGHT, peer)
+	itx.Body.Proposer = proposer
+	itx.Body.Round = round
+	return itx
+}
+
+// NewInternalTransactionRotation creates an InternalTransaction to replace the
+// public key of a validator. It must be signed with both the old key (Sign) and
+// the new key (SignRotation).
+func NewInternalTransactionRotation(peer peers.Peer, newPubKey string) InternalTransaction {
+	itx := NewInternalTransaction(PEER_ROTATE_KEY, peer)
+	itx.Body.NewPubKey = newPubKey
+	return itx
+}
+
+// NewInternalTransactionUpdate creates an InternalTransaction by which a
+// validator announces a new NetAddr or Moniker, given in peer.
+func NewInternalTransactionUpdate(peer peers.Peer) InternalTransaction {
+	return NewInternalTransaction(PEER_UPDATE, peer)
 }
 
 // Marshal ...
@@ -130,9 +212,55 @@ func (t *InternalTransaction) Sign(privKey *ecdsa.PrivateKey) error {
 	return err
 }
 
-// Verify the transaction's signature.
+//SignRotation signs the transaction's body with the new key of a
+//PEER_ROTATE_KEY
+func (t *InternalTransaction) SignRotation(newKey *ecdsa.PrivateKey) error {
+	signBytes, err := t.Body.Hash()
+	if err != nil {
+		return err
+	}
+
+	R, S, err := keys.Sign(newKey, signBytes)
+	if err != nil {
+		return err
+	}
+
+	t.RotationSignature = keys.EncodeSignature(R, S)
+
+	return nil
+}
+
+// Verify the transaction's signature. It is signed by the Proposer, if any, or
+// else by the Peer. Only PEER_REMOVE and PEER_WEIGHT transactions may have a
+// Proposer. A PEER_ROTATE_KEY must also carry a valid signature by the new key.
 func (t *InternalTransaction) Verify() (bool, error) {
+	if (t.Body.Type == PEER_ROTATE_KEY) != (t.Body.NewPubKey != "") {
+		return false, nil
+	}
+
+	if t.Body.Type == PEER_ROTATE_KEY {
+		ok, err := verifyBody(t.Body, t.Body.NewPubKey, t.RotationSignature)
+		if !ok || err != nil {
+			return ok, err
+		}
+	} else if t.RotationSignature != "" {
+		return false, nil
+	}
+
 	pubBytes := t.Body.Peer.PubKeyBytes()
+
+	if t.Body.Proposer != "" {
+		if t.Body.Type != PEER_REMOVE && t.Body.Type != PEER_WEIGHT {
+			return false, nil
+		}
+
+		var err error
+		pubBytes, err = common.DecodeFromString(t.Body.Proposer)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	pubKey := keys.ToPublicKey(pubBytes)
 
 	signBytes, err := t.Body.Hash()
@@ -148,6 +276,31 @@ func (t *InternalTransaction) Verify() (bool, error) {
 	return keys.Verify(pubKey, signBytes, r, s), nil
 }
 
+// verifyBody checks a signature of the body by the given public key.
+func verifyBody(body InternalTransactionBody, pubKeyHex string, signature string) (bool, error) {
+	pubBytes, err := common.DecodeFromString(pubKeyHex)
+	if err != nil {
+		return false, err
+	}
+
+	pubKey := keys.ToPublicKey(pubBytes)
+	if pubKey == nil || pubKey.X == nil {
+		return false, nil
+	}
+
+	signBytes, err := body.Hash()
+	if err != nil {
+		return false, err
+	}
+
+	r, s, err := keys.DecodeSignature(signature)
+	if err != nil {
+		return false, err
+	}
+
+	return keys.Verify(pubKey, signBytes, r, s), nil
+}
+
 // HashString returns a string representation of the body's hash. It is used in
 // node/core as a key in a map to keep track of InternalTransactions as they go
 // through consensus.
@@ -172,6 +325,66 @@ func (t *InternalTransaction) AsRefused() InternalTransactionReceipt {
 	}
 }
 
+/*******************************************************************************
+Invites
+*******************************************************************************/
+
+// inviteHash is the hash signed by an invite to the peer with the given public
+// key, which expires after the given round.
+func inviteHash(pubKey string, expiryRound int) []byte {
+	pubKey = "0X" + strings.TrimPrefix(strings.ToUpper(pubKey), "0X")
+	return crypto.SHA256([]byte(fmt.Sprintf("invite:%s:%d", pubKey, expiryRound)))
+}
+
+// NewJoinInvite creates a token by which the owner of key invites the peer
+// with the given public key to join the validator-set, until the given round.
+// The token is made of the inviter's public key, the expiry round, and the
+// signature, separated by colons.
+func NewJoinInvite(key *ecdsa.PrivateKey, pubKey string, expiryRound int) (string, error) {
+	R, S, err := keys.Sign(key, inviteHash(pubKey, expiryRound))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%d:%s", keys.PublicKeyHex(&key.PublicKey), expiryRound, keys.EncodeSignature(R, S)), nil
+}
+
+// VerifyJoinInvite checks that an invite was created for the peer with the
+// given public key, and returns the public key of the inviter and the round
+// after which the invite expires.
+func VerifyJoinInvite(invite string, pubKey string) (string, int, error) {
+	parts := strings.SplitN(invite, ":", 3)
+	if len(parts) != 3 {
+		return "", 0, fmt.Errorf("Malformed invite")
+	}
+
+	inviterBytes, err := common.DecodeFromString(parts[0])
+	if err != nil {
+		return "", 0, fmt.Errorf("Malformed invite: %s", err)
+	}
+
+	inviter := keys.ToPublicKey(inviterBytes)
+	if inviter == nil || inviter.X == nil {
+		return "", 0, fmt.Errorf("Malformed invite: invalid public key")
+	}
+
+	expiryRound, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, fmt.Errorf("Malformed invite: %s", err)
+	}
+
+	r, s, err := keys.DecodeSignature(parts[2])
+	if err != nil {
+		return "", 0, fmt.Errorf("Malformed invite: %s", err)
+	}
+
+	if !keys.Verify(inviter, inviteHash(pubKey, expiryRound), r, s) {
+		return "", 0, fmt.Errorf("Invalid invite signature")
+	}
+
+	return strings.ToUpper(parts[0]), expiryRound, nil
+}
+
 /*******************************************************************************
 InternalTransactionReceipt
 *******************************************************************************/
@@ -0,0 +1,175 @@
+package hashgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+func TestVerifyInternalTransaction(t *testing.T) {
+	peerKey, _ := keys.GenerateECDSAKey()
+	proposerKey, _ := keys.GenerateECDSAKey()
+
+	peer := peers.NewPeer(keys.PublicKeyHex(&peerKey.PublicKey), "", "peer")
+	proposer := keys.PublicKeyHex(&proposerKey.PublicKey)
+
+	verify := func(itx InternalTransaction) bool {
+		ok, err := itx.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	//Signed by the peer itself
+	leave := NewInternalTransactionLeave(*peer, 0)
+	leave.Sign(peerKey)
+	if !verify(leave) {
+		t.Fatal("PEER_REMOVE signed by the peer should verify")
+	}
+
+	//Signed by the proposer
+	removal := NewInternalTransactionRemoval(*peer, proposer, 0)
+	removal.Sign(proposerKey)
+	if !verify(removal) {
+		t.Fatal("PEER_REMOVE signed by the proposer should verify")
+	}
+
+	//A proposal signed by the peer instead of the proposer is invalid
+	forged := NewInternalTransactionRemoval(*peer, proposer, 0)
+	forged.Sign(peerKey)
+	if verify(forged) {
+		t.Fatal("PEER_REMOVE with a proposer should not verify with the peer's signature")
+	}
+
+	//PEER_WEIGHT can be proposed by another validator
+	weight := NewInternalTransactionWeight(*peer, 5, proposer, 0)
+	weight.Sign(proposerKey)
+	if !verify(weight) {
+		t.Fatal("PEER_WEIGHT signed by the proposer should verify")
+	}
+
+	//Only PEER_REMOVE and PEER_WEIGHT can have a proposer
+	add := NewInternalTransactionJoin(*peer)
+	add.Body.Proposer = proposer
+	add.Sign(proposerKey)
+	if verify(add) {
+		t.Fatal("PEER_ADD with a proposer should not verify")
+	}
+
+	//The proposer does not change the hash of transactions without one
+	noProposer := NewInternalTransactionLeave(*peer, 0)
+	noProposer.Body.Proposer = ""
+	if noProposer.HashString() != leave.HashString() {
+		t.Fatal("Hashes of PEER_REMOVE without proposer should match")
+	}
+}
+
+func TestJoinInvite(t *testing.T) {
+	inviterKey, _ := keys.GenerateECDSAKey()
+	peerKey, _ := keys.GenerateECDSAKey()
+	otherKey, _ := keys.GenerateECDSAKey()
+
+	inviter := keys.PublicKeyHex(&inviterKey.PublicKey)
+	peer := keys.PublicKeyHex(&peerKey.PublicKey)
+	other := keys.PublicKeyHex(&otherKey.PublicKey)
+
+	invite, err := NewJoinInvite(inviterKey, peer, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, expiry, err := VerifyJoinInvite(invite, peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != inviter {
+		t.Fatalf("Inviter should be %s, not %s", inviter, res)
+	}
+	if expiry != 42 {
+		t.Fatalf("Invite should expire at round 42, not %d", expiry)
+	}
+
+	//The public key is not case sensitive
+	if _, _, err := VerifyJoinInvite(invite, strings.ToLower(peer)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := VerifyJoinInvite(invite, other); err == nil {
+		t.Fatal("Invite should not be valid for another peer")
+	}
+
+	//The expiry round is signed
+	parts := strings.SplitN(invite, ":", 3)
+	if _, _, err := VerifyJoinInvite(parts[0]+":1000:"+parts[2], peer); err == nil {
+		t.Fatal("Invite with a modified expiry should not be valid")
+	}
+
+	for _, malformed := range []string{"", "abc", inviter, "0X1234:42:" + parts[2], parts[0] + ":x:" + parts[2]} {
+		if _, _, err := VerifyJoinInvite(malformed, peer); err == nil {
+			t.Fatalf("Malformed invite %q should not be valid", malformed)
+		}
+	}
+}
+
+func TestVerifyRotationTransaction(t *testing.T) {
+	oldKey, _ := keys.GenerateECDSAKey()
+	newKey, _ := keys.GenerateECDSAKey()
+	otherKey, _ := keys.GenerateECDSAKey()
+
+	peer := peers.NewPeer(keys.PublicKeyHex(&oldKey.PublicKey), "", "peer")
+	newPubKey := keys.PublicKeyHex(&newKey.PublicKey)
+
+	verify := func(itx InternalTransaction) bool {
+		ok, _ := itx.Verify()
+		return ok
+	}
+
+	//Signed by both keys
+	rotation := NewInternalTransactionRotation(*peer, newPubKey)
+	rotation.Sign(oldKey)
+	rotation.SignRotation(newKey)
+	if !verify(rotation) {
+		t.Fatal("PEER_ROTATE_KEY signed by both keys should verify")
+	}
+
+	//Missing the signature of the new key
+	unsigned := NewInternalTransactionRotation(*peer, newPubKey)
+	unsigned.Sign(oldKey)
+	if verify(unsigned) {
+		t.Fatal("PEER_ROTATE_KEY without the signature of the new key should not verify")
+	}
+
+	//The new key is not the one that signed
+	wrongKey := NewInternalTransactionRotation(*peer, newPubKey)
+	wrongKey.Sign(oldKey)
+	wrongKey.SignRotation(otherKey)
+	if verify(wrongKey) {
+		t.Fatal("PEER_ROTATE_KEY signed by another key should not verify")
+	}
+
+	//Not signed by the old key
+	hijack := NewInternalTransactionRotation(*peer, newPubKey)
+	hijack.Sign(newKey)
+	hijack.SignRotation(newKey)
+	if verify(hijack) {
+		t.Fatal("PEER_ROTATE_KEY not signed by the old key should not verify")
+	}
+
+	//A PEER_ROTATE_KEY requires a new key, and only a PEER_ROTATE_KEY can have one
+	noKey := NewInternalTransactionRotation(*peer, "")
+	noKey.Sign(oldKey)
+	if verify(noKey) {
+		t.Fatal("PEER_ROTATE_KEY without a new key should not verify")
+	}
+
+	add := NewInternalTransactionJoin(*peer)
+	add.Body.NewPubKey = newPubKey
+	add.Sign(oldKey)
+	add.SignRotation(newKey)
+	if verify(add) {
+		t.Fatal("PEER_ADD with a new key should not verify")
+	}
+}
@@ -27,6 +27,10 @@ type RoundInfo struct {
 	ReceivedEvents []string
 	queued         bool
 	decided        bool
+	// witnesses indexes the witnesses in CreatedEvents, so that they can be
+	// listed without scanning all the events of the round. It is rebuilt
+	// lazily when the RoundInfo is unmarshalled.
+	witnesses []string
 }
 
 // NewRoundInfo creates a new RoundInfo.
@@ -44,6 +48,9 @@ func (r *RoundInfo) AddCreatedEvent(x string, witness bool) {
 		r.CreatedEvents[x] = roundEvent{
 			Witness: witness,
 		}
+		if witness && r.witnesses != nil {
+			r.witnesses = append(r.witnesses, x)
+		}
 	}
 }
 
@@ -59,6 +66,9 @@ func (r *RoundInfo) SetFame(x string, f bool) {
 		e = roundEvent{
 			Witness: true,
 		}
+		if r.witnesses != nil {
+			r.witnesses = append(r.witnesses, x)
+		}
 	}
 
 	if f {
@@ -70,21 +80,22 @@ func (r *RoundInfo) SetFame(x string, f bool) {
 	r.CreatedEvents[x] = e
 }
 
-// WitnessesDecided returns true if a super-majority of witnesses are decided,
-// and there are no undecided witnesses. Our algorithm relies on the fact that a
+// WitnessesDecided returns true if witnesses holding a super-majority of the
+// voting weight are decided, and there are no undecided witnesses. The weight
+// function returns the voting weight of a witness's creator. Our algorithm relies on the fact that a
 // witness that is not yet known when a super-majority of witnesses are already
 // decided, has no chance of ever being famous. Once a Round is decided it stays
 // decided, even if new witnesses are added after it was first decided.
-func (r *RoundInfo) WitnessesDecided(peerSet *peers.PeerSet) bool {
+func (r *RoundInfo) WitnessesDecided(peerSet *peers.PeerSet, weight func(witness string) int) bool {
 	//if the round was already decided, it stays decided no matter what.
 	if r.decided {
 		return true
 	}
 
 	c := 0
-	for _, e := range r.CreatedEvents {
+	for x, e := range r.CreatedEvents {
 		if e.Witness && e.Famous != common.Undefined {
-			c++
+			c += weight(x)
 		} else if e.Witness && e.Famous == common.Undefined {
 			return false
 		}
@@ -97,13 +108,18 @@ func (r *RoundInfo) WitnessesDecided(peerSet *peers.PeerSet) bool {
 
 // Witnesses return witnesses.
 func (r *RoundInfo) Witnesses() []string {
-	res := []string{}
-	for x, e := range r.CreatedEvents {
-		if e.Witness {
-			res = append(res, x)
+	if r.witnesses == nil {
+		r.witnesses = []string{}
+		for x, e := range r.CreatedEvents {
+			if e.Witness {
+				r.witnesses = append(r.witnesses, x)
+			}
 		}
 	}
 
+	res := make([]string, len(r.witnesses))
+	copy(res, r.witnesses)
+
 	return res
 }
 
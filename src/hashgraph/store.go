@@ -1,12 +1,21 @@
 package hashgraph
 
-import "github.com/Kdag-K/kdag/src/peers"
+import (
+	cm "github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/peers"
+)
 
 // Store is an interface for backend stores.
 type Store interface {
 	// CacheSize retrieves the cacheSize setting that determines the maximum
 	// number of items that caches can contain.
 	CacheSize() int
+	// CacheBytes retrieves the cacheBytes setting that determines the maximum
+	// estimated size of each cache, in bytes. Zero means unbounded.
+	CacheBytes() int64
+	// CacheStats returns the usage statistics of the event, round, block and
+	// frame caches.
+	CacheStats() map[string]cm.LRUStats
 	// GetPeerSet returns the peer-set effective at a given round.
 	GetPeerSet(round int) (*peers.PeerSet, error)
 	// SetPeerSet sets the peer-set effective at a given round.
@@ -60,6 +69,12 @@ type Store interface {
 	SetBlock(*Block) error
 	// LastBlockIndex returns the last block index.
 	LastBlockIndex() int
+	// GetTxLocation returns the block index and position of a transaction
+	// identified by its hash.
+	GetTxLocation(txHash string) (TxLocation, error)
+	// GetBlockIndexesByTime returns the indexes of blocks whose timestamp is
+	// within [start, end].
+	GetBlockIndexesByTime(start, end int64) ([]int, error)
 	// GetFrame retrieves the frame associated to a round received.
 	GetFrame(roundReceived int) (*Frame, error)
 	// SetFrame stores a frame.
@@ -0,0 +1,352 @@
+package hashgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// RoundTrace records how the consensus methods processed a round, to find out
+// why consensus stalls. It reflects the last pass of DecideFame,
+// DecideRoundReceived, and ProcessDecidedRounds over the round.
+type RoundTrace struct {
+	Round int
+	// Decided is true when the fame of enough witnesses is decided.
+	Decided bool
+	// Processed is true when the round was committed by ProcessDecidedRounds.
+	Processed bool
+	// PendingReason explains why a round is not processed yet.
+	PendingReason string `json:",omitempty"`
+	// BlockedEvents is the number of undetermined events whose round-received
+	// could not be decided because the fame of this round's witnesses is not
+	// decided.
+	BlockedEvents int
+	// Witnesses are sorted by hash.
+	Witnesses []*WitnessTrace
+}
+
+// WitnessTrace records the fame voting of a witness.
+type WitnessTrace struct {
+	Hash    string
+	Creator string
+	// Famous is True, False, or Undefined.
+	Famous string
+	// VotingRounds is the number of rounds it took to decide the fame of the
+	// witness, or 0 if it is not decided.
+	VotingRounds int
+	// Exhausted is true if the fame of the witness was not decided within
+	// ConsensusParams.MaxVotingRounds.
+	Exhausted bool
+	// Votes contains the votes of the witnesses of later rounds, in the order
+	// they were cast.
+	Votes []VoteTrace
+}
+
+// VoteTrace is the vote of a witness, in a later round, on the fame of a
+// WitnessTrace.
+type VoteTrace struct {
+	Round int
+	Voter string
+	Vote  bool
+	// Yays and Nays are the weights of the strongly-seen witnesses of the
+	// previous round that voted true or false. They are 0 in the first voting
+	// round, where the vote is whether the voter sees the witness.
+	Yays int `json:",omitempty"`
+	Nays int `json:",omitempty"`
+	// CoinRound is true if the vote was cast in a coin round.
+	CoinRound bool `json:",omitempty"`
+	// CoinFlip is true if the vote is the middle bit of the voter's hash.
+	CoinFlip bool `json:",omitempty"`
+	// Decisive is true if the vote decided the fame of the witness.
+	Decisive bool `json:",omitempty"`
+}
+
+// roundTracer keeps the RoundTraces of the most recent rounds. A nil
+// roundTracer records nothing, so that tracing costs nothing when it is
+// disabled.
+type roundTracer struct {
+	size      int
+	rounds    map[int]*RoundTrace
+	witnesses map[string]*WitnessTrace
+	lastRound int
+}
+
+func newRoundTracer(size int) *roundTracer {
+	return &roundTracer{
+		size:      size,
+		rounds:    make(map[int]*RoundTrace),
+		witnesses: make(map[string]*WitnessTrace),
+		lastRound: -1,
+	}
+}
+
+// round returns the RoundTrace of a round, creating it if necessary, and
+// evicts the traces that are size rounds older than the last one.
+func (t *roundTracer) round(index int) *RoundTrace {
+	rt, ok := t.rounds[index]
+	if !ok {
+		rt = &RoundTrace{Round: index}
+		t.rounds[index] = rt
+	}
+
+	if index > t.lastRound {
+		t.lastRound = index
+		for r, old := range t.rounds {
+			if r <= t.lastRound-t.size {
+				for _, w := range old.Witnesses {
+					delete(t.witnesses, w.Hash)
+				}
+				delete(t.rounds, r)
+			}
+		}
+	}
+
+	return rt
+}
+
+// startVoting resets the votes on witness x, of the given round, before they
+// are recomputed by DecideFame.
+func (t *roundTracer) startVoting(round int, x string, creator string) *WitnessTrace {
+	if t == nil {
+		return nil
+	}
+
+	wt, ok := t.witnesses[x]
+	if !ok {
+		wt = &WitnessTrace{Hash: x, Creator: creator}
+		t.witnesses[x] = wt
+
+		rt := t.round(round)
+		rt.Witnesses = append(rt.Witnesses, wt)
+		sort.Slice(rt.Witnesses, func(i, j int) bool {
+			return rt.Witnesses[i].Hash < rt.Witnesses[j].Hash
+		})
+	}
+
+	wt.Votes = wt.Votes[:0]
+	wt.Famous = common.Undefined.String()
+	wt.VotingRounds = 0
+
+	return wt
+}
+
+func (wt *WitnessTrace) vote(vt VoteTrace) {
+	if wt == nil {
+		return
+	}
+	wt.Votes = append(wt.Votes, vt)
+}
+
+func (wt *WitnessTrace) decide(famous bool, votingRounds int) {
+	if wt == nil {
+		return
+	}
+	wt.Votes[len(wt.Votes)-1].Decisive = true
+	if famous {
+		wt.Famous = common.True.String()
+	} else {
+		wt.Famous = common.False.String()
+	}
+	wt.VotingRounds = votingRounds
+}
+
+func (wt *WitnessTrace) exhaust() {
+	if wt == nil {
+		return
+	}
+	wt.Exhausted = true
+}
+
+// decideRound records whether the fame of a round's witnesses is decided, and
+// the reason if it is not.
+func (t *roundTracer) decideRound(index int, round *RoundInfo, peerSet *peers.PeerSet, weight func(string) int, decided bool) {
+	if t == nil {
+		return
+	}
+
+	rt := t.round(index)
+	rt.Decided = decided
+	rt.PendingReason = ""
+
+	if decided {
+		return
+	}
+
+	undecided := 0
+	decidedWeight := 0
+	for _, w := range round.Witnesses() {
+		if round.IsDecided(w) {
+			decidedWeight += weight(w)
+		} else {
+			undecided++
+		}
+	}
+
+	if undecided > 0 {
+		rt.PendingReason = fmt.Sprintf("fame of %d witnesses undecided", undecided)
+	} else {
+		rt.PendingReason = fmt.Sprintf("weight of witnesses %d below supermajority %d", decidedWeight, peerSet.SuperMajority())
+	}
+}
+
+// resetBlockedEvents is called before the blocked events are recounted.
+func (t *roundTracer) resetBlockedEvents() {
+	if t == nil {
+		return
+	}
+	for _, rt := range t.rounds {
+		rt.BlockedEvents = 0
+	}
+}
+
+func (t *roundTracer) blockEvent(index int) {
+	if t == nil {
+		return
+	}
+	t.round(index).BlockedEvents++
+}
+
+func (t *roundTracer) process(index int) {
+	if t == nil {
+		return
+	}
+	rt := t.round(index)
+	rt.Processed = true
+	rt.PendingReason = ""
+}
+
+func (t *roundTracer) wait(index int, blocking int) {
+	if t == nil {
+		return
+	}
+	t.round(index).PendingReason = fmt.Sprintf("waiting for round %d", blocking)
+}
+
+// get returns a deep copy of a RoundTrace.
+func (t *roundTracer) get(index int) (*RoundTrace, bool) {
+	rt, ok := t.rounds[index]
+	if !ok {
+		return nil, false
+	}
+
+	res := *rt
+	res.Witnesses = make([]*WitnessTrace, len(rt.Witnesses))
+	for i, wt := range rt.Witnesses {
+		w := *wt
+		w.Votes = append([]VoteTrace{}, wt.Votes...)
+		res.Witnesses[i] = &w
+	}
+
+	return &res, true
+}
+
+/*******************************************************************************
+Hashgraph methods
+*******************************************************************************/
+
+// SetRoundTracing enables the tracing of the consensus methods for the last
+// size rounds, or disables it if size is 0.
+func (h *Hashgraph) SetRoundTracing(size int) {
+	if size <= 0 {
+		h.tracer = nil
+		return
+	}
+	h.tracer = newRoundTracer(size)
+}
+
+// traceWaitingRounds records that the decided rounds after an undecided round
+// are waiting for it.
+func (h *Hashgraph) traceWaitingRounds(undecided int) {
+	if h.tracer == nil {
+		return
+	}
+	for _, r := range h.PendingRounds.GetOrderedPendingRounds() {
+		if r.Index > undecided && r.Decided {
+			h.tracer.wait(r.Index, undecided)
+		}
+	}
+}
+
+// traceBlockedEvents counts, for every round, the undetermined events whose
+// round-received cannot be decided because the fame of the round's witnesses
+// is not decided. It is called at every pass of RunConsensus, because
+// DecideRoundReceived is skipped while no decision changes, which is when
+// consensus stalls and events keep piling up.
+func (h *Hashgraph) traceBlockedEvents() error {
+	if h.tracer == nil {
+		return nil
+	}
+
+	h.tracer.resetBlockedEvents()
+
+	for _, x := range h.UndeterminedEvents {
+		r, err := h.round(x)
+		if err != nil {
+			return err
+		}
+
+		// Rounds below the roundLowerBound are never decided, but they do
+		// not block events (cf. DecideRoundReceived).
+		for i := r + 1; i <= h.Store.LastRound(); i++ {
+			tr, err := h.Store.GetRound(i)
+			if err != nil {
+				break
+			}
+
+			tPeers, err := h.Store.GetPeerSet(i)
+			if err != nil {
+				return err
+			}
+
+			if !tr.WitnessesDecided(tPeers, h.weightFunc(tPeers)) &&
+				(h.roundLowerBound == nil || *h.roundLowerBound < i) {
+				h.tracer.blockEvent(i)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetRoundTrace returns the RoundTrace of a round. It returns an error if
+// tracing is disabled or if the round is not traced.
+func (h *Hashgraph) GetRoundTrace(index int) (*RoundTrace, error) {
+	if h.tracer == nil {
+		return nil, fmt.Errorf("Round tracing is disabled")
+	}
+
+	rt, ok := h.tracer.get(index)
+	if !ok {
+		return nil, common.NewStoreErr("RoundTrace", common.KeyNotFound, fmt.Sprint(index))
+	}
+
+	return rt, nil
+}
+
+// DumpRoundTraces writes all the RoundTraces, in JSON, sorted by round.
+func (h *Hashgraph) DumpRoundTraces(w io.Writer) error {
+	if h.tracer == nil {
+		return fmt.Errorf("Round tracing is disabled")
+	}
+
+	indexes := []int{}
+	for r := range h.tracer.rounds {
+		indexes = append(indexes, r)
+	}
+	sort.Ints(indexes)
+
+	traces := []*RoundTrace{}
+	for _, r := range indexes {
+		rt, _ := h.tracer.get(r)
+		traces = append(traces, rt)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(traces)
+}
@@ -0,0 +1,129 @@
+package hashgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// Votes records the validators that voted for changes of the validator-set
+// which require the approval of a supermajority ([proposal] => sorted public
+// keys of the voters). Votes are only modified when Blocks are committed, and
+// they are carried in Frames, so every node, including those that fast-forward
+// or bootstrap, counts the same votes.
+type Votes map[string][]string
+
+// Add records the vote of a validator for a proposal, and returns all the
+// voters of the proposal.
+func (v Votes) Add(proposal string, voter string) []string {
+	voter = strings.ToUpper(voter)
+
+	voters := v[proposal]
+	i := sort.SearchStrings(voters, voter)
+	if i == len(voters) || voters[i] != voter {
+		voters = append(voters, "")
+		copy(voters[i+1:], voters[i:])
+		voters[i] = voter
+		v[proposal] = voters
+	}
+
+	return voters
+}
+
+// Remove forgets the votes for a proposal.
+func (v Votes) Remove(proposal string) {
+	delete(v, proposal)
+}
+
+// RemovePrefix forgets the votes for all the proposals that start with the
+// given prefix.
+func (v Votes) RemovePrefix(prefix string) {
+	for proposal := range v {
+		if strings.HasPrefix(proposal, prefix) {
+			delete(v, proposal)
+		}
+	}
+}
+
+// Copy returns a deep copy of the Votes.
+func (v Votes) Copy() Votes {
+	res := make(Votes, len(v))
+	for proposal, voters := range v {
+		res[proposal] = append([]string{}, voters...)
+	}
+	return res
+}
+
+// ValidatorRound returns the round from which a validator has held its current
+// place and weight in the validator-set, according to a peer-set history. The
+// second value is false if the validator is not part of the latest peer-set.
+// InternalTransactions that remove a validator or change its weight are bound
+// to this round, so they cannot be replayed after the validator has left and
+// rejoined, or after its weight has changed.
+func ValidatorRound(history map[int][]*peers.Peer, pubKey string) (int, bool) {
+	pubKey = strings.ToUpper(pubKey)
+
+	round := -1
+	weight := 0
+
+	for _, r := range sortedRounds(history) {
+		peer, ok := findPeer(history[r], pubKey)
+		switch {
+		case !ok:
+			round = -1
+		case round == -1 || peer.VotingWeight() != weight:
+			round = r
+			weight = peer.VotingWeight()
+		}
+	}
+
+	if round == -1 {
+		return 0, false
+	}
+
+	return round, true
+}
+
+// RemovedRound returns the latest round from which a validator was removed
+// from the validator-set, according to a peer-set history. The second value is
+// false if the validator is part of the latest peer-set, or was never a
+// validator.
+func RemovedRound(history map[int][]*peers.Peer, pubKey string) (int, bool) {
+	pubKey = strings.ToUpper(pubKey)
+
+	removed := -1
+	present := false
+
+	for _, r := range sortedRounds(history) {
+		_, ok := findPeer(history[r], pubKey)
+		if present && !ok {
+			removed = r
+		}
+		present = ok
+	}
+
+	if present || removed == -1 {
+		return 0, false
+	}
+
+	return removed, true
+}
+
+func sortedRounds(history map[int][]*peers.Peer) []int {
+	rounds := make([]int, 0, len(history))
+	for r := range history {
+		rounds = append(rounds, r)
+	}
+	sort.Ints(rounds)
+	return rounds
+}
+
+func findPeer(peerSet []*peers.Peer, pubKey string) (*peers.Peer, bool) {
+	for _, p := range peerSet {
+		if p.PubKeyString() == pubKey {
+			return p, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,75 @@
+package hashgraph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+func TestVotes(t *testing.T) {
+	votes := make(Votes)
+
+	votes.Add("proposal", "0xbb")
+	votes.Add("proposal", "0xAA")
+	voters := votes.Add("proposal", "0xBB")
+
+	if !reflect.DeepEqual(voters, []string{"0XAA", "0XBB"}) {
+		t.Fatalf("Voters should be sorted and counted once, not %v", voters)
+	}
+
+	cp := votes.Copy()
+	votes.Add("proposal", "0xCC")
+	votes.Remove("other")
+
+	if len(cp["proposal"]) != 2 {
+		t.Fatalf("Copy should not be modified with the original, %v", cp)
+	}
+
+	votes.Remove("proposal")
+	if len(votes) != 0 {
+		t.Fatalf("Votes should be empty, not %v", votes)
+	}
+}
+
+func TestValidatorRound(t *testing.T) {
+	a := peers.NewPeer("0xaa", "", "a")
+	b := peers.NewPeer("0xbb", "", "b")
+	heavyB := peers.NewPeer("0xbb", "", "b")
+	heavyB.Weight = 3
+
+	history := map[int][]*peers.Peer{
+		0:  {a, b},
+		10: {a},
+		20: {a, b},
+		30: {a, heavyB},
+		40: {a, heavyB},
+	}
+
+	if round, ok := ValidatorRound(history, "0xAA"); !ok || round != 0 {
+		t.Fatalf("a should be a validator since round 0, not %d (%v)", round, ok)
+	}
+
+	// b rejoined at round 20, and its weight changed at round 30
+	if round, ok := ValidatorRound(history, "0xbb"); !ok || round != 30 {
+		t.Fatalf("b should be a validator since round 30, not %d (%v)", round, ok)
+	}
+
+	if _, ok := RemovedRound(history, "0xBB"); ok {
+		t.Fatal("b should not be removed")
+	}
+
+	history[50] = []*peers.Peer{a}
+
+	if _, ok := ValidatorRound(history, "0xBB"); ok {
+		t.Fatal("b should not be a validator")
+	}
+
+	if round, ok := RemovedRound(history, "0xBB"); !ok || round != 50 {
+		t.Fatalf("b should be removed at round 50, not %d (%v)", round, ok)
+	}
+
+	if _, ok := RemovedRound(history, "0xCC"); ok {
+		t.Fatal("c was never a validator")
+	}
+}
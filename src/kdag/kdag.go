@@ -8,7 +8,10 @@ import (
 	"github.com/sirupsen/logrus"
 	
 	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto"
 	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/discovery"
+	"github.com/Kdag-K/kdag/src/genesis"
 	h "github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/net"
 	"github.com/Kdag-K/kdag/src/net/signal/wamp"
@@ -26,6 +29,8 @@ type Kdag struct {
 	Peers        *peers.PeerSet
 	GenesisPeers *peers.PeerSet
 	Service      *service.Service
+	Discovery    *discovery.Discovery
+	signal       *wamp.Client
 	logger       *logrus.Entry
 }
 
@@ -52,12 +57,24 @@ func (b *Kdag) Init() error {
 		b.logger.WithError(err).Error("kdag.go:Init() initKey")
 		return err
 	}
+	b.logger.Debug("initGenesis")
+	if err := b.initGenesis(); err != nil {
+		b.logger.WithError(err).Error("kdag.go:Init() initGenesis")
+		return err
+	}
+
 	b.logger.Debug("initPeers")
 	if err := b.initPeers(); err != nil {
 		b.logger.WithError(err).Error("kdag.go:Init() initPeers")
 		return err
 	}
 
+	b.logger.Debug("initConsensusParams")
+	if err := b.initConsensusParams(); err != nil {
+		b.logger.WithError(err).Error("kdag.go:Init() initConsensusParams")
+		return err
+	}
+
 	b.logger.Debug("initStore")
 	if err := b.initStore(); err != nil {
 		b.logger.WithError(err).Error("kdag.go:Init() initStore")
@@ -76,6 +93,12 @@ func (b *Kdag) Init() error {
 		return err
 	}
 
+	b.logger.Debug("initDiscovery")
+	if err := b.initDiscovery(); err != nil {
+		b.logger.WithError(err).Error("kdag.go:Init() initDiscovery")
+		return err
+	}
+
 	b.logger.Debug("initNode")
 	if err := b.initNode(); err != nil {
 		b.logger.WithError(err).Error("kdag.go:Init() initNode")
@@ -116,10 +139,15 @@ func (b *Kdag) validateConfig() error {
 		"kdag.TCPTimeout":       b.Config.TCPTimeout,
 		"kdag.JoinTimeout":      b.Config.JoinTimeout,
 		"kdag.CacheSize":        b.Config.CacheSize,
+		"kdag.CacheBytes":       b.Config.CacheBytes,
 		"kdag.SyncLimit":        b.Config.SyncLimit,
 		"kdag.EnableFastSync":   b.Config.EnableFastSync,
 		"kdag.MaintenanceMode":  b.Config.MaintenanceMode,
+		"kdag.Observer":         b.Config.Observer,
 		"kdag.SuspendLimit":     b.Config.SuspendLimit,
+		"kdag.TraceRounds":      b.Config.TraceRounds,
+		"kdag.Seeds":            b.Config.Seeds,
+		"kdag.ResolverService":  b.Config.ResolverService,
 	}
 
 	// WebRTC requires signaling and ICE servers
@@ -130,6 +158,7 @@ func (b *Kdag) validateConfig() error {
 		logFields["kdag.SignalSkipVerify"] = b.Config.SignalSkipVerify
 		logFields["kdag.ICEAddress"] = b.Config.ICEAddress
 		logFields["kdag.ICEUsername"] = b.Config.ICEUsername
+		logFields["kdag.SignalDiscovery"] = b.Config.SignalDiscovery
 	} else {
 		logFields["kdag.BindAddr"] = b.Config.BindAddr
 		logFields["kdag.AdvertiseAddr"] = b.Config.AdvertiseAddr
@@ -149,6 +178,8 @@ func (b *Kdag) validateConfig() error {
 	if b.Config.Store {
 		logFields["kdag.Store"] = b.Config.Store
 		logFields["kdag.DatabaseDir"] = b.Config.DatabaseDir
+		logFields["kdag.StoreIndexes"] = b.Config.StoreIndexes
+		logFields["kdag.EncryptDB"] = b.Config.EncryptDB
 		logFields["kdag.Bootstrap"] = b.Config.Bootstrap
 	}
 
@@ -200,6 +231,7 @@ func (b *Kdag) initTransport() error {
 		}
 
 		b.Transport = webRTCTransport
+		b.signal = signal
 	} else {
 		tcpTransport, err := net.NewTCPTransport(
 		b.Config.BindAddr,
@@ -220,18 +252,86 @@ func (b *Kdag) initTransport() error {
 	return nil
 	}
 
+// initDiscovery creates the Discovery through which the node finds peers to
+// join or fast-forward, on top of the peers from peers.json.
+func (b *Kdag) initDiscovery() error {
+	b.Discovery = discovery.NewDiscovery(b.Config.Logger().WithField("component", "discovery"))
+
+	if b.Config.Seeds != "" {
+		seeds, err := discovery.NewSeedSource(b.Config.Seeds)
+		if err != nil {
+			return err
+		}
+		b.Discovery.AddSource(seeds)
+	}
+
+	if b.Config.ResolverService != "" {
+		resolver := discovery.NewLocalResolver(b.Config.ResolverFile())
+		b.Discovery.AddSource(discovery.NewResolverSource(resolver, b.Config.ResolverService))
+	}
+
+	if b.Config.SignalDiscovery && b.signal != nil {
+		b.Discovery.AddSource(discovery.NewSignalSource(b.signal))
+	}
+
+	b.logger.WithField("sources", b.Discovery.Len()).Debug("Loaded Discovery")
+
+	return nil
+}
+
+// genesis.json
+func (b *Kdag) initGenesis() error {
+	if b.Config.Genesis == nil {
+		g, err := genesis.Read(b.Config.GenesisFile())
+		if os.IsNotExist(err) {
+			b.logger.Debug("No genesis.json")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		b.Config.Genesis = g
+	} else if err := b.Config.Genesis.Validate(); err != nil {
+		return err
+	}
+
+	hash, err := b.Config.Genesis.HashString()
+	if err != nil {
+		return err
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"chain_id":     b.Config.Genesis.ChainID,
+		"genesis_time": b.Config.Genesis.GenesisTime,
+		"validators":   len(b.Config.Genesis.Validators),
+		"hash":         hash,
+	}).Debug("Loaded Genesis")
+
+	return nil
+}
+
 	// peers.json
 func (b *Kdag) initPeers() error {
 	peerStore := peers.NewJSONPeerSet(b.Config.DataDir, true)
 
 	participants, err := peerStore.PeerSet()
 	if err != nil {
-		return err
+		// With a genesis, peers.json is optional
+		if b.Config.Genesis == nil {
+			return err
+		}
+		b.logger.Debugf("could not read peers.json, using genesis validators: %v", err)
+		participants = b.Config.Genesis.PeerSet()
 	}
 
 	b.Peers = participants
 	b.logger.Debug("Loaded Peers")
 
+	// The genesis takes precedence over peers.genesis.json
+	if b.Config.Genesis != nil {
+		b.GenesisPeers = b.Config.Genesis.PeerSet()
+		return nil
+	}
+
 	// Set Genesis Peer Set from peers.genesis.json
 	genesisPeerStore := peers.NewJSONPeerSet(b.Config.DataDir, false)
 
@@ -239,6 +339,15 @@ func (b *Kdag) initPeers() error {
 	if err != nil { // If there is any error, the current peer set is used as the genesis peer set
 		b.logger.Debugf("could not read peers.genesis.json: %v", err)
 		b.GenesisPeers = participants
+
+		// The node rewrites peers.json when the peer-set changes, so the
+		// genesis peer-set is recorded before it does, for the node to
+		// restart from the same one.
+		if os.IsNotExist(err) {
+			if err := genesisPeerStore.Write(participants.Peers); err != nil {
+				return fmt.Errorf("Writing peers.genesis.json: %s", err)
+			}
+		}
 	} else {
 		b.GenesisPeers = genesisParticipants
 	}
@@ -246,10 +355,31 @@ func (b *Kdag) initPeers() error {
 	return nil
 }
 
+// consensus.genesis.json
+func (b *Kdag) initConsensusParams() error {
+	if b.Config.Genesis != nil {
+		params := b.Config.Genesis.ConsensusParams
+		b.Config.ConsensusParams = &params
+	} else if b.Config.ConsensusParams == nil {
+		params, err := h.ReadConsensusParams(b.Config.ConsensusParamsFile())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		b.Config.ConsensusParams = &params
+	}
+
+	b.logger.WithFields(logrus.Fields{
+		"coin_round_freq":   b.Config.ConsensusParams.CoinRoundFreq,
+		"max_voting_rounds": b.Config.ConsensusParams.MaxVotingRounds,
+	}).Debug("Loaded ConsensusParams")
+
+	return nil
+}
+
 func (b *Kdag) initStore() error {
 	if !b.Config.Store {
 		b.logger.Debug("Creating InmemStore")
-		b.Store = h.NewInmemStore(b.Config.CacheSize)
+		b.Store = h.NewSizedInmemStore(b.Config.CacheSize, b.Config.CacheBytes)
 	} else {
 		dbPath := b.Config.DatabaseDir
 
@@ -274,10 +404,18 @@ func (b *Kdag) initStore() error {
 
 		b.logger.WithField("path", dbPath).Debug("Opening BadgerStore")
 
+		encryptionKey, err := b.dbEncryptionKey(!b.Config.Bootstrap)
+		if err != nil {
+			return err
+		}
+
 		dbStore, err := h.NewBadgerStore(
 			b.Config.CacheSize,
+			b.Config.CacheBytes,
 			dbPath,
 			b.Config.MaintenanceMode,
+			b.Config.StoreIndexes,
+			encryptionKey,
 			b.logger)
 		if err != nil {
 			return err
@@ -289,6 +427,17 @@ func (b *Kdag) initStore() error {
 	return nil
 }
 
+// dbEncryptionKey returns the key that encrypts the database, or nil if
+// encryption is not activated. If create is true and the key file does not
+// exist yet, a new key is generated and written to it.
+func (b *Kdag) dbEncryptionKey(create bool) ([]byte, error) {
+	if !b.Config.EncryptDB {
+		return nil, nil
+	}
+
+	return crypto.ReadOrCreateSymmetricKey(b.Config.DBKeyfile(), create)
+}
+
 func (b *Kdag) initKey() error {
 	if b.Config.Key == nil {
 		simpleKeyfile := keys.NewSimpleKeyfile(b.Config.Keyfile())
@@ -334,6 +483,9 @@ func (b *Kdag) initNode() error {
 		b.Config.Proxy,
 	)
 
+	b.Node.SetDiscovery(b.Discovery)
+	b.Node.SetPeerStore(peers.NewJSONPeerSet(b.Config.DataDir, true))
+
 	return b.Node.Init()
 }
 
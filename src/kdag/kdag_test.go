@@ -1,6 +1,7 @@
 package kdag
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"fmt"
 	"io/ioutil"
@@ -74,6 +75,51 @@ func TestInitStore(t *testing.T) {
 	}
 }
 
+// TestInitPeersGenesis checks that the genesis peer-set is recorded before
+// peers.json is rewritten, so that a node restarts from the same one.
+func TestInitPeersGenesis(t *testing.T) {
+	dir := t.TempDir()
+
+	conf := config.NewDefaultConf()
+	conf.SetDataDir(dir)
+
+	peerSlice := []*peers.Peer{}
+	for i := 0; i < 3; i++ {
+		key, _ := bkeys.GenerateECDSAKey()
+		peerSlice = append(peerSlice, peers.NewPeer(bkeys.PublicKeyHex(&key.PublicKey), fmt.Sprintf("addr%d", i), fmt.Sprintf("peer%d", i)))
+	}
+
+	if err := peers.NewJSONPeerSet(dir, true).Write(peerSlice); err != nil {
+		t.Fatal(err)
+	}
+
+	kdag := NewKdag(conf)
+	if err := kdag.initPeers(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The node persists a new peer-set
+	if err := peers.NewJSONPeerSet(dir, true).Write(peerSlice[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewKdag(conf)
+	if err := restarted.initPeers(); err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted.Peers.Len() != 2 {
+		t.Fatalf("Peers should be read from peers.json, not %v", restarted.Peers.Peers)
+	}
+
+	genesisHash, _ := kdag.GenesisPeers.Hash()
+	restartedHash, _ := restarted.GenesisPeers.Hash()
+
+	if !bytes.Equal(restartedHash, genesisHash) || restarted.GenesisPeers.Len() != 3 {
+		t.Fatalf("Genesis peers should not change: %v", restarted.GenesisPeers.Peers)
+	}
+}
+
 func TestMaintenanceMode(t *testing.T) {
 	os.RemoveAll("test_data")
 	os.Mkdir("test_data", os.ModeDir|0777)
@@ -0,0 +1,131 @@
+package kdag
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Kdag-K/kdag/src/common"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+// ReplayReport summarises the outcome of a Replay.
+type ReplayReport struct {
+	// From is the index of the first replayed block.
+	From int
+	// LastBlock is the index of the last block that was committed to the app,
+	// or From-1 if none was committed.
+	LastBlock int
+	// Mismatch is set if the app's state-hash diverged from the one recorded
+	// in a block. The replay stops at the first mismatch.
+	Mismatch *StateHashMismatch
+}
+
+// StateHashMismatch describes a block for which the app returned a different
+// state-hash than the one recorded in the consensus log.
+type StateHashMismatch struct {
+	BlockIndex int
+	Recorded   []byte
+	Replayed   []byte
+}
+
+// String returns a human-readable description of the mismatch.
+func (m *StateHashMismatch) String() string {
+	return fmt.Sprintf("block %d: recorded state-hash %s, replayed state-hash %s",
+		m.BlockIndex,
+		common.EncodeToString(m.Recorded),
+		common.EncodeToString(m.Replayed))
+}
+
+// Replay re-drives the application from the blocks stored in the database,
+// without running the gossip or consensus routines. Blocks with index in
+// [from, to] are committed to the application, through the configured Proxy,
+// in order. If to is negative, all the blocks from index from onwards are
+// replayed. The app is expected to be in the state that results from
+// committing block from-1, so replaying from 0 requires a fresh app.
+//
+// Every state-hash returned by the app is compared with the one recorded in the
+// corresponding block, and the replay stops at the first mismatch. If the
+// Kdag object has not been initialised with a store, the database is opened
+// in maintenance-mode and closed upon returning.
+func (b *Kdag) Replay(from, to int) (*ReplayReport, error) {
+	if b.Config.Proxy == nil {
+		return nil, fmt.Errorf("No Proxy to replay blocks to")
+	}
+
+	store := b.Store
+	if store == nil {
+		// If --datadir was explicitely set, but not --db, the following line
+		// will update the default database dir to be inside the new datadir.
+		b.Config.SetDataDir(b.Config.DataDir)
+
+		encryptionKey, err := b.dbEncryptionKey(false)
+		if err != nil {
+			return nil, err
+		}
+
+		dbStore, err := h.NewBadgerStore(
+			b.Config.CacheSize,
+			b.Config.CacheBytes,
+			b.Config.DatabaseDir,
+			true,
+			b.Config.StoreIndexes,
+			encryptionKey,
+			b.logger)
+		if err != nil {
+			return nil, err
+		}
+		defer dbStore.Close()
+
+		store = dbStore
+	}
+
+	report := &ReplayReport{
+		From:      from,
+		LastBlock: from - 1,
+	}
+
+	for i := from; to < 0 || i <= to; i++ {
+		block, err := store.GetBlock(i)
+		if err != nil {
+			if common.IsStore(err, common.KeyNotFound) && to < 0 {
+				break
+			}
+			return report, err
+		}
+
+		// The app was originally given the block before the state-hash and
+		// the receipts were set.
+		replayedBlock := *block
+		replayedBlock.Body.StateHash = []byte{}
+		replayedBlock.Body.InternalTransactionReceipts = nil
+
+		resp, err := b.Config.Proxy.CommitBlock(replayedBlock)
+		if err != nil {
+			return report, fmt.Errorf("Committing block %d: %v", i, err)
+		}
+
+		report.LastBlock = i
+
+		if !bytes.Equal(resp.StateHash, block.StateHash()) {
+			report.Mismatch = &StateHashMismatch{
+				BlockIndex: i,
+				Recorded:   block.StateHash(),
+				Replayed:   resp.StateHash,
+			}
+
+			b.logger.WithFields(logrus.Fields{
+				"block":    i,
+				"recorded": common.EncodeToString(block.StateHash()),
+				"replayed": common.EncodeToString(resp.StateHash),
+			}).Error("Replay state-hash mismatch")
+
+			break
+		}
+
+		b.logger.WithField("block", i).Debug("Replayed block")
+	}
+
+	return report, nil
+}
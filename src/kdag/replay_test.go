@@ -0,0 +1,78 @@
+package kdag
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/config"
+	h "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/Kdag-K/kdag/src/proxy/dummy"
+	"github.com/sirupsen/logrus"
+)
+
+func TestReplay(t *testing.T) {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+	defer os.RemoveAll("test_data")
+
+	conf := config.NewTestConfig(t, logrus.InfoLevel)
+	conf.SetDataDir("test_data")
+
+	// Record blocks with the state-hashes produced by a dummy app.
+	store, err := h.NewBadgerStore(conf.CacheSize, conf.CacheBytes, conf.DatabaseDir, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := dummy.NewInmemDummyClient(common.NewTestEntry(t, logrus.InfoLevel))
+	for i := 0; i < 5; i++ {
+		block := h.NewBlock(i, i, []byte{}, []*peers.Peer{}, [][]byte{[]byte(fmt.Sprintf("tx%d", i))}, []h.InternalTransaction{}, 0)
+		resp, err := app.CommitBlock(*block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Body.StateHash = resp.StateHash
+		if i == 3 {
+			block.Body.StateHash = []byte("diverged")
+		}
+		if err := store.SetBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	t.Run("Replay until first mismatch", func(t *testing.T) {
+		conf.Proxy = dummy.NewInmemDummyClient(common.NewTestEntry(t, logrus.InfoLevel))
+
+		report, err := NewKdag(conf).Replay(0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if report.Mismatch == nil {
+			t.Fatal("Replay should have reported a mismatch")
+		}
+		if report.Mismatch.BlockIndex != 3 || report.LastBlock != 3 {
+			t.Fatalf("Replay should stop at block 3, not %d", report.LastBlock)
+		}
+	})
+
+	t.Run("Replay range", func(t *testing.T) {
+		conf.Proxy = dummy.NewInmemDummyClient(common.NewTestEntry(t, logrus.InfoLevel))
+
+		report, err := NewKdag(conf).Replay(0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if report.Mismatch != nil {
+			t.Fatalf("Unexpected mismatch: %s", report.Mismatch)
+		}
+		if report.LastBlock != 2 {
+			t.Fatalf("Last replayed block should be 2, not %d", report.LastBlock)
+		}
+	})
+}
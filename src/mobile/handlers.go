@@ -22,3 +22,11 @@ type ExceptionHandler interface {
 type StateChangeHandler interface {
 	OnStateChanged(state int32)
 }
+
+// PeersChangeHandler wraps an OnPeersChanged callback. This method will be
+// called by Kdag when consensus decides a future validator-set, which only
+// becomes effective at the change's EffectiveRound. The change is serialized
+// with JSON.
+type PeersChangeHandler interface {
+	OnPeersChanged(change []byte)
+}
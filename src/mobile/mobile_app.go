@@ -0,0 +1,96 @@
+package mobile
+
+import (
+	"encoding/json"
+
+	"github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/node/state"
+	"github.com/Kdag-K/kdag/src/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+// mobileApp implements the ProxyHandler and PeersChangeHandler interfaces by
+// relaying the calls to the handlers of the mobile application, with the
+// blocks and peers changes serialized with JSON.
+type mobileApp struct {
+	commitHandler      CommitHandler
+	stateChangeHandler StateChangeHandler
+	peersChangeHandler PeersChangeHandler
+	exceptionHandler   ExceptionHandler
+	logger             *logrus.Entry
+}
+
+func newMobileApp(commitHandler CommitHandler,
+	stateChangeHandler StateChangeHandler,
+	peersChangeHandler PeersChangeHandler,
+	exceptionHandler ExceptionHandler,
+	logger *logrus.Entry) *mobileApp {
+
+	return &mobileApp{
+		commitHandler:      commitHandler,
+		stateChangeHandler: stateChangeHandler,
+		peersChangeHandler: peersChangeHandler,
+		exceptionHandler:   exceptionHandler,
+		logger:             logger,
+	}
+}
+
+// CommitHandler implements the ProxyHandler interface
+func (m *mobileApp) CommitHandler(block hashgraph.Block) (proxy.CommitResponse, error) {
+	blockBytes, err := json.Marshal(block)
+	if err != nil {
+		m.logger.Debug("mobileApp error marshalling Block")
+		return proxy.CommitResponse{}, err
+	}
+
+	processedBlockBytes := m.commitHandler.OnCommit(blockBytes)
+
+	processedBlock := new(hashgraph.Block)
+	if err := json.Unmarshal(processedBlockBytes, processedBlock); err != nil {
+		m.logger.Debug("mobileApp error unmarshalling processed Block")
+		return proxy.CommitResponse{}, err
+	}
+
+	return proxy.CommitResponse{
+		StateHash:                   processedBlock.StateHash(),
+		InternalTransactionReceipts: processedBlock.InternalTransactionReceipts(),
+	}, nil
+}
+
+// SnapshotHandler implements the ProxyHandler interface. Mobile applications
+// do not support snapshots yet.
+func (m *mobileApp) SnapshotHandler(blockIndex int) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// RestoreHandler implements the ProxyHandler interface. Mobile applications
+// do not support snapshots yet.
+func (m *mobileApp) RestoreHandler(snapshot []byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// StateChangeHandler implements the ProxyHandler interface
+func (m *mobileApp) StateChangeHandler(state state.State) error {
+	if m.stateChangeHandler != nil {
+		m.stateChangeHandler.OnStateChanged(int32(state))
+	}
+	return nil
+}
+
+// PeersChangeHandler implements the PeersChangeHandler interface. It is a
+// no-op if the mobile application did not provide a PeersChangeHandler.
+func (m *mobileApp) PeersChangeHandler(change proxy.PeersChange) error {
+	if m.peersChangeHandler == nil {
+		return nil
+	}
+
+	changeBytes, err := json.Marshal(change)
+	if err != nil {
+		m.logger.Debug("mobileApp error marshalling PeersChange")
+		return err
+	}
+
+	m.peersChangeHandler.OnPeersChanged(changeBytes)
+
+	return nil
+}
@@ -30,6 +30,7 @@ type Node struct {
 func New(
 	commitHandler CommitHandler,
 	stateChangeHandler StateChangeHandler,
+	peersChangeHandler PeersChangeHandler,
 	exceptionHandler ExceptionHandler,
 	configDir string,
 ) *Node {
@@ -64,6 +65,7 @@ func New(
 	mobileApp := newMobileApp(
 		commitHandler,
 		stateChangeHandler,
+		peersChangeHandler,
 		exceptionHandler,
 		babbleConfig.Logger())
 	babbleConfig.Proxy = inmem.NewInmemProxy(mobileApp, babbleConfig.Logger())
@@ -5,31 +5,38 @@ import (
 	"github.com/Kdag-K/kdag/src/peers"
 )
 
+// All the requests and responses carrying hashgraph data contain the
+// GenesisHash of the sender's network, so that nodes from different networks
+// reject each other, cf. the genesis package.
+
 // SyncRequest corresponds to  the pull part of the pull-push gossip protocol.
 // It is used to retrieve unknown Events from another node. The Known map
 // represents how much the requester currently knows about the hashgraph. The
 // SyncLimit indicates the max number of Events to include in the response.
 type SyncRequest struct {
-	FromID    uint32
-	Known     map[uint32]int
-	SyncLimit int
+	FromID      uint32
+	GenesisHash string
+	Known       map[uint32]int
+	SyncLimit   int
 }
 
 // SyncResponse returns a list of Events as requested by a SyncRequest. The
 // known map indicates how much the responder knows about the hashgraph. Events
 // are encoded in light-weight wire format to take less space.
 type SyncResponse struct {
-	FromID uint32
-	Events []hashgraph.WireEvent
-	Known  map[uint32]int
+	FromID      uint32
+	GenesisHash string
+	Events      []hashgraph.WireEvent
+	Known       map[uint32]int
 }
 
 // EagerSyncRequest corresponds to the push part of the pull-push gossip
 // protocol. It is used to actively push Events to a node without it being
 // requested.
 type EagerSyncRequest struct {
-	FromID uint32
-	Events []hashgraph.WireEvent
+	FromID      uint32
+	GenesisHash string
+	Events      []hashgraph.WireEvent
 }
 
 // EagerSyncResponse indicates the success or failure of an EagerSyncRequest.
@@ -41,26 +48,80 @@ type EagerSyncResponse struct {
 // FastForwardRequest is used to request a Block, Frame, and Snapshot, from
 // which to fast-forward.
 type FastForwardRequest struct {
-	FromID uint32
+	FromID      uint32
+	GenesisHash string
 }
 
-// FastForwardResponse encapsulates the response to a FastForwardRequest.
+// FastForwardResponse encapsulates the response to a FastForwardRequest. The
+// snapshot is not included, only its manifest, and its chunks are fetched with
+// SnapshotChunkRequests.
 type FastForwardResponse struct {
-	FromID   uint32
-	Block    hashgraph.Block
-	Frame    hashgraph.Frame
-	Snapshot []byte
+	FromID      uint32
+	GenesisHash string
+	Block       hashgraph.Block
+	Frame       hashgraph.Frame
+	Manifest    SnapshotManifest
+}
+
+// SnapshotManifest describes the snapshot corresponding to a Block, split into
+// chunks of ChunkSize bytes (the last one can be shorter), so that it can be
+// fetched from several nodes in parallel and verified chunk by chunk. Hash is
+// the hash of the whole snapshot, which does not depend on the chunk size, so
+// that manifests from different nodes can be compared.
+type SnapshotManifest struct {
+	BlockIndex  int
+	Size        int64
+	Hash        []byte
+	ChunkSize   int
+	ChunkHashes [][]byte
+}
+
+// SnapshotChunkRequest is used to retrieve a chunk of the snapshot
+// corresponding to a Block.
+type SnapshotChunkRequest struct {
+	FromID      uint32
+	GenesisHash string
+	BlockIndex  int
+	Chunk       int
+}
+
+// SnapshotChunkResponse contains the chunk requested by a SnapshotChunkRequest.
+type SnapshotChunkResponse struct {
+	FromID      uint32
+	GenesisHash string
+	Data        []byte
 }
 
 // JoinRequest is used to submit an InternalTransaction to join a Kdag group.
 type JoinRequest struct {
+	GenesisHash         string
 	InternalTransaction hashgraph.InternalTransaction
 }
 
 // JoinResponse contains the response to a JoinRequest.
 type JoinResponse struct {
 	FromID        uint32
+	GenesisHash   string
 	Accepted      bool
 	AcceptedRound int
 	Peers         []*peers.Peer
+	// Reason explains why the JoinRequest was refused, if it was refused by
+	// the validators' join policy.
+	Reason string
+}
+
+// LeaveRequest is used to submit an InternalTransaction to leave a Kdag group
+// through another node, when the leaving node cannot get it through consensus
+// by itself.
+type LeaveRequest struct {
+	GenesisHash         string
+	InternalTransaction hashgraph.InternalTransaction
+}
+
+// LeaveResponse contains the response to a LeaveRequest.
+type LeaveResponse struct {
+	FromID        uint32
+	GenesisHash   string
+	Accepted      bool
+	AcceptedRound int
 }
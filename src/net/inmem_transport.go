@@ -114,6 +114,21 @@ func (i *InmemTransport) FastForward(target string, args *FastForwardRequest, re
 	return nil
 }
 
+// SnapshotChunk implements the Transport interface.
+func (i *InmemTransport) SnapshotChunk(target string, args *SnapshotChunkRequest, resp *SnapshotChunkResponse) error {
+	rpcResp, err := i.makeRPC(target, args, nil, i.timeout)
+	if err != nil {
+		return err
+	}
+
+	// Copy the result back
+	if out, ok := rpcResp.Response.(*SnapshotChunkResponse); ok {
+		*resp = *out
+	}
+
+	return nil
+}
+
 // Join implements the Transport interface
 func (i *InmemTransport) Join(target string, args *JoinRequest, resp *JoinResponse) error {
 	rpcResp, err := i.makeRPC(target, args, nil, i.timeout)
@@ -129,6 +144,21 @@ func (i *InmemTransport) Join(target string, args *JoinRequest, resp *JoinRespon
 	return nil
 }
 
+// Leave implements the Transport interface
+func (i *InmemTransport) Leave(target string, args *LeaveRequest, resp *LeaveResponse) error {
+	rpcResp, err := i.makeRPC(target, args, nil, i.timeout)
+	if err != nil {
+		return err
+	}
+
+	// Copy the result back
+	if out, ok := rpcResp.Response.(*LeaveResponse); ok {
+		*resp = *out
+	}
+
+	return nil
+}
+
 func (i *InmemTransport) makeRPC(target string, args interface{}, r io.Reader, timeout time.Duration) (rpcResp RPCResponse, err error) {
 	i.RLock()
 	peer, ok := i.peers[target]
@@ -139,11 +169,16 @@ func (i *InmemTransport) makeRPC(target string, args interface{}, r io.Reader, t
 		return
 	}
 
-	// Send the RPC over
-	respCh := make(chan RPCResponse)
-	peer.consumerCh <- RPC{
+	// Send the RPC over, unless the peer has stopped consuming RPCs
+	respCh := make(chan RPCResponse, 1)
+	select {
+	case peer.consumerCh <- RPC{
 		Command:  args,
 		RespChan: respCh,
+	}:
+	case <-time.After(timeout):
+		err = stacktrace.NewError("command enqueue timeout")
+		return
 	}
 
 	// Wait for a response
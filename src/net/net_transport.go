@@ -23,6 +23,8 @@ const (
 	rpcSync
 	rpcEagerSync
 	rpcFastForward
+	rpcLeave
+	rpcSnapshotChunk
 )
 
 const (
@@ -232,11 +234,21 @@ func (n *NetworkTransport) FastForward(target string, args *FastForwardRequest,
 	return n.genericRPC(target, rpcFastForward, n.timeout, args, resp)
 }
 
+// SnapshotChunk implements the Transport interface.
+func (n *NetworkTransport) SnapshotChunk(target string, args *SnapshotChunkRequest, resp *SnapshotChunkResponse) error {
+	return n.genericRPC(target, rpcSnapshotChunk, n.timeout, args, resp)
+}
+
 // Join implements the Transport interface.
 func (n *NetworkTransport) Join(target string, args *JoinRequest, resp *JoinResponse) error {
 	return n.genericRPC(target, rpcJoin, n.joinTimeout, args, resp)
 }
 
+// Leave implements the Transport interface.
+func (n *NetworkTransport) Leave(target string, args *LeaveRequest, resp *LeaveResponse) error {
+	return n.genericRPC(target, rpcLeave, n.joinTimeout, args, resp)
+}
+
 // genericRPC handles a simple request/response RPC.
 func (n *NetworkTransport) genericRPC(target string, rpcType uint8, timeout time.Duration, args interface{}, resp interface{}) error {
 	// Get a conn
@@ -392,12 +404,24 @@ func (n *NetworkTransport) handleCommand(r *bufio.Reader, dec *json.Decoder, enc
 			return err
 		}
 		rpc.Command = &req
+	case rpcSnapshotChunk:
+		var req SnapshotChunkRequest
+		if err := dec.Decode(&req); err != nil {
+			return err
+		}
+		rpc.Command = &req
 	case rpcJoin:
 		var req JoinRequest
 		if err := dec.Decode(&req); err != nil {
 			return err
 		}
 		rpc.Command = &req
+	case rpcLeave:
+		var req LeaveRequest
+		if err := dec.Decode(&req); err != nil {
+			return err
+		}
+		rpc.Command = &req
 	default:
 		return fmt.Errorf("unknown rpc type %d", rpcType)
 	}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gammazero/nexus/v3/client"
@@ -189,6 +190,66 @@ func (c *Client) Offer(target string, offer webrtc.SessionDescription) (*webrtc.
 
 }
 
+// Presence returns the identifiers of the clients that are currently listening
+// for offers, ie. the public keys of the peers connected to the signaling
+// server. It relies on the registration meta-procedures of the WAMP router,
+// whose own procedures are excluded.
+func (c *Client) Presence() ([]string, error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		c.config.ResponseTimeout,
+	)
+	defer cancel()
+
+	result, err := c.client.Call(ctx, string(wamp.MetaProcRegList), nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Arguments) == 0 {
+		return nil, errors.New("Empty registration list")
+	}
+
+	regs, ok := wamp.AsDict(result.Arguments[0])
+	if !ok {
+		return nil, errors.New("Error reading registration list")
+	}
+
+	exact, _ := wamp.AsList(regs[wamp.MatchExact])
+
+	res := []string{}
+	for _, r := range exact {
+		regID, ok := wamp.AsID(r)
+		if !ok {
+			continue
+		}
+
+		reg, err := c.client.Call(ctx, string(wamp.MetaProcRegGet), nil, wamp.List{regID}, nil, nil)
+		if err != nil {
+			// The registration could have been removed in the meantime
+			continue
+		}
+
+		if len(reg.Arguments) == 0 {
+			continue
+		}
+
+		details, ok := wamp.AsDict(reg.Arguments[0])
+		if !ok {
+			continue
+		}
+
+		uri, ok := wamp.AsString(details["uri"])
+		if !ok || strings.HasPrefix(uri, "wamp.") {
+			continue
+		}
+
+		res = append(res, uri)
+	}
+
+	return res, nil
+}
+
 // Consumer implements the Signal interface. It returns the channel through
 // which incoming WebRTC offers are received. The offers are wrapped insided
 // promises which provide an asynchronous response mechanism.
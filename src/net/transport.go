@@ -18,8 +18,8 @@ type Transport interface {
 	// can reach us
 	AdvertiseAddr() string
 
-	// Sync, EagerSync, FastForward, and Join send the appropriate RPC to the
-	// target node.
+	// Sync, EagerSync, FastForward, SnapshotChunk, Join, and Leave send the
+	// appropriate RPC to the target node.
 
 	Sync(target string, args *SyncRequest, resp *SyncResponse) error
 
@@ -27,8 +27,12 @@ type Transport interface {
 
 	FastForward(target string, args *FastForwardRequest, resp *FastForwardResponse) error
 
+	SnapshotChunk(target string, args *SnapshotChunkRequest, resp *SnapshotChunkResponse) error
+
 	Join(target string, args *JoinRequest, resp *JoinResponse) error
 
+	Leave(target string, args *LeaveRequest, resp *LeaveResponse) error
+
 	// Close permanently closes a transport, stopping any associated goroutines
 	// and freeing other resources.
 	Close() error
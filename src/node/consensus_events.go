@@ -0,0 +1,95 @@
+package node
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/proxy"
+)
+
+// consensusEventBufferSize is the capacity of the channels returned by
+// SubscribeConsensusEvents.
+const consensusEventBufferSize = 1000
+
+// consensusEventFeed relays consensus events to any number of subscribers. The
+// hashgraph must never wait for a subscriber, so events are dropped for
+// subscribers whose buffer is full. Subscribers can detect gaps from the
+// Position of the events they receive.
+type consensusEventFeed struct {
+	sync.Mutex
+	subscribers map[int]chan hg.ConsensusEvent
+	nextID      int
+}
+
+func newConsensusEventFeed() *consensusEventFeed {
+	return &consensusEventFeed{
+		subscribers: make(map[int]chan hg.ConsensusEvent),
+	}
+}
+
+func (f *consensusEventFeed) subscribe() (<-chan hg.ConsensusEvent, func()) {
+	f.Lock()
+	defer f.Unlock()
+
+	id := f.nextID
+	f.nextID++
+
+	ch := make(chan hg.ConsensusEvent, consensusEventBufferSize)
+	f.subscribers[id] = ch
+
+	unsubscribe := func() {
+		f.Lock()
+		defer f.Unlock()
+
+		if _, ok := f.subscribers[id]; ok {
+			delete(f.subscribers, id)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// publish sends the event to all the subscribers and returns the number of
+// subscribers that did not have room for it.
+func (f *consensusEventFeed) publish(event hg.ConsensusEvent) int {
+	f.Lock()
+	defer f.Unlock()
+
+	dropped := 0
+	for _, ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+			dropped++
+		}
+	}
+
+	return dropped
+}
+
+// SubscribeConsensusEvents returns a channel that receives every Event that
+// reaches consensus, in consensus order, and a function to cancel the
+// subscription. Events are dropped if the channel is not drained fast enough.
+func (n *Node) SubscribeConsensusEvents() (<-chan hg.ConsensusEvent, func()) {
+	return n.consensusEvents.subscribe()
+}
+
+// onConsensusEvent is called by the hashgraph for every consensus event. It
+// relays the event to the app, if the proxy supports it, and to subscribers.
+func (n *Node) onConsensusEvent(event hg.ConsensusEvent) {
+	if gateway, ok := n.proxy.(proxy.ConsensusEventGateway); ok {
+		if err := gateway.OnConsensusEvent(event); err != nil {
+			n.logger.WithError(err).Warn("Failed to relay consensus event")
+		}
+	}
+
+	if dropped := n.consensusEvents.publish(event); dropped > 0 {
+		n.logger.WithFields(logrus.Fields{
+			"position":    event.Position,
+			"subscribers": dropped,
+		}).Warn("Dropped consensus event for slow subscribers")
+	}
+}
@@ -22,7 +22,7 @@ type controlTimer struct {
 func newControlTimer(timerFactory timerFactory) *controlTimer {
 	return &controlTimer{
 		timerFactory: timerFactory,
-		tickCh:       make(chan struct{}),
+		tickCh:       make(chan struct{}, 1),
 		resetCh:      make(chan time.Duration),
 		stopCh:       make(chan struct{}),
 		shutdownCh:   make(chan struct{}),
@@ -55,8 +55,15 @@ func (c *controlTimer) run(init time.Duration) {
 	for {
 		select {
 		case <-timer:
-			c.tickCh <- struct{}{}
+			// Clear isSet before ticking, otherwise the listener could try to
+			// reset the timer before it is cleared, and the reset would be
+			// lost. The tick is buffered so that the loop is always ready to
+			// receive resets.
 			c.isSet = false
+			select {
+			case c.tickCh <- struct{}{}:
+			default:
+			}
 		case t := <-c.resetCh:
 			timer = setTimer(t)
 		case <-c.stopCh:
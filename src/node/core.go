@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/Kdag-K/kdag/src/common"
 	hg "github.com/Kdag-K/kdag/src/hashgraph"
@@ -54,6 +54,20 @@ type core struct {
 	// effect (if there is one). Default -1.
 	removedRound int
 
+	// nextValidator holds the new key of a pending key rotation, and
+	// rotatedRound is the round at which the rotation takes effect, once it has
+	// been accepted. Default -1.
+	nextValidator *Validator
+	rotatedRound  int
+
+	// retiringValidator holds the old key of a key rotation that has taken
+	// effect. It keeps extending its own chain of Events, with retiringHead
+	// and retiringSeq, until RotatedRound is decided, because the witnesses of
+	// the previous rounds still need to be strongly-seen through its Events.
+	retiringValidator *Validator
+	retiringHead      string
+	retiringSeq       int
+
 	// targetRound is the minimum Consensus Round that the node needs to reach.
 	// It is useful to set this value to a joining peer's accepted-round to
 	// prevent them from having to wait.
@@ -91,6 +105,26 @@ type core struct {
 	// requests when a node is in maintenance mode
 	maintenanceMode bool
 
+	// observer is true when the node follows consensus without being a
+	// validator. An observer never creates Events or signs Blocks.
+	observer bool
+
+	// joinAuthorizer applies the join policy of the Genesis to the PEER_ADD
+	// InternalTransactions accepted by the application.
+	joinAuthorizer JoinAuthorizer
+
+	// joinRefusals records the reasons why the joinAuthorizer refused
+	// InternalTransactions, to report them to the corresponding promises.
+	joinRefusals map[string]string
+
+	// peersCallback is called with the new list of peers every time accepted
+	// InternalTransactions change it.
+	peersCallback func(*peers.PeerSet)
+
+	// validatorsCallback is called every time accepted InternalTransactions
+	// change the validator-set.
+	validatorsCallback func(proxy.PeersChange)
+
 	// promises keeps track of pending JoinRequests while the corresponding
 	// InternalTransactions go through consensus asynchronously.
 	promises map[string]*joinPromise
@@ -121,12 +155,15 @@ func newCore(
 		internalTransactionPool: []hg.InternalTransaction{},
 		selfBlockSignatures:     hg.NewSigPool(),
 		promises:                make(map[string]*joinPromise),
+		joinAuthorizer:          JoinPolicy{},
+		joinRefusals:            make(map[string]string),
 		heads:                   make(map[uint32]*hg.Event),
 		logger:                  logger,
 		head:                    "",
 		seq:                     -1,
 		acceptedRound:           -1,
 		removedRound:            -1,
+		rotatedRound:            -1,
 		targetRound:             -1,
 		lastPeerChangeRound:     -1,
 		maintenanceMode:         maintenanceMode,
@@ -185,7 +222,15 @@ func (c *core) bootstrap() error {
 // setPeers sets the peers property and a New RandomPeerSelector
 func (c *core) setPeers(ps *peers.PeerSet) {
 	c.peers = ps
-	c.peerSelector = newRandomPeerSelector(c.peers, c.validator.ID())
+
+	// Until a key rotation takes effect, the new key of this node's validator
+	// can already be in the PeerSet. Do not gossip with ourselves.
+	selectable := ps
+	if c.nextValidator != nil {
+		selectable = ps.WithRemovedPeer(peers.NewPeer(c.nextValidator.PublicKeyHex(), "", ""))
+	}
+
+	c.peerSelector = newRandomPeerSelector(selectable, c.validator.ID())
 }
 
 /*******************************************************************************
@@ -210,26 +255,26 @@ Sync
 func (c *core) sync(fromID uint32, unknownEvents []hg.WireEvent) error {
 	c.logger.WithField("unknown_events", len(unknownEvents)).Debug("Sync")
 
+	// Events that are already known are skipped. That can happen when two
+	// concurrent pulls are trying to insert the same events. The creators are
+	// resolved as the Events are inserted, because the batch may contain the
+	// first Events of a validator that joined within the same batch.
+	inserted, insertErr := c.hg.InsertWireEventsAndRunConsensus(unknownEvents)
+
 	var otherHead *hg.Event
-	for _, we := range unknownEvents {
-		ev, err := c.hg.ReadWireInfo(we)
-		if err != nil {
-			c.logger.WithFields(logrus.Fields{
-				"wire_event": we,
-				"error":      err,
-			}).Error("Reading WireEvent")
-			return err
+	for i, ev := range inserted {
+		if ev == nil {
+			continue
 		}
 
-		// NormalSelfParentErrors are not reported. They can happen when two
-		// concurrent pulls are trying to insert the same events.
-		if err := c.insertEventAndRunConsensus(ev, false); err != nil {
-			if hg.IsNormalSelfParentError(err) {
-				continue
-			} else {
-				c.logger.WithError(err).Errorf("Inserting Event")
-				return err
-			}
+		we := unknownEvents[i]
+
+		if ev.Creator() == c.validator.PublicKeyHex() {
+			c.head = ev.Hex()
+			c.seq = ev.Index()
+		} else if c.retiringValidator != nil && ev.Creator() == c.retiringValidator.PublicKeyHex() {
+			c.retiringHead = ev.Hex()
+			c.retiringSeq = ev.Index()
 		}
 
 		if we.Body.CreatorID == fromID {
@@ -244,6 +289,11 @@ func (c *core) sync(fromID uint32, unknownEvents []hg.WireEvent) error {
 		}
 	}
 
+	if insertErr != nil {
+		c.logger.WithError(insertErr).Errorf("Inserting Event")
+		return insertErr
+	}
+
 	// Do not overwrite a non-empty head with an empty head
 	if h, ok := c.heads[fromID]; !ok ||
 		h == nil ||
@@ -260,6 +310,11 @@ func (c *core) sync(fromID uint32, unknownEvents []hg.WireEvent) error {
 		"target_round":              c.targetRound,
 	}).Debug("Sync")
 
+	// Observers do not record the heads
+	if c.observer {
+		return nil
+	}
+
 	// Create new event with self head and other head only if there are pending
 	// loaded events or the pools are not empty
 	if c.busy() ||
@@ -290,11 +345,31 @@ func (c *core) recordHeads() error {
 
 // addSelfEvent adds a self event
 func (c *core) addSelfEvent(otherHead string) error {
+	if c.observer {
+		return fmt.Errorf("Observers cannot create Events")
+	}
+
 	if c.hg.Store.LastRound() < c.acceptedRound {
 		c.logger.Debugf("Too early to insert self-event (%d / %d)", c.hg.Store.LastRound(), c.acceptedRound)
 		return nil
 	}
 
+	if c.nextValidator != nil && c.rotatedRound >= 0 && c.hg.Store.LastRound() >= c.rotatedRound {
+		c.rotateKey()
+	}
+
+	// The Events of the retiring validator must be ancestors of the new
+	// Events, so that other validators see them.
+	if c.retiringValidator != nil {
+		retiringHead, err := c.addRetiringEvent(otherHead)
+		if err != nil {
+			return err
+		}
+		if retiringHead != "" {
+			otherHead = retiringHead
+		}
+	}
+
 	// Add own block signatures to next Event
 	sigs := c.selfBlockSignatures.Slice()
 	txs := len(c.transactionPool)
@@ -332,6 +407,36 @@ func (c *core) addSelfEvent(otherHead string) error {
 	return nil
 }
 
+// addRetiringEvent extends the chain of the retiring validator of a key
+// rotation with an empty Event, until the rotation's effective round is
+// decided. It returns the hash of the new Event, or an empty string if the
+// retiring validator was retired.
+func (c *core) addRetiringEvent(otherHead string) (string, error) {
+	if c.hg.LastConsensusRound != nil && *c.hg.LastConsensusRound >= c.rotatedRound {
+		c.logger.WithField("old_key", c.retiringValidator.PublicKeyHex()).Debug("Retired old key")
+		c.retiringValidator = nil
+		c.rotatedRound = -1
+		return "", nil
+	}
+
+	event := hg.NewEvent([][]byte{},
+		[]hg.InternalTransaction{},
+		[]hg.BlockSignature{},
+		[]string{c.retiringHead, otherHead},
+		c.retiringValidator.PublicKeyBytes(),
+		c.retiringSeq+1)
+
+	if err := event.Sign(c.retiringValidator.Key); err != nil {
+		return "", err
+	}
+
+	if err := c.insertEventAndRunConsensus(event, true); err != nil {
+		return "", err
+	}
+
+	return event.Hex(), nil
+}
+
 // signAndInsertSelfEvent signs a Hashgraph Event, inserts it and runs
 // consensus.
 func (c *core) signAndInsertSelfEvent(event *hg.Event) error {
@@ -349,6 +454,9 @@ func (c *core) insertEventAndRunConsensus(event *hg.Event, setWireInfo bool) err
 	if event.Creator() == c.validator.PublicKeyHex() {
 		c.head = event.Hex()
 		c.seq = event.Index()
+	} else if c.retiringValidator != nil && event.Creator() == c.retiringValidator.PublicKeyHex() {
+		c.retiringHead = event.Hex()
+		c.retiringSeq = event.Index()
 	}
 	return nil
 }
@@ -410,72 +518,284 @@ func (c *core) getAnchorBlockWithFrame() (*hg.Block, *hg.Frame, error) {
 Leave
 *******************************************************************************/
 
-// leave causes the node to politely leave the network. If the node is not
-// alone, it submits an InternalTransaction to be removed from the
-// validator-set. Otherwise it does nothing.
-func (c *core) leave(leaveTimeout time.Duration) error {
+// newLeaveTransaction creates the InternalTransaction that removes this node's
+// validator from the validator-set, signed by the validator itself. It returns
+// nil if there is nothing to do: when the node is not a validator, when it is
+// the only validator, or when it is in maintenance mode.
+func (c *core) newLeaveTransaction() (*hg.InternalTransaction, error) {
 	// Do nothing if we are not a validator.
 	p, ok := c.validators.ByID[c.validator.ID()]
 	if !ok {
 		c.logger.Debugf("Leave: not a validator, do nothing")
-		return nil
+		return nil, nil
 	}
 
 	// Do nothing if we are the only validator.
 	if len(c.validators.Peers) <= 1 {
 		c.logger.Debugf("Leave: alone, do nothing")
-		return nil
+		return nil, nil
 	}
 
 	// Check for maintenance mode, if set no need for a leave request
 	if c.maintenanceMode {
 		c.logger.Debugf("Leave: maintenance mode, do nothing")
-		return nil
+		return nil, nil
 	}
 
-	// Otherwise, submit an InternalTransaction
-	c.logger.Debugf("Leave: submit InternalTransaction")
+	round, err := c.validatorRound(p.PubKeyString())
+	if err != nil {
+		return nil, err
+	}
 
-	itx := hg.NewInternalTransaction(hg.PEER_REMOVE, *p)
-	itx.Sign(c.validator.Key)
+	itx := hg.NewInternalTransactionLeave(*p, round)
+	if err := itx.Sign(c.validator.Key); err != nil {
+		return nil, err
+	}
 
-	promise := c.addInternalTransaction(itx)
+	return &itx, nil
+}
 
-	// Wait for the InternalTransaction to go through consensus
-	timeout := time.After(leaveTimeout)
-	select {
-	case resp := <-promise.respCh:
-		c.logger.WithFields(logrus.Fields{
-			"leaving_round": resp.acceptedRound,
-			"peers":         len(resp.peers),
-		}).Debug("leave request processed")
-	case <-timeout:
-		err := fmt.Errorf("Timeout waiting for leave request to go through consensus")
-		c.logger.WithError(err).Error()
-		return err
+// vote records the vote of a validator for a PEER_REMOVE or a PEER_WEIGHT,
+// and returns true when the voters hold a supermajority of the voting weight in
+// the validator-set. Votes are counted in the Hashgraph, so that all the nodes
+// agree on them, and they are bound to the round from which the peer has held
+// its place and weight. It returns an error if the vote is not valid. An
+// unreachable validator cannot leave by itself, but as long as the hashgraph
+// makes progress, the other validators hold a supermajority and can remove it.
+func (c *core) vote(validators *peers.PeerSet, txBody hg.InternalTransactionBody) (bool, error) {
+	target := txBody.Peer.PubKeyString()
+
+	voter := strings.ToUpper(txBody.Proposer)
+	if voter == "" {
+		voter = target
 	}
 
-	// Wait for node to reach RemovedRound
-	if c.peers.Len() >= 1 {
-		timeout = time.After(leaveTimeout)
-		for {
-			select {
-			case <-timeout:
-				err := fmt.Errorf("Timeout waiting for leaving node to reach TargetRound")
-				c.logger.WithError(err).Error()
-				return err
-			default:
-				if c.hg.LastConsensusRound != nil && *c.hg.LastConsensusRound < c.removedRound {
-					c.logger.Debugf("Waiting to reach RemovedRound: %d/%d", *c.hg.LastConsensusRound, c.removedRound)
-					time.Sleep(100 * time.Millisecond)
-				} else {
-					return nil
-				}
+	round, err := c.validatorRound(target)
+	if err != nil {
+		return false, err
+	}
+
+	if txBody.Round != round {
+		return false, fmt.Errorf("%s bound to round %d, but %s has held its place since round %d", txBody.Type, txBody.Round, target, round)
+	}
+
+	// A validator can always leave
+	if txBody.Type == hg.PEER_REMOVE && voter == target {
+		return true, nil
+	}
+
+	if _, ok := validators.ByPubKey[voter]; !ok {
+		return false, fmt.Errorf("%s proposed by a non-validator", txBody.Type)
+	}
+
+	proposal := removalProposal(target, round)
+	if txBody.Type == hg.PEER_WEIGHT {
+		proposal = weightProposal(target, round, txBody.Peer.Weight)
+	}
+
+	voters := c.hg.Votes.Add(proposal, voter)
+
+	// Only count the votes of the current validators
+	weight := 0
+	for _, pubKey := range voters {
+		weight += validators.WeightOf(pubKey)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"type":           txBody.Type.String(),
+		"peer":           target,
+		"voter":          voter,
+		"votes_weight":   weight,
+		"super_majority": validators.SuperMajority(),
+	}).Info("Vote")
+
+	return weight >= validators.SuperMajority(), nil
+}
+
+// forgetVotes forgets all the votes to remove a validator or change its
+// weight, when it is removed or its weight changes.
+func (c *core) forgetVotes(pubKey string) {
+	round, err := c.validatorRound(pubKey)
+	if err != nil {
+		return
+	}
+
+	c.hg.Votes.Remove(removalProposal(pubKey, round))
+	c.hg.Votes.RemovePrefix(fmt.Sprintf("%s:%s:%d:", hg.PEER_WEIGHT, pubKey, round))
+}
+
+// validatorRound returns the round from which a validator has held its current
+// place and weight, according to the recorded validator-sets.
+func (c *core) validatorRound(pubKey string) (int, error) {
+	history, err := c.hg.Store.GetAllPeerSets()
+	if err != nil {
+		return 0, err
+	}
+
+	round, ok := hg.ValidatorRound(history, pubKey)
+	if !ok {
+		return 0, fmt.Errorf("%s is not a validator", pubKey)
+	}
+
+	return round, nil
+}
+
+// removedRoundOf returns the round from which a peer was removed from the
+// validator-set, according to the recorded validator-sets.
+func (c *core) removedRoundOf(pubKey string) (int, bool) {
+	history, err := c.hg.Store.GetAllPeerSets()
+	if err != nil {
+		return 0, false
+	}
+
+	return hg.RemovedRound(history, pubKey)
+}
+
+// removalProposal identifies the votes to remove a validator in the
+// Hashgraph's Votes.
+func removalProposal(pubKey string, round int) string {
+	return fmt.Sprintf("%s:%s:%d", hg.PEER_REMOVE, pubKey, round)
+}
+
+// weightProposal identifies the votes to change the weight of a validator in
+// the Hashgraph's Votes.
+func weightProposal(pubKey string, round int, weight int) string {
+	return fmt.Sprintf("%s:%s:%d:%d", hg.PEER_WEIGHT, pubKey, round, weight)
+}
+
+/*******************************************************************************
+Weight
+*******************************************************************************/
+
+// newWeightTransaction creates and signs an InternalTransaction by which this
+// node votes to change the voting weight of a validator.
+func (c *core) newWeightTransaction(pubKey string, weight int) (hg.InternalTransaction, error) {
+	if weight < 1 {
+		return hg.InternalTransaction{}, fmt.Errorf("Weight must be at least 1, not %d", weight)
+	}
+
+	if _, ok := c.validators.ByID[c.validator.ID()]; !ok {
+		return hg.InternalTransaction{}, fmt.Errorf("Not a validator")
+	}
+
+	p, ok := c.validators.ByPubKey[strings.ToUpper(pubKey)]
+	if !ok {
+		return hg.InternalTransaction{}, fmt.Errorf("%s is not a validator", pubKey)
+	}
+
+	round, err := c.validatorRound(p.PubKeyString())
+	if err != nil {
+		return hg.InternalTransaction{}, err
+	}
+
+	itx := hg.NewInternalTransactionWeight(*p, weight, c.validator.PublicKeyHex(), round)
+	if err := itx.Sign(c.validator.Key); err != nil {
+		return hg.InternalTransaction{}, err
+	}
+
+	return itx, nil
+}
+
+// newUpdateTransaction creates an InternalTransaction to change the NetAddr and
+// Moniker of this node's validator.
+func (c *core) newUpdateTransaction(netAddr string, moniker string) (hg.InternalTransaction, error) {
+	p, ok := c.validators.ByID[c.validator.ID()]
+	if !ok {
+		return hg.InternalTransaction{}, fmt.Errorf("Not a validator")
+	}
+
+	peer := *p
+	peer.NetAddr = netAddr
+	peer.Moniker = moniker
+
+	itx := hg.NewInternalTransactionUpdate(peer)
+	if err := itx.Sign(c.validator.Key); err != nil {
+		return hg.InternalTransaction{}, err
+	}
+
+	return itx, nil
+}
+
+// newRotationTransaction creates an InternalTransaction, signed by the current
+// and the next key, to replace the public key of this node's validator. The
+// next validator is used to create Events once the rotation takes effect.
+func (c *core) newRotationTransaction(next *Validator) (hg.InternalTransaction, error) {
+	p, ok := c.validators.ByID[c.validator.ID()]
+	if !ok {
+		return hg.InternalTransaction{}, fmt.Errorf("Not a validator")
+	}
+
+	if c.keyUsed(c.validators, next.PublicKeyHex()) {
+		return hg.InternalTransaction{}, fmt.Errorf("New key has already been used")
+	}
+
+	itx := hg.NewInternalTransactionRotation(*p, next.PublicKeyHex())
+	if err := itx.Sign(c.validator.Key); err != nil {
+		return hg.InternalTransaction{}, err
+	}
+	if err := itx.SignRotation(next.Key); err != nil {
+		return hg.InternalTransaction{}, err
+	}
+
+	c.nextValidator = next
+
+	return itx, nil
+}
+
+// keyUsed returns true if a public key belongs to the given validator-set, or
+// belonged to any of the recorded validator-sets. Only validators create
+// Events, and all the nodes, including those that fast-forwarded, have the
+// same history of validator-sets, so they agree on the keys that were used.
+func (c *core) keyUsed(validators *peers.PeerSet, pubKey string) bool {
+	pubKey = strings.ToUpper(pubKey)
+
+	if _, ok := validators.ByPubKey[pubKey]; ok {
+		return true
+	}
+
+	history, err := c.hg.Store.GetAllPeerSets()
+	if err != nil {
+		c.logger.WithError(err).Error("Reading validator-sets")
+		return true
+	}
+
+	for _, peerSet := range history {
+		for _, p := range peerSet {
+			if p.PubKeyString() == pubKey {
+				return true
 			}
 		}
 	}
 
-	return nil
+	return false
+}
+
+// rotateKey replaces the validator with the next validator of an accepted key
+// rotation. The new key starts a new chain of Events, like a joining peer, and
+// the old key becomes the retiring validator.
+func (c *core) rotateKey() {
+	c.logger.WithFields(logrus.Fields{
+		"old_key": c.validator.PublicKeyHex(),
+		"new_key": c.nextValidator.PublicKeyHex(),
+		"round":   c.rotatedRound,
+	}).Info("Rotating validator key")
+
+	c.retiringValidator = c.validator
+	c.retiringHead = c.head
+	c.retiringSeq = c.seq
+
+	c.validator = c.nextValidator
+	c.head = ""
+	c.seq = -1
+
+	// Pending block-signatures were created by the old key, but the
+	// block-signatures of an Event are attributed to its creator.
+	c.selfBlockSignatures = hg.NewSigPool()
+
+	c.nextValidator = nil
+
+	// the peer-selector excludes this node by ID
+	c.setPeers(c.peers)
 }
 
 /*******************************************************************************
@@ -506,7 +826,7 @@ func (c *core) commit(block *hg.Block) error {
 	// might update the PeerSet.
 	if err == nil {
 		block.Body.StateHash = commitResponse.StateHash
-		block.Body.InternalTransactionReceipts = commitResponse.InternalTransactionReceipts
+		block.Body.InternalTransactionReceipts = c.authorizeJoins(block.RoundReceived(), commitResponse.InternalTransactionReceipts)
 
 		// Sign the block if we belong to its validator-set
 		blockPeerSet, err := c.hg.Store.GetPeerSet(block.RoundReceived())
@@ -514,7 +834,7 @@ func (c *core) commit(block *hg.Block) error {
 			return err
 		}
 
-		if _, ok := blockPeerSet.ByID[c.validator.ID()]; ok {
+		if _, ok := blockPeerSet.ByID[c.validator.ID()]; ok && !c.observer {
 			sig, err := c.signBlock(block)
 			if err != nil {
 				return err
@@ -527,7 +847,7 @@ func (c *core) commit(block *hg.Block) error {
 			return err
 		}
 
-		err = c.processAcceptedInternalTransactions(block.RoundReceived(), commitResponse.InternalTransactionReceipts)
+		err = c.processAcceptedInternalTransactions(block.RoundReceived(), block.Body.InternalTransactionReceipts)
 		if err != nil {
 			return err
 		}
@@ -556,6 +876,35 @@ func (c *core) signBlock(block *hg.Block) (hg.BlockSignature, error) {
 	return sig, nil
 }
 
+// authorizeJoins refuses the PEER_ADD InternalTransactions, accepted by the
+// application, that the joinAuthorizer does not authorize at the block's round.
+// The joins accepted earlier in the same block count towards the validator-set
+// they are authorized against.
+func (c *core) authorizeJoins(round int, receipts []hg.InternalTransactionReceipt) []hg.InternalTransactionReceipt {
+	validators := c.validators
+
+	res := make([]hg.InternalTransactionReceipt, len(receipts))
+	for i, r := range receipts {
+		res[i] = r
+
+		if !r.Accepted || r.InternalTransaction.Body.Type != hg.PEER_ADD {
+			continue
+		}
+
+		if err := c.joinAuthorizer.Authorize(r.InternalTransaction, validators, round); err != nil {
+			c.logger.WithError(err).WithField("peer", r.InternalTransaction.Body.Peer).Info("Join refused")
+			c.joinRefusals[r.InternalTransaction.HashString()] = err.Error()
+			res[i] = r.InternalTransaction.AsRefused()
+			continue
+		}
+
+		peer := r.InternalTransaction.Body.Peer
+		validators = validators.WithNewPeer(&peer)
+	}
+
+	return res
+}
+
 // processAcceptedInternalTransactions processes a list of
 // InternalTransactionReceipts from a block, updates the PeerSet for the
 // corresponding round (round-received + 6), and responds to eventual promises.
@@ -568,9 +917,19 @@ func (c *core) processAcceptedInternalTransactions(roundReceived int, receipts [
 	// round r+5 or before; so it is safe to set the new peer-set at round r+6.
 	effectiveRound := roundReceived + 6
 
+	// Accepted InternalTransactions that only recorded a vote, and those that
+	// were refused by the validators, with the reason.
+	pending := make(map[string]bool)
+	refused := make(map[string]string)
+
+	// Leave requests of peers that were already removed, with the round at
+	// which they were removed.
+	removed := make(map[string]int)
+
 	changed := false
 	for _, r := range receipts {
 		txBody := r.InternalTransaction.Body
+		hash := r.InternalTransaction.HashString()
 
 		if r.Accepted {
 			c.logger.WithFields(logrus.Fields{
@@ -581,9 +940,40 @@ func (c *core) processAcceptedInternalTransactions(roundReceived int, receipts [
 
 			switch txBody.Type {
 			case hg.PEER_ADD:
-				validators = validators.WithNewPeer(&txBody.Peer)
-				currentPeers = currentPeers.WithNewPeer(&txBody.Peer)
+				// Joining peers start with the default weight, which can
+				// only be changed through PEER_WEIGHT.
+				peer := txBody.Peer
+				peer.Weight = 0
+				validators = validators.WithNewPeer(&peer)
+				currentPeers = currentPeers.WithNewPeer(&peer)
 			case hg.PEER_REMOVE:
+				// A leave request can be submitted through several nodes
+				if _, ok := validators.ByPubKey[txBody.Peer.PubKeyString()]; !ok {
+					c.logger.WithField("peer", txBody.Peer).Debug("Removing a peer that is not a validator")
+					if txBody.Proposer != "" {
+						refused[hash] = fmt.Sprintf("%s is not a validator", txBody.Peer.PubKeyString())
+					} else if round, ok := c.removedRoundOf(txBody.Peer.PubKeyString()); ok {
+						removed[hash] = round
+					}
+					continue
+				}
+
+				// Removals, proposed by other validators or by the peer
+				// itself, are bound to the round from which the peer has been
+				// a validator, so they cannot be replayed after it rejoins.
+				done, err := c.vote(validators, txBody)
+				if err != nil {
+					c.logger.WithError(err).WithField("peer", txBody.Peer).Warn("Removal refused")
+					refused[hash] = err.Error()
+					continue
+				}
+				if !done {
+					pending[hash] = true
+					continue
+				}
+
+				c.forgetVotes(txBody.Peer.PubKeyString())
+
 				validators = validators.WithRemovedPeer(&txBody.Peer)
 				currentPeers = currentPeers.WithRemovedPeer(&txBody.Peer)
 
@@ -592,6 +982,53 @@ func (c *core) processAcceptedInternalTransactions(roundReceived int, receipts [
 					c.logger.Debugf("Update RemovedRound from %d to %d", c.removedRound, effectiveRound)
 					c.removedRound = effectiveRound
 				}
+			case hg.PEER_WEIGHT:
+				if _, ok := validators.ByPubKey[txBody.Peer.PubKeyString()]; !ok {
+					refused[hash] = fmt.Sprintf("%s is not a validator", txBody.Peer.PubKeyString())
+					continue
+				}
+
+				// Weight changes need the votes of a supermajority
+				done, err := c.vote(validators, txBody)
+				if err != nil {
+					c.logger.WithError(err).WithField("peer", txBody.Peer).Warn("Weight vote refused")
+					refused[hash] = err.Error()
+					continue
+				}
+				if !done {
+					pending[hash] = true
+					continue
+				}
+
+				c.forgetVotes(txBody.Peer.PubKeyString())
+
+				validators = validators.WithPeerWeight(&txBody.Peer)
+				currentPeers = currentPeers.WithPeerWeight(&txBody.Peer)
+			case hg.PEER_ROTATE_KEY:
+				// A key that was already used would collide with its Events
+				if c.keyUsed(validators, txBody.NewPubKey) {
+					c.logger.WithField("new_key", txBody.NewPubKey).Warn("Key rotation to a used key")
+					refused[hash] = "New key has already been used"
+					continue
+				}
+
+				validators = validators.WithRotatedKey(&txBody.Peer, txBody.NewPubKey)
+				currentPeers = currentPeers.WithRotatedKey(&txBody.Peer, txBody.NewPubKey)
+
+				// Switch keys at the effective round if rotating self
+				if c.nextValidator != nil &&
+					txBody.Peer.ID() == c.validator.ID() &&
+					strings.ToUpper(txBody.NewPubKey) == strings.ToUpper(c.nextValidator.PublicKeyHex()) {
+					c.logger.Debugf("Update RotatedRound from %d to %d", c.rotatedRound, effectiveRound)
+					c.rotatedRound = effectiveRound
+				}
+			case hg.PEER_UPDATE:
+				validators = validators.WithUpdatedPeer(&txBody.Peer)
+				currentPeers = currentPeers.WithUpdatedPeer(&txBody.Peer)
+
+				if txBody.Peer.ID() == c.validator.ID() {
+					c.validator.Moniker = txBody.Peer.Moniker
+				}
 			default:
 				c.logger.Errorf("Unknown InternalTransactionType %s", txBody.Type)
 				continue
@@ -613,6 +1050,7 @@ func (c *core) processAcceptedInternalTransactions(roundReceived int, receipts [
 			return fmt.Errorf("Updating Store PeerSet: %s", err)
 		}
 
+		oldValidators := c.validators
 		c.validators = validators
 
 		c.logger.WithFields(logrus.Fields{
@@ -620,10 +1058,18 @@ func (c *core) processAcceptedInternalTransactions(roundReceived int, receipts [
 			"validators":      len(validators.Peers),
 		}).Info("Validators changed")
 
+		if c.validatorsCallback != nil {
+			c.validatorsCallback(proxy.NewPeersChange(roundReceived, effectiveRound, oldValidators, validators))
+		}
+
 		// Update the current list of communicating peers. This is not
 		// necessarily equal to the latest recorded validator_set.
 		c.setPeers(currentPeers)
 
+		if c.peersCallback != nil {
+			c.peersCallback(currentPeers)
+		}
+
 		// A new validator-set has been recorded and will only be effective from
 		// effectiveRound. A joining node will not be able to participate in the
 		// consensus until the Hashgraph reaches that effectiveRound. Hence, we
@@ -636,14 +1082,23 @@ func (c *core) processAcceptedInternalTransactions(roundReceived int, receipts [
 
 	for _, r := range receipts {
 		//respond to the corresponding promise
-		if p, ok := c.promises[r.InternalTransaction.HashString()]; ok {
-			if r.Accepted {
+		hash := r.InternalTransaction.HashString()
+		if p, ok := c.promises[hash]; ok {
+			switch {
+			case !r.Accepted:
+				p.refuse(c.joinRefusals[hash])
+			case refused[hash] != "":
+				p.refuse(refused[hash])
+			case pending[hash]:
+				p.pend()
+			case removed[hash] > 0:
+				p.respond(true, removed[hash], c.validators.Peers)
+			default:
 				p.respond(true, effectiveRound, c.validators.Peers)
-			} else {
-				p.respond(false, 0, []*peers.Peer{})
 			}
-			delete(c.promises, r.InternalTransaction.HashString())
+			delete(c.promises, hash)
 		}
+		delete(c.joinRefusals, hash)
 	}
 
 	return nil
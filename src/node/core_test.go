@@ -375,6 +375,55 @@ func initConsensusHashgraph(t *testing.T) []*core {
 	return cores
 }
 
+/*
+initR2DynHashgraph creates a hashgraph with 3 validators, where a 4th peer, Bob,
+requests to join with a PEER_ADD InternalTransaction inserted after the first
+Block, so that fast-forwarding from Block 0 replays the join. Bob is added to the validator-set, but never gossips, so the 3 initial
+validators, which hold a supermajority, keep deciding rounds without him.
+*/
+func initR2DynHashgraph(t *testing.T) ([]*core, *peers.Peer, *ecdsa.PrivateKey) {
+	cores, _, _ := initCores(3, t)
+
+	bobKey, _ := keys.GenerateECDSAKey()
+	bobPeer := peers.NewPeer(keys.PublicKeyHex(&bobKey.PublicKey), "", "bob")
+
+	joinTx := hg.NewInternalTransactionJoin(*bobPeer)
+	if err := joinTx.Sign(bobKey); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 12; i++ {
+		playbook := []play{
+			{from: 0, to: 1, payload: [][]byte{[]byte("x" + strconv.Itoa(i) + "10")}},
+			{from: 1, to: 2, payload: [][]byte{[]byte("x" + strconv.Itoa(i) + "21")}},
+			{from: 2, to: 0, payload: [][]byte{[]byte("x" + strconv.Itoa(i) + "02")}},
+		}
+
+		if i == 2 {
+			playbook[1].internalTxs = []hg.InternalTransaction{joinTx}
+		}
+
+		for _, play := range playbook {
+			if err := syncAndRunConsensus(cores, play.from, play.to, play.payload, play.internalTxs); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return cores, bobPeer, bobKey
+}
+
+// clonePeerSet returns a PeerSet with copies of the given peers, so that the
+// genesis peer-set of a core is not modified along with its current one.
+func clonePeerSet(t *testing.T, ps []*peers.Peer) *peers.PeerSet {
+	clone := make([]*peers.Peer, len(ps))
+	for i, p := range ps {
+		clone[i] = peers.NewPeer(p.PubKeyHex, p.NetAddr, p.Moniker)
+		clone[i].Weight = p.Weight
+	}
+	return peers.NewPeerSet(clone)
+}
+
 func TestConsensus(t *testing.T) {
 	cores := initConsensusHashgraph(t)
 
@@ -512,9 +561,9 @@ func TestCoreFastForwardAfterJoin(t *testing.T) {
 		}
 
 		expectedKnown := map[uint32]int{
-			cores[0].validator.ID(): 9,
-			cores[1].validator.ID(): 15,
-			cores[2].validator.ID(): 10,
+			cores[0].validator.ID(): 11,
+			cores[1].validator.ID(): 12,
+			cores[2].validator.ID(): 12,
 			cores[3].validator.ID(): 0,
 		}
 
@@ -566,7 +615,7 @@ func TestCoreFastForwardAfterJoin(t *testing.T) {
 			Check PeerSets
 		***********************************************************************/
 
-		for i := p.roundLowerBound; i <= 8; i++ {
+		for i := p.roundLowerBound; i <= 10; i++ {
 			c3PS, err := cores[3].hg.Store.GetPeerSet(i)
 			if err != nil {
 				t.Fatal(err)
@@ -587,7 +636,7 @@ func TestCoreFastForwardAfterJoin(t *testing.T) {
 		***********************************************************************/
 
 		if r := cores[3].getLastConsensusRoundIndex(); r == nil || *r != 6 {
-			t.Fatalf("Cores[3] last consensus Round should be 4, not %v", *r)
+			t.Fatalf("Cores[3] last consensus Round should be 6, not %v", *r)
 		}
 
 		if lbi := cores[3].hg.Store.LastBlockIndex(); lbi != 5 {
@@ -597,6 +646,58 @@ func TestCoreFastForwardAfterJoin(t *testing.T) {
 
 }
 
+// TestSyncJoinAndJoinerEvents checks that a sync can contain the join of a new
+// validator followed by its first Events, whose creator is only known once
+// the join is committed.
+func TestSyncJoinAndJoinerEvents(t *testing.T) {
+	cores, bobPeer, bobKey := initR2DynHashgraph(t)
+
+	initPeerSet, err := cores[0].hg.Store.GetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestCore := func(key *ecdsa.PrivateKey, moniker string) *core {
+		c := newCore(
+			NewValidator(key, moniker),
+			clonePeerSet(t, initPeerSet.Peers),
+			clonePeerSet(t, initPeerSet.Peers),
+			hg.NewInmemStore(1000),
+			proxy.DummyCommitCallback,
+			false,
+			common.NewTestEntry(t, common.TestLogLevel))
+		c.setHeadAndSeq()
+		return c
+	}
+
+	// Bob syncs the whole hashgraph, including his join, and creates his first
+	// Event on top of it.
+	bobCore := newTestCore(bobKey, bobPeer.Moniker)
+	cores = append(cores, bobCore)
+
+	if err := syncAndRunConsensus(cores, 2, 3, [][]byte{[]byte("bob")}, []hg.InternalTransaction{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if bobCore.seq != 0 {
+		t.Fatalf("Bob should have created his first Event, seq is %d", bobCore.seq)
+	}
+
+	// An observer receives the join and Bob's first Event in the same sync
+	observerKey, _ := keys.GenerateECDSAKey()
+	observer := newTestCore(observerKey, "observer")
+	observer.observer = true
+	cores = append(cores, observer)
+
+	if err := syncAndRunConsensus(cores, 3, 4, [][]byte{}, []hg.InternalTransaction{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if known := observer.knownEvents()[bobCore.validator.ID()]; known != 0 {
+		t.Fatalf("Observer should know Bob's first Event, known index is %d", known)
+	}
+}
+
 /******************************************************************************/
 
 func synchronizeCores(cores []*core, from int, to int, payload [][]byte, internalTxs []hg.InternalTransaction) error {
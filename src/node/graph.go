@@ -112,6 +112,15 @@ func (g *Graph) GetBlocks() []*hg.Block {
 	return res
 }
 
+// GetDAG returns a window of the Hashgraph annotated with the results of the
+// consensus algorithm, cf. hashgraph.ExportDAG.
+func (g *Graph) GetDAG(opts hg.DAGOptions) (*hg.DAG, error) {
+	g.Node.coreLock.Lock()
+	defer g.Node.coreLock.Unlock()
+
+	return hg.ExportDAG(g.Node.core.hg.Store, opts)
+}
+
 // GetInfos returns an Infos struct representing the entire Hashgraph.
 func (g *Graph) GetInfos() (Infos, error) {
 	participantEvents, err := g.GetParticipantEvents()
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Kdag-K/kdag/src/genesis"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// JoinAuthorizer decides whether a peer may join the validator-set. It is
+// consulted when a node receives a JoinRequest, to refuse it early, and again
+// when the PEER_ADD InternalTransaction is committed, where a refusal overrides
+// the application's receipt. The decision only depends on the InternalTransaction
+// and on the consensus state, so that all the validators produce the same Block.
+type JoinAuthorizer interface {
+	// Authorize returns an error, whose message is the reason of the refusal,
+	// if the PEER_ADD should be refused given the current validator-set and
+	// the round at which it is committed.
+	Authorize(itx hg.InternalTransaction, validators *peers.PeerSet, round int) error
+}
+
+// JoinPolicy is a JoinAuthorizer that requires the authorization of all its
+// members. An empty JoinPolicy authorizes every join request.
+type JoinPolicy []JoinAuthorizer
+
+// Authorize implements the JoinAuthorizer interface.
+func (jp JoinPolicy) Authorize(itx hg.InternalTransaction, validators *peers.PeerSet, round int) error {
+	for _, a := range jp {
+		if err := a.Authorize(itx, validators, round); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewJoinPolicy creates the JoinPolicy defined in a Genesis. A nil policy, as
+// in networks without a Genesis, authorizes every join request; applications
+// that need other rules can refuse PEER_ADD InternalTransactions in their
+// receipts.
+func NewJoinPolicy(p *genesis.JoinPolicy) JoinPolicy {
+	policy := JoinPolicy{}
+
+	if p == nil {
+		return policy
+	}
+
+	if len(p.Allowlist) > 0 {
+		policy = append(policy, NewAllowlistAuthorizer(p.Allowlist))
+	}
+
+	if p.RequireInvite {
+		policy = append(policy, &InviteAuthorizer{})
+	}
+
+	if p.MaxValidators > 0 {
+		policy = append(policy, &MaxValidatorsAuthorizer{Max: p.MaxValidators})
+	}
+
+	return policy
+}
+
+// AllowlistAuthorizer only authorizes the peers whose public key is listed in
+// a static allowlist.
+type AllowlistAuthorizer struct {
+	pubKeys map[string]bool
+}
+
+// NewAllowlistAuthorizer creates an AllowlistAuthorizer from a list of public
+// keys.
+func NewAllowlistAuthorizer(pubKeys []string) *AllowlistAuthorizer {
+	allowed := make(map[string]bool, len(pubKeys))
+	for _, pk := range pubKeys {
+		allowed[normalizePubKey(pk)] = true
+	}
+	return &AllowlistAuthorizer{pubKeys: allowed}
+}
+
+// Authorize implements the JoinAuthorizer interface.
+func (a *AllowlistAuthorizer) Authorize(itx hg.InternalTransaction, validators *peers.PeerSet, round int) error {
+	if !a.pubKeys[normalizePubKey(itx.Body.Peer.PubKeyHex)] {
+		return fmt.Errorf("Peer is not in the allowlist")
+	}
+	return nil
+}
+
+// InviteAuthorizer only authorizes the peers that present an invite created by
+// a current validator, cf. hashgraph.NewJoinInvite. An invite can only be used
+// until its expiry round; after that, the peer needs a new invite to join
+// again.
+type InviteAuthorizer struct{}
+
+// Authorize implements the JoinAuthorizer interface.
+func (a *InviteAuthorizer) Authorize(itx hg.InternalTransaction, validators *peers.PeerSet, round int) error {
+	if itx.Body.Invite == "" {
+		return fmt.Errorf("An invite is required")
+	}
+
+	inviter, expiry, err := hg.VerifyJoinInvite(itx.Body.Invite, itx.Body.Peer.PubKeyHex)
+	if err != nil {
+		return err
+	}
+
+	if round > expiry {
+		return fmt.Errorf("Invite expired at round %d", expiry)
+	}
+
+	if _, ok := validators.ByPubKey[inviter]; !ok {
+		return fmt.Errorf("Invite was not created by a validator")
+	}
+
+	return nil
+}
+
+// MaxValidatorsAuthorizer refuses new peers when the validator-set reaches a
+// maximum size.
+type MaxValidatorsAuthorizer struct {
+	Max int
+}
+
+// Authorize implements the JoinAuthorizer interface.
+func (a *MaxValidatorsAuthorizer) Authorize(itx hg.InternalTransaction, validators *peers.PeerSet, round int) error {
+	if validators.Len() >= a.Max {
+		return fmt.Errorf("Validator-set is full (%d validators)", a.Max)
+	}
+	return nil
+}
+
+func normalizePubKey(pubKey string) string {
+	return "0X" + strings.TrimPrefix(strings.ToUpper(pubKey), "0X")
+}
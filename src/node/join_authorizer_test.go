@@ -0,0 +1,96 @@
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/genesis"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+func TestJoinPolicy(t *testing.T) {
+	validatorKey, _ := keys.GenerateECDSAKey()
+	allowedKey, _ := keys.GenerateECDSAKey()
+	strangerKey, _ := keys.GenerateECDSAKey()
+
+	validators := peers.NewPeerSet([]*peers.Peer{
+		peers.NewPeer(keys.PublicKeyHex(&validatorKey.PublicKey), "", "validator"),
+	})
+
+	allowed := keys.PublicKeyHex(&allowedKey.PublicKey)
+	stranger := keys.PublicKeyHex(&strangerKey.PublicKey)
+
+	join := func(pubKey string, invite string) hg.InternalTransaction {
+		itx := hg.NewInternalTransactionJoin(*peers.NewPeer(pubKey, "", ""))
+		itx.Body.Invite = invite
+		return itx
+	}
+
+	allowlistAuthorizer := NewAllowlistAuthorizer([]string{strings.ToLower(allowed)})
+
+	if err := allowlistAuthorizer.Authorize(join(allowed, ""), validators, 0); err != nil {
+		t.Fatalf("Allowed peer should be authorized: %s", err)
+	}
+
+	if err := allowlistAuthorizer.Authorize(join(stranger, ""), validators, 0); err == nil {
+		t.Fatal("Peer not in allowlist should not be authorized")
+	}
+
+	inviteAuthorizer := &InviteAuthorizer{}
+
+	invite, err := hg.NewJoinInvite(validatorKey, stranger, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inviteAuthorizer.Authorize(join(stranger, invite), validators, 10); err != nil {
+		t.Fatalf("Invited peer should be authorized: %s", err)
+	}
+
+	if err := inviteAuthorizer.Authorize(join(stranger, invite), validators, 11); err == nil {
+		t.Fatal("Expired invite should not be valid")
+	}
+
+	if err := inviteAuthorizer.Authorize(join(stranger, ""), validators, 0); err == nil {
+		t.Fatal("Peer without invite should not be authorized")
+	}
+
+	notValidatorInvite, _ := hg.NewJoinInvite(allowedKey, stranger, 10)
+	if err := inviteAuthorizer.Authorize(join(stranger, notValidatorInvite), validators, 0); err == nil {
+		t.Fatal("Invite created by a non-validator should not be valid")
+	}
+
+	maxAuthorizer := &MaxValidatorsAuthorizer{Max: 2}
+
+	if err := maxAuthorizer.Authorize(join(stranger, ""), validators, 0); err != nil {
+		t.Fatalf("Peer should be authorized below max validators: %s", err)
+	}
+
+	full := validators.WithNewPeer(peers.NewPeer(allowed, "", ""))
+	if err := maxAuthorizer.Authorize(join(stranger, ""), full, 0); err == nil {
+		t.Fatal("Peer should not be authorized when the validator-set is full")
+	}
+
+	policy := NewJoinPolicy(&genesis.JoinPolicy{
+		Allowlist:     []string{allowed},
+		MaxValidators: 2,
+	})
+
+	if len(policy) != 2 {
+		t.Fatalf("Policy should have 2 authorizers, not %d", len(policy))
+	}
+
+	if err := policy.Authorize(join(allowed, ""), validators, 0); err != nil {
+		t.Fatalf("Policy should authorize allowed peer: %s", err)
+	}
+
+	if err := policy.Authorize(join(allowed, ""), full, 0); err == nil {
+		t.Fatal("Policy should refuse when any authorizer refuses")
+	}
+
+	if err := NewJoinPolicy(nil).Authorize(join(stranger, ""), full, 0); err != nil {
+		t.Fatalf("Empty policy should authorize everyone: %s", err)
+	}
+}
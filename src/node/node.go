@@ -1,15 +1,23 @@
 package node
 
 import (
+	"crypto/ecdsa"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/Kdag-K/kdag/src/common"
 	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/discovery"
 	hg "github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/net"
 	_state "github.com/Kdag-K/kdag/src/node/state"
@@ -26,6 +34,18 @@ type Node struct {
 
 	conf *config.Config
 
+	// genesisHash identifies the node's network. It is empty if the node was
+	// not configured with a Genesis.
+	genesisHash string
+
+	// discovery finds other peers to contact when joining or fast-forwarding,
+	// on top of the known peers. It is optional.
+	discovery *discovery.Discovery
+
+	// peerStore persists the list of peers whenever it changes. It is
+	// optional.
+	peerStore *peers.JSONPeerSet
+
 	logger *logrus.Entry
 
 	// core is the link between the node and the underlying hashgraph. It
@@ -44,6 +64,14 @@ type Node struct {
 	// transactions from the application to Kdag.
 	proxy proxy.AppGateway
 
+	// consensusEvents relays the events that reach consensus to subscribers of
+	// the event stream.
+	consensusEvents *consensusEventFeed
+
+	// snapshots keeps the chunks of the snapshots served to fast-syncing
+	// nodes.
+	snapshots *snapshotStore
+
 	// submitCh is where the node listens for incoming transactions to be
 	// submitted to Kdag
 	submitCh chan []byte
@@ -86,7 +114,7 @@ func NewNode(conf *config.Config,
 ) *Node {
 
 	// Prepare sigCh to relay SIGINT and SIGTERM system calls
-	sigCh := make(chan os.Signal)
+	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	core := newCore(validator,
@@ -97,11 +125,18 @@ func NewNode(conf *config.Config,
 		conf.MaintenanceMode,
 		conf.Logger())
 
+	core.observer = conf.Observer
+
 	netCh := make(<-chan net.RPC)
 	if trans != nil {
 		netCh = trans.Consumer()
 	}
 
+	chunkSize := conf.SnapshotChunkSize
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultSnapshotChunkSize
+	}
+
 	node := Node{
 		conf:         conf,
 		logger:       conf.Logger(),
@@ -114,8 +149,13 @@ func NewNode(conf *config.Config,
 		shutdownCh:   make(chan struct{}),
 		suspendCh:    make(chan struct{}),
 		controlTimer: newRandomControlTimer(),
+
+		consensusEvents: newConsensusEventFeed(),
+		snapshots:       newSnapshotStore(conf.SnapshotsDir(), chunkSize),
 	}
 
+	core.hg.SetConsensusEventCallback(node.onConsensusEvent)
+
 	return &node
 }
 
@@ -127,6 +167,28 @@ Public Methods
 // on configuration (Babbling, CatchingUp, Joining, or Suspended).
 func (n *Node) Init() error {
 
+	if n.conf.Genesis != nil {
+		hash, err := n.conf.Genesis.HashString()
+		if err != nil {
+			return err
+		}
+		n.genesisHash = hash
+		n.core.joinAuthorizer = NewJoinPolicy(n.conf.Genesis.JoinPolicy)
+	}
+
+	// the consensus parameters must be set before any event is inserted,
+	// including the events loaded by bootstrap.
+	if n.conf.ConsensusParams != nil {
+		if err := n.core.hg.SetConsensusParams(*n.conf.ConsensusParams); err != nil {
+			return err
+		}
+	}
+
+	n.core.hg.SetRoundTracing(n.conf.TraceRounds)
+
+	n.core.peersCallback = n.persistPeers
+	n.core.validatorsCallback = n.onPeersChanged
+
 	// if the bootstrap option is set, load the hashgraph from an existing
 	// database (if bootstrap option is set in config).
 	if n.conf.Bootstrap {
@@ -145,9 +207,15 @@ func (n *Node) Init() error {
 		go n.trans.Listen()
 
 		_, ok := n.core.peers.ByID[n.core.validator.ID()]
-		if ok {
+		if ok && n.conf.Observer {
+			return fmt.Errorf("An observer cannot belong to the PeerSet")
+		} else if ok {
 			n.logger.Debug("Node belongs to PeerSet")
 			n.setBabblingOrCatchingUpState()
+			n.announcePeer()
+		} else if n.conf.Observer {
+			n.logger.Debug("Node is an observer")
+			n.setBabblingOrCatchingUpState()
 		} else {
 			n.logger.Debug("Node does not belong to PeerSet => Joining")
 			n.transition(_state.Joining)
@@ -186,13 +254,22 @@ func (n *Node) Run(gossip bool) {
 		switch state {
 		case _state.Babbling:
 			n.kdag(gossip)
+		case _state.Leaving, _state.LeaveAccepted:
+			// Keep gossiping until the node is removed, unless it was
+			// suspended before leaving.
+			select {
+			case <-n.suspendCh:
+				time.Sleep(100 * time.Millisecond)
+			default:
+				n.kdag(gossip)
+			}
 		case _state.CatchingUp:
 			n.fastForward()
 		case _state.Joining:
 			n.join()
 		case _state.Suspended:
 			time.Sleep(2000 * time.Millisecond)
-		case _state.Shutdown:
+		case _state.Left, _state.Shutdown:
 			return
 		}
 	}
@@ -206,7 +283,19 @@ func (n *Node) RunAsync(gossip bool) {
 
 // Leave causes the node to politely leave the network with a LeaveRequest and
 // to wait for its validator to be removed from the validator-set via consensus.
+// It reports its progress to the application through OnStateChanged: Leaving
+// while the request goes through consensus, LeaveAccepted until the hashgraph
+// reaches the round at which the node is removed, and Left before it shuts
+// down. If the node cannot get the request through consensus by itself, for
+// example because it is partitioned from the other validators, it submits it
+// through them.
 func (n *Node) Leave() error {
+	return n.LeaveWithProgress(nil)
+}
+
+// LeaveWithProgress is like Leave, but it also reports the progress states to
+// the progress function, right before the application.
+func (n *Node) LeaveWithProgress(progress func(_state.State)) error {
 	if n.conf.MaintenanceMode {
 		return nil
 	}
@@ -215,7 +304,7 @@ func (n *Node) Leave() error {
 
 	defer n.Shutdown()
 
-	err := n.core.leave(n.conf.JoinTimeout)
+	err := n.leave(progress)
 	if err != nil {
 		n.logger.WithError(err).Error("Leaving")
 		return err
@@ -224,6 +313,278 @@ func (n *Node) Leave() error {
 	return nil
 }
 
+// SetWeight submits this node's vote to change the voting weight of a
+// validator, possibly its own, and waits for it to go through consensus. The
+// weight changes when validators holding a supermajority of the voting weight
+// have voted for it, and it is effective 6 rounds after the round in which the
+// last vote was accepted. SetWeight returns true if this vote completed the
+// change, and an error if the vote was refused.
+func (n *Node) SetWeight(pubKey string, weight int) (bool, error) {
+	n.coreLock.Lock()
+	itx, err := n.core.newWeightTransaction(pubKey, weight)
+	if err != nil {
+		n.coreLock.Unlock()
+		return false, err
+	}
+	promise := n.core.addInternalTransaction(itx)
+	n.coreLock.Unlock()
+
+	timeout := time.After(n.conf.JoinTimeout)
+	select {
+	case resp := <-promise.respCh:
+		if resp.pending {
+			n.logger.WithField("peer", pubKey).Info("Weight vote recorded")
+			return false, nil
+		}
+		if !resp.accepted {
+			return false, fmt.Errorf("Weight change refused: %s", resp.reason)
+		}
+		n.logger.WithFields(logrus.Fields{
+			"peer":            pubKey,
+			"weight":          weight,
+			"effective_round": resp.acceptedRound,
+		}).Info("Weight changed")
+		return true, nil
+	case <-timeout:
+		return false, fmt.Errorf("Timeout waiting for weight vote to go through consensus")
+	}
+}
+
+// UpdatePeer submits an InternalTransaction to change the NetAddr and Moniker
+// of the node's validator, and waits for it to go through consensus. The other
+// nodes gossip to the new address as soon as the transaction is committed, and
+// the change is recorded in the validator-set 6 rounds later.
+func (n *Node) UpdatePeer(netAddr string, moniker string) error {
+	n.coreLock.Lock()
+	itx, err := n.core.newUpdateTransaction(netAddr, moniker)
+	if err != nil {
+		n.coreLock.Unlock()
+		return err
+	}
+	promise := n.core.addInternalTransaction(itx)
+	n.coreLock.Unlock()
+
+	timeout := time.After(n.conf.JoinTimeout)
+	select {
+	case resp := <-promise.respCh:
+		if !resp.accepted {
+			return fmt.Errorf("Peer update refused")
+		}
+		n.logger.WithFields(logrus.Fields{
+			"net_addr":        netAddr,
+			"moniker":         moniker,
+			"effective_round": resp.acceptedRound,
+		}).Info("Peer updated")
+		return nil
+	case <-timeout:
+		return fmt.Errorf("Timeout waiting for peer update to go through consensus")
+	}
+}
+
+// announcePeer updates the NetAddr and Moniker of the node's validator, in the
+// background, if they differ from the ones recorded in the validator-set; for
+// example when the node was moved to another host.
+func (n *Node) announcePeer() {
+	p, ok := n.core.validators.ByID[n.core.validator.ID()]
+	if !ok {
+		return
+	}
+
+	netAddr := n.trans.AdvertiseAddr()
+	moniker := n.core.validator.Moniker
+
+	if p.NetAddr == netAddr && p.Moniker == moniker {
+		return
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"old_net_addr": p.NetAddr,
+		"net_addr":     netAddr,
+		"old_moniker":  p.Moniker,
+		"moniker":      moniker,
+	}).Info("Announcing peer update")
+
+	go func() {
+		if err := n.UpdatePeer(netAddr, moniker); err != nil {
+			n.logger.WithError(err).Error("Announcing peer update")
+		}
+	}()
+}
+
+// RotateKey submits an InternalTransaction, signed by the current and the new
+// key, to replace the public key of the node's validator, and waits for it to
+// go through consensus. The node keeps its place in the validator-set, and
+// starts creating Events with the new key at the returned effective round. It
+// returns an error if the application refused the transaction.
+func (n *Node) RotateKey(newKey *ecdsa.PrivateKey) (int, error) {
+	n.coreLock.Lock()
+	itx, err := n.core.newRotationTransaction(NewValidator(newKey, n.core.validator.Moniker))
+	if err != nil {
+		n.coreLock.Unlock()
+		return 0, err
+	}
+	promise := n.core.addInternalTransaction(itx)
+	n.coreLock.Unlock()
+
+	timeout := time.After(n.conf.JoinTimeout)
+	select {
+	case resp := <-promise.respCh:
+		if !resp.accepted {
+			return 0, fmt.Errorf("Key rotation refused")
+		}
+		n.logger.WithFields(logrus.Fields{
+			"new_key":         itx.Body.NewPubKey,
+			"effective_round": resp.acceptedRound,
+		}).Info("Key rotation accepted")
+		return resp.acceptedRound, nil
+	case <-timeout:
+		return 0, fmt.Errorf("Timeout waiting for key rotation to go through consensus")
+	}
+}
+
+// RotateKeyFromFile rotates the validator's key to the key stored in the
+// NextKeyfile of the data directory. It is up to the owner of the data
+// directory to replace the Keyfile with the NextKeyfile afterwards, so that the
+// node restarts with the new key.
+func (n *Node) RotateKeyFromFile() (int, error) {
+	newKey, err := keys.NewSimpleKeyfile(n.conf.NextKeyfile()).ReadKey()
+	if err != nil {
+		return 0, fmt.Errorf("Reading next key: %s", err)
+	}
+
+	return n.RotateKey(newKey)
+}
+
+// SetDiscovery sets the Discovery used to find peers when joining or
+// fast-forwarding. It must be called before Init.
+func (n *Node) SetDiscovery(d *discovery.Discovery) {
+	n.discovery = d
+}
+
+// SetPeerStore sets the store where the node writes the list of peers every
+// time accepted InternalTransactions change it, so that it can rejoin the
+// network after a restart. It must be called before Init.
+func (n *Node) SetPeerStore(store *peers.JSONPeerSet) {
+	n.peerStore = store
+}
+
+// persistPeers writes a PeerSet to the peerStore, if there is one.
+func (n *Node) persistPeers(peerSet *peers.PeerSet) {
+	if n.peerStore == nil {
+		return
+	}
+
+	if err := n.peerStore.Write(peerSet.Peers); err != nil {
+		n.logger.WithError(err).Error("Persisting peers")
+		return
+	}
+
+	n.logger.WithField("peers", peerSet.Len()).Debug("Persisted peers")
+}
+
+// onPeersChanged notifies the App of a change of validator-set, if it
+// implements the PeersChangeGateway interface. It is called while the block
+// that decided the change is processed, so all the nodes notify their App at
+// the same point of the sequence of blocks, before the change is effective.
+func (n *Node) onPeersChanged(change proxy.PeersChange) {
+	gateway, ok := n.proxy.(proxy.PeersChangeGateway)
+	if !ok {
+		return
+	}
+
+	if err := gateway.OnPeersChanged(change); err != nil {
+		n.logger.WithError(err).Warn("Failed to notify peers change")
+	}
+}
+
+// joinTargets returns the peers to contact for joining, fast-forwarding, or
+// leaving through other peers: the known peers, followed by the discovered peers that are not already known.
+// The node's own validator is excluded.
+func (n *Node) joinTargets() []*peers.Peer {
+	known := n.core.peerSelector.getPeers()
+
+	candidates := known.Peers
+	if n.discovery != nil {
+		for _, p := range n.discovery.Peers() {
+			if _, ok := known.ByPubKey[p.PubKeyString()]; !ok {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+
+	_, targets := peers.ExcludePeer(candidates, n.core.validator.ID())
+
+	return targets
+}
+
+// authorizeJoin applies the join policy to a JoinRequest against the current
+// validator-set and the last consensus round.
+func (n *Node) authorizeJoin(itx hg.InternalTransaction) error {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.joinAuthorizer.Authorize(itx, n.core.validators, n.GetLastConsensusRoundIndex())
+}
+
+// ProposeRemoval submits this node's vote to remove another validator, and
+// waits for it to go through consensus. The InternalTransaction must be a
+// PEER_REMOVE signed with this node's key on behalf of this node, which
+// authenticates the request, and bound to the round from which the peer has
+// been a validator (cf. hashgraph.ValidatorRound). The peer is removed when validators holding a
+// supermajority of the voting weight have voted to remove it. ProposeRemoval
+// returns true if this vote completed the removal.
+func (n *Node) ProposeRemoval(itx hg.InternalTransaction) (bool, error) {
+	if itx.Body.Type != hg.PEER_REMOVE {
+		return false, fmt.Errorf("Not a PEER_REMOVE transaction")
+	}
+
+	if strings.ToUpper(itx.Body.Proposer) != strings.ToUpper(n.GetPubKey()) {
+		return false, fmt.Errorf("Removal must be proposed by this node")
+	}
+
+	if ok, err := itx.Verify(); !ok || err != nil {
+		return false, fmt.Errorf("Invalid signature on removal proposal")
+	}
+
+	target := itx.Body.Peer.PubKeyString()
+
+	n.coreLock.Lock()
+	if target == strings.ToUpper(n.GetPubKey()) {
+		n.coreLock.Unlock()
+		return false, fmt.Errorf("Use leave to remove this node")
+	}
+	if _, ok := n.core.validators.ByPubKey[target]; !ok {
+		n.coreLock.Unlock()
+		return false, fmt.Errorf("%s is not a validator", target)
+	}
+	round, err := n.core.validatorRound(target)
+	if err != nil || round != itx.Body.Round {
+		n.coreLock.Unlock()
+		return false, fmt.Errorf("Removal must be bound to round %d", round)
+	}
+	promise := n.core.addInternalTransaction(itx)
+	n.coreLock.Unlock()
+
+	timeout := time.After(n.conf.JoinTimeout)
+	select {
+	case resp := <-promise.respCh:
+		if resp.pending {
+			n.logger.WithField("peer", target).Info("Removal vote recorded")
+			return false, nil
+		}
+		if !resp.accepted {
+			return false, fmt.Errorf("Removal refused: %s", resp.reason)
+		}
+		n.logger.WithFields(logrus.Fields{
+			"peer":            target,
+			"effective_round": resp.acceptedRound,
+		}).Info("Validator removed")
+		return true, nil
+	case <-timeout:
+		return false, fmt.Errorf("Timeout waiting for removal proposal to go through consensus")
+	}
+}
+
 // Shutdown attempts to cleanly shutdown the node by waiting for pending work to
 // be finished, stopping the control-timer, and closing the transport.
 func (n *Node) Shutdown() {
@@ -287,15 +648,99 @@ func (n *Node) GetStats() map[string]string {
 		"id":                   fmt.Sprint(n.core.validator.ID()),
 		"state":                n.GetState().String(),
 		"moniker":              n.core.validator.Moniker,
+		"genesis_hash":         n.genesisHash,
+		"observer":             strconv.FormatBool(n.conf.Observer),
+
+		"invalid_signatures":       strconv.Itoa(n.core.hg.InvalidSignatures),
+		"duplicate_signatures":     strconv.Itoa(n.core.hg.DuplicateSignatures),
+		"non_validator_signatures": strconv.Itoa(n.core.hg.NonValidatorSignatures),
+
+		"fame_decisions":          strconv.Itoa(n.core.hg.FameStats.DecidedWitnesses),
+		"fame_mean_voting_rounds": strconv.FormatFloat(n.core.hg.FameStats.MeanVotingRounds(), 'f', 2, 64),
+		"fame_max_voting_rounds":  strconv.Itoa(n.core.hg.FameStats.MaxVotingRounds),
+		"fame_exhausted":          strconv.Itoa(n.core.hg.FameStats.Exhausted),
+		"coin_rounds":             strconv.Itoa(n.core.hg.FameStats.CoinRounds),
+		"coin_flips":              strconv.Itoa(n.core.hg.FameStats.CoinFlips),
 	}
+
+	addCacheStats(s, n.core.hg.Store.CacheStats())
+	addCacheStats(s, n.core.hg.CacheStats())
+
 	return s
 }
 
+// addCacheStats adds the usage statistics of caches to the output of GetStats.
+func addCacheStats(s map[string]string, stats map[string]common.LRUStats) {
+	for name, cs := range stats {
+		prefix := "cache_" + name
+		s[prefix+"_items"] = strconv.Itoa(cs.Items)
+		s[prefix+"_bytes"] = strconv.FormatInt(cs.Bytes, 10)
+		s[prefix+"_hits"] = strconv.FormatUint(cs.Hits, 10)
+		s[prefix+"_misses"] = strconv.FormatUint(cs.Misses, 10)
+		s[prefix+"_evictions"] = strconv.FormatUint(cs.Evictions, 10)
+	}
+}
+
 // GetBlock returns a block by index.
 func (n *Node) GetBlock(blockIndex int) (*hg.Block, error) {
 	return n.core.hg.Store.GetBlock(blockIndex)
 }
 
+// VerifyBlock checks the signatures of a block against the validator-set that
+// was effective when the block was produced.
+func (n *Node) VerifyBlock(blockIndex int) (*hg.BlockVerification, error) {
+	return n.core.hg.VerifyBlock(blockIndex)
+}
+
+// GetRoundTrace returns how the consensus methods processed a recent round. It
+// requires TraceRounds to be set in the configuration.
+func (n *Node) GetRoundTrace(roundIndex int) (*hg.RoundTrace, error) {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.hg.GetRoundTrace(roundIndex)
+}
+
+// DumpRoundTraces writes the traces of all the recent rounds in JSON.
+func (n *Node) DumpRoundTraces(w io.Writer) error {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.hg.DumpRoundTraces(w)
+}
+
+// dumpRoundTraces writes the traces of all the recent rounds to a file in the
+// data directory.
+func (n *Node) dumpRoundTraces() {
+	path := filepath.Join(n.conf.DataDir, fmt.Sprintf("rounds-trace-%d.json", time.Now().Unix()))
+
+	f, err := os.Create(path)
+	if err != nil {
+		n.logger.WithError(err).Error("Creating round traces file")
+		return
+	}
+	defer f.Close()
+
+	if err := n.DumpRoundTraces(f); err != nil {
+		n.logger.WithError(err).Error("Dumping round traces")
+		return
+	}
+
+	n.logger.WithField("file", path).Info("Dumped round traces")
+}
+
+// GetTxLocation returns the index of the block containing a transaction, and
+// the transaction's position within the block.
+func (n *Node) GetTxLocation(txHash string) (hg.TxLocation, error) {
+	return n.core.hg.Store.GetTxLocation(txHash)
+}
+
+// GetBlockIndexesByTime returns the indexes of the blocks whose timestamp is
+// within [start, end].
+func (n *Node) GetBlockIndexesByTime(start, end int64) ([]int, error) {
+	return n.core.hg.Store.GetBlockIndexesByTime(start, end)
+}
+
 // GetLastBlockIndex returns the index of the last known block.
 func (n *Node) GetLastBlockIndex() int {
 	return n.core.getLastBlockIndex()
@@ -346,6 +791,10 @@ func (n *Node) doBackgroundWork() {
 				n.resetTimer()
 			})
 		case t := <-n.submitCh:
+			if n.conf.Observer {
+				n.logger.Warn("Observers do not accept transactions")
+				continue
+			}
 			n.logger.Debug("Adding Transaction")
 			n.addTransaction(t)
 			n.resetTimer()
@@ -402,6 +851,11 @@ func (n *Node) checkSuspend() {
 			"acceptedRound":             n.core.acceptedRound,
 		}).Debugf("SUSPEND")
 
+		// keep a record of the rounds that failed to reach consensus
+		if tooManyUndeterminedEvents && n.conf.TraceRounds > 0 {
+			n.dumpRoundTraces()
+		}
+
 		n.Suspend()
 	}
 }
@@ -424,7 +878,7 @@ func (n *Node) kdag(gossip bool) {
 					n.GoFunc(func() {
 						n.gossip(peer)
 					})
-				} else {
+				} else if !n.conf.Observer {
 					n.monologue()
 				}
 			}
@@ -462,6 +916,7 @@ func (n *Node) monologue() error {
 }
 
 // gossip performs a pull-push gossip operation with the selected peer.
+// Observers only pull.
 func (n *Node) gossip(peer *peers.Peer) error {
 	var connected bool
 
@@ -485,6 +940,13 @@ func (n *Node) gossip(peer *peers.Peer) error {
 		return err
 	}
 
+	// observers only pull events from the validators
+	if n.conf.Observer {
+		n.logStats()
+		connected = true
+		return nil
+	}
+
 	// push
 	err = n.push(peer, otherKnownEvents)
 	if err != nil {
@@ -636,8 +1098,18 @@ func (n *Node) fastForward() error {
 		return fmt.Errorf("getBestFastForwardResponse returned nil")
 	}
 
+	// check the Block signatures before trusting its StateHash to verify the
+	// snapshot
+	n.coreLock.Lock()
+	err = n.core.hg.CheckBlock(&resp.Block, peers.NewPeerSet(resp.Frame.Peers))
+	n.coreLock.Unlock()
+	if err != nil {
+		n.logger.WithError(err).Error("Checking AnchorBlock")
+		return err
+	}
+
 	//update app from snapshot
-	err = n.proxy.Restore(resp.Snapshot)
+	err = n.restoreSnapshot(&resp.Block, &resp.Manifest)
 	if err != nil {
 		n.logger.WithError(err).Error("Restoring App from Snapshot")
 		return err
@@ -665,12 +1137,15 @@ func (n *Node) fastForward() error {
 }
 
 // getBestFastForwardResponse performs a FastForwardRequest with all known peers
-// and only selects the one corresponding to the hightest block number.
+// and selects the one corresponding to the highest block number that enough of
+// them vouch for (cf. selectFastForwardResponse). This prevents a single peer
+// from feeding the node a stale anchor. The content of the response is not
+// trusted on that basis: the Block signatures, the Frame hash, and the
+// snapshot's state hash are checked before the node resumes from it.
 func (n *Node) getBestFastForwardResponse() *net.FastForwardResponse {
-	var bestResponse *net.FastForwardResponse
-	maxBlock := 0
+	responses := make(map[uint32]*net.FastForwardResponse)
 
-	for _, p := range n.core.peerSelector.getPeers().Peers {
+	for _, p := range n.joinTargets() {
 		start := time.Now()
 		resp, err := n.requestFastForward(p.NetAddr)
 		elapsed := time.Since(start)
@@ -687,16 +1162,240 @@ func (n *Node) getBestFastForwardResponse() *net.FastForwardResponse {
 			"frame_events":         len(resp.Frame.Events),
 			"frame_roots":          resp.Frame.Roots,
 			"frame_peers":          len(resp.Frame.Peers),
-			"snapshot":             resp.Snapshot,
+			"snapshot_size":        resp.Manifest.Size,
+			"snapshot_chunks":      len(resp.Manifest.ChunkHashes),
 		}).Debug("FastForwardResponse")
 
-		if resp.Block.Index() > maxBlock {
-			bestResponse = &resp
-			maxBlock = resp.Block.Index()
+		// FromID is not authenticated, so this does not prove who answered;
+		// it only discards answers from an address that now belongs to
+		// another node. The response is counted for the peer that was asked.
+		if resp.FromID != p.ID() {
+			n.logger.WithFields(logrus.Fields{
+				"from_id": resp.FromID,
+				"peer_id": p.ID(),
+			}).Warn("FastForwardResponse from unexpected peer")
+			continue
 		}
+
+		responses[p.ID()] = &resp
 	}
 
-	return bestResponse
+	n.coreLock.Lock()
+	validators := n.core.validators
+	n.coreLock.Unlock()
+
+	best, weight := selectFastForwardResponse(responses, validators)
+	if best != nil {
+		n.logger.WithFields(logrus.Fields{
+			"block_index": best.Block.Index(),
+			"weight":      weight,
+			"trust_count": validators.TrustCount(),
+		}).Debug("FastForwardResponse confirmed")
+	}
+
+	return best
+}
+
+// selectFastForwardResponse groups the responses that agree on the anchor
+// Block, its Frame, and the snapshot, and returns a response from the group
+// with the highest Block index whose senders, together with the peers whose
+// anchor is further ahead, hold more than TrustCount of the voting weight in
+// the validator-set, along with that weight. Counting the peers that are ahead
+// lets the node catch up while the validators produce new Blocks, and are not
+// at the same anchor when they answer. Responses from peers that are not in the
+// validator-set carry no weight. It returns nil if no group has enough weight.
+func selectFastForwardResponse(responses map[uint32]*net.FastForwardResponse, validators *peers.PeerSet) (*net.FastForwardResponse, int) {
+	type group struct {
+		resp   *net.FastForwardResponse
+		weight int
+	}
+
+	// the Block index and voting weight of each validator that answered
+	type vouch struct {
+		index  int
+		weight int
+	}
+	var vouches []vouch
+
+	groups := make(map[string]*group)
+	for id, resp := range responses {
+		if resp.Block.Index() <= 0 {
+			continue
+		}
+
+		p, ok := validators.ByID[id]
+		if !ok {
+			continue
+		}
+
+		bodyHash, err := resp.Block.Body.Hash()
+		if err != nil {
+			continue
+		}
+
+		frameHash, err := resp.Frame.Hash()
+		if err != nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%d:%X:%X:%X",
+			resp.Block.Index(),
+			bodyHash,
+			frameHash,
+			resp.Manifest.Hash)
+
+		g, ok := groups[key]
+		if !ok {
+			g = &group{resp: resp}
+			groups[key] = g
+		}
+		g.weight += p.VotingWeight()
+
+		vouches = append(vouches, vouch{resp.Block.Index(), p.VotingWeight()})
+	}
+
+	var best *group
+	for _, g := range groups {
+		for _, v := range vouches {
+			if v.index > g.resp.Block.Index() {
+				g.weight += v.weight
+			}
+		}
+
+		if g.weight <= validators.TrustCount() {
+			continue
+		}
+		if best == nil ||
+			g.resp.Block.Index() > best.resp.Block.Index() ||
+			(g.resp.Block.Index() == best.resp.Block.Index() && g.weight > best.weight) {
+			best = g
+		}
+	}
+
+	if best == nil {
+		return nil, 0
+	}
+
+	return best.resp, best.weight
+}
+
+/*******************************************************************************
+Leaving
+*******************************************************************************/
+
+// leave submits the node's leave request and waits for it to take effect,
+// reporting the progress states along the way.
+func (n *Node) leave(progress func(_state.State)) error {
+	report := func(state _state.State) {
+		if progress != nil {
+			progress(state)
+		}
+		n.transition(state)
+	}
+
+	n.coreLock.Lock()
+	itx, err := n.core.newLeaveTransaction()
+	if err != nil || itx == nil {
+		n.coreLock.Unlock()
+		return err
+	}
+
+	// A suspended node does not process Events, so it can only leave
+	// through other peers.
+	var promise *joinPromise
+	if n.GetState() != _state.Suspended {
+		promise = n.core.addInternalTransaction(*itx)
+	}
+	n.coreLock.Unlock()
+
+	report(_state.Leaving)
+
+	removedRound, local, err := n.waitLeaveAccepted(*itx, promise)
+	if err != nil {
+		return err
+	}
+
+	n.logger.WithField("removed_round", removedRound).Debug("Leave request accepted")
+
+	report(_state.LeaveAccepted)
+
+	// The other validators need the node's Events until the round at which
+	// it is removed. A node that had to go through other peers cannot
+	// provide them anyway.
+	if local {
+		if err := n.waitRemoved(removedRound); err != nil {
+			return err
+		}
+	}
+
+	report(_state.Left)
+
+	return nil
+}
+
+// waitLeaveAccepted waits for a leave request to go through consensus, and
+// returns the round at which the node is removed. If the node does not process
+// the request within JoinTimeout, it submits it to the other peers, one after
+// the other, until one of them accepts it or JoinTimeout expires again. local
+// indicates whether the node processed the request itself.
+func (n *Node) waitLeaveAccepted(itx hg.InternalTransaction, promise *joinPromise) (removedRound int, local bool, err error) {
+	if promise != nil {
+		select {
+		case resp := <-promise.respCh:
+			if !resp.accepted {
+				return 0, true, fmt.Errorf("Leave request refused")
+			}
+			return resp.acceptedRound, true, nil
+		case <-time.After(n.conf.JoinTimeout):
+			n.logger.Warn("Leave request not processed, submitting it through other peers")
+		}
+	}
+
+	// Peers that already removed the node accept the request right away, so
+	// it can be submitted several times.
+	timeout := time.After(n.conf.JoinTimeout)
+	for {
+		for _, p := range n.joinTargets() {
+			resp, err := n.requestLeave(p.NetAddr, itx)
+			if err != nil {
+				n.logger.WithError(err).WithField("peer", p.NetAddr).Warn("requestLeave()")
+				continue
+			}
+
+			if resp.Accepted {
+				return resp.AcceptedRound, false, nil
+			}
+		}
+
+		select {
+		case <-timeout:
+			return 0, false, fmt.Errorf("Timeout waiting for leave request to go through consensus")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// waitRemoved waits for the hashgraph to decide the round at which the node is
+// removed from the validator-set.
+func (n *Node) waitRemoved(removedRound int) error {
+	timeout := time.After(n.conf.JoinTimeout)
+	for {
+		n.coreLock.Lock()
+		lastConsensusRound := n.GetLastConsensusRoundIndex()
+		n.coreLock.Unlock()
+
+		if lastConsensusRound >= removedRound {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("Timeout waiting for leaving node to reach RemovedRound %d", removedRound)
+		default:
+			n.logger.Debugf("Waiting to reach RemovedRound: %d/%d", lastConsensusRound, removedRound)
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
 }
 
 /*******************************************************************************
@@ -712,7 +1411,13 @@ func (n *Node) join() error {
 
 	n.logger.Info("JOINING")
 
-	peer := n.core.peerSelector.next()
+	targets := n.joinTargets()
+	if len(targets) == 0 {
+		n.logger.Error("Cannot join: no known peers")
+		return fmt.Errorf("No known peers")
+	}
+
+	peer := targets[rand.Intn(len(targets))]
 
 	start := time.Now()
 	resp, err := n.requestJoin(peer.NetAddr)
@@ -738,11 +1443,20 @@ func (n *Node) join() error {
 		n.core.acceptedRound = resp.AcceptedRound
 		n.core.removedRound = -1
 
+		// Gossip with the validator-set that accepted the node, which may
+		// have nothing in common with the peers it was started with.
+		if len(resp.Peers) > 0 {
+			n.coreLock.Lock()
+			n.core.setPeers(peers.NewPeerSet(resp.Peers))
+			n.persistPeers(n.core.peers)
+			n.coreLock.Unlock()
+		}
+
 		n.setBabblingOrCatchingUpState()
 	} else {
 		// Then JoinRequest was explicitly refused by the curren peer-set. This
 		// is not an error.
-		n.logger.Info("JoinRequest rejected")
+		n.logger.WithField("reason", resp.Reason).Info("JoinRequest rejected")
 		n.Shutdown()
 	}
 
@@ -764,9 +1478,16 @@ func (n *Node) transition(state _state.State) {
 }
 
 // setBabblingOrCatchingUpState sets the node's state to CatchingUp if fast-sync
-// is enabled, or to Babbling if fast-sync is not enabled.
+// is enabled, or to Babbling if fast-sync is not enabled. Fast-sync is ignored
+// if the AppGateway cannot verify the snapshots it restores, cf.
+// restoreSnapshot.
 func (n *Node) setBabblingOrCatchingUpState() {
-	if n.conf.EnableFastSync {
+	_, verifiable := n.proxy.(proxy.SnapshotStreamGateway)
+	if n.conf.EnableFastSync && !verifiable {
+		n.logger.Warn("FastSync requires an AppGateway that implements SnapshotStreamGateway")
+	}
+
+	if n.conf.EnableFastSync && verifiable {
 		n.logger.Debug("FastSync enabled => CatchingUp")
 		n.transition(_state.CatchingUp)
 	} else {
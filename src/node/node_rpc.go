@@ -14,22 +14,27 @@ import (
 
 func (n *Node) requestSync(target string, known map[uint32]int, syncLimit int) (net.SyncResponse, error) {
 	args := net.SyncRequest{
-		FromID:    n.core.validator.ID(),
-		SyncLimit: syncLimit,
-		Known:     known,
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
+		SyncLimit:   syncLimit,
+		Known:       known,
 	}
 
 	var out net.SyncResponse
 
 	err := n.trans.Sync(target, &args, &out)
+	if err == nil {
+		err = n.checkGenesisHash(out.GenesisHash)
+	}
 
 	return out, err
 }
 
 func (n *Node) requestEagerSync(target string, events []hg.WireEvent) (net.EagerSyncResponse, error) {
 	args := net.EagerSyncRequest{
-		FromID: n.core.validator.ID(),
-		Events: events,
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
+		Events:      events,
 	}
 
 	var out net.EagerSyncResponse
@@ -45,12 +50,50 @@ func (n *Node) requestFastForward(target string) (net.FastForwardResponse, error
 	}).Debug("RequestFastForward()")
 
 	args := net.FastForwardRequest{
-		FromID: n.core.validator.ID(),
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
 	}
 
 	var out net.FastForwardResponse
 
 	err := n.trans.FastForward(target, &args, &out)
+	if err == nil {
+		err = n.checkGenesisHash(out.GenesisHash)
+	}
+
+	return out, err
+}
+
+func (n *Node) requestSnapshotChunk(target string, blockIndex int, chunk int) (net.SnapshotChunkResponse, error) {
+	args := net.SnapshotChunkRequest{
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
+		BlockIndex:  blockIndex,
+		Chunk:       chunk,
+	}
+
+	var out net.SnapshotChunkResponse
+
+	err := n.trans.SnapshotChunk(target, &args, &out)
+	if err == nil {
+		err = n.checkGenesisHash(out.GenesisHash)
+	}
+
+	return out, err
+}
+
+func (n *Node) requestLeave(target string, itx hashgraph.InternalTransaction) (net.LeaveResponse, error) {
+	args := net.LeaveRequest{
+		GenesisHash:         n.genesisHash,
+		InternalTransaction: itx,
+	}
+
+	var out net.LeaveResponse
+
+	err := n.trans.Leave(target, &args, &out)
+	if err == nil {
+		err = n.checkGenesisHash(out.GenesisHash)
+	}
 
 	return out, err
 }
@@ -62,13 +105,21 @@ func (n *Node) requestJoin(target string) (net.JoinResponse, error) {
 		n.trans.AdvertiseAddr(),
 		n.core.validator.Moniker))
 
+	joinTx.Body.Invite = n.conf.JoinInvite
+
 	joinTx.Sign(n.core.validator.Key)
 
-	args := net.JoinRequest{InternalTransaction: joinTx}
+	args := net.JoinRequest{
+		GenesisHash:         n.genesisHash,
+		InternalTransaction: joinTx,
+	}
 
 	var out net.JoinResponse
 
 	err := n.trans.Join(target, &args, &out)
+	if err == nil {
+		err = n.checkGenesisHash(out.GenesisHash)
+	}
 
 	return out, err
 }
@@ -80,7 +131,10 @@ func (n *Node) processRPC(rpc net.RPC) {
 	// because it enables the other nodes to be notified of this suspension.
 	_, isSyncRequest := rpc.Command.(*net.SyncRequest)
 
+	// A leaving node keeps responding until it is removed.
 	if state := n.GetState(); !(state == _state.Babbling ||
+		state == _state.Leaving ||
+		state == _state.LeaveAccepted ||
 		(state == _state.Suspended && isSyncRequest)) {
 
 		n.logger.WithField("state", state).Debug("Not in Babbling state")
@@ -88,6 +142,12 @@ func (n *Node) processRPC(rpc net.RPC) {
 		return
 	}
 
+	if err := n.checkGenesisHash(requestGenesisHash(rpc.Command)); err != nil {
+		n.logger.WithError(err).Debug("Rejecting RPC from another network")
+		rpc.Respond(nil, err)
+		return
+	}
+
 	switch cmd := rpc.Command.(type) {
 	case *net.SyncRequest:
 		n.processSyncRequest(rpc, cmd)
@@ -95,14 +155,46 @@ func (n *Node) processRPC(rpc net.RPC) {
 		n.processEagerSyncRequest(rpc, cmd)
 	case *net.FastForwardRequest:
 		n.processFastForwardRequest(rpc, cmd)
+	case *net.SnapshotChunkRequest:
+		n.processSnapshotChunkRequest(rpc, cmd)
 	case *net.JoinRequest:
 		n.processJoinRequest(rpc, cmd)
+	case *net.LeaveRequest:
+		n.processLeaveRequest(rpc, cmd)
 	default:
 		n.logger.WithField("cmd", rpc.Command).Error("Unexpected RPC command")
 		rpc.Respond(nil, fmt.Errorf("unexpected command"))
 	}
 }
 
+// requestGenesisHash returns the GenesisHash of a request.
+func requestGenesisHash(cmd interface{}) string {
+	switch cmd := cmd.(type) {
+	case *net.SyncRequest:
+		return cmd.GenesisHash
+	case *net.EagerSyncRequest:
+		return cmd.GenesisHash
+	case *net.FastForwardRequest:
+		return cmd.GenesisHash
+	case *net.SnapshotChunkRequest:
+		return cmd.GenesisHash
+	case *net.JoinRequest:
+		return cmd.GenesisHash
+	case *net.LeaveRequest:
+		return cmd.GenesisHash
+	}
+	return ""
+}
+
+// checkGenesisHash returns an error if a request or response was sent by a
+// node from another network.
+func (n *Node) checkGenesisHash(hash string) error {
+	if hash != n.genesisHash {
+		return fmt.Errorf("Wrong genesis hash %q, expected %q", hash, n.genesisHash)
+	}
+	return nil
+}
+
 func (n *Node) processSyncRequest(rpc net.RPC, cmd *net.SyncRequest) {
 	n.logger.WithFields(logrus.Fields{
 		"from_id":    cmd.FromID,
@@ -111,7 +203,8 @@ func (n *Node) processSyncRequest(rpc net.RPC, cmd *net.SyncRequest) {
 	}).Debug("process SyncRequest")
 
 	resp := &net.SyncResponse{
-		FromID: n.core.validator.ID(),
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
 	}
 
 	var respErr error
@@ -209,7 +302,8 @@ func (n *Node) processFastForwardRequest(rpc net.RPC, cmd *net.FastForwardReques
 	}).Debug("process FastForwardRequest")
 
 	resp := &net.FastForwardResponse{
-		FromID: n.core.validator.ID(),
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
 	}
 
 	var respErr error
@@ -226,27 +320,54 @@ func (n *Node) processFastForwardRequest(rpc net.RPC, cmd *net.FastForwardReques
 		resp.Block = *block
 		resp.Frame = *frame
 
-		//Get snapshot
-		snapshot, err := n.proxy.GetSnapshot(block.Index())
+		//Get snapshot manifest
+		manifest, err := n.getSnapshotManifest(block.Index())
 
 		if err != nil {
 			n.logger.WithField("error", err).Error("Getting Snapshot")
 			respErr = err
 		} else {
-			resp.Snapshot = snapshot
+			resp.Manifest = *manifest
 		}
 	}
 
 	n.logger.WithFields(logrus.Fields{
-		"events":         len(resp.Frame.Events),
-		"block":          resp.Block.Index(),
-		"round_received": resp.Block.RoundReceived(),
-		"rpc_err":        respErr,
+		"events":          len(resp.Frame.Events),
+		"block":           resp.Block.Index(),
+		"round_received":  resp.Block.RoundReceived(),
+		"snapshot_chunks": len(resp.Manifest.ChunkHashes),
+		"rpc_err":         respErr,
 	}).Debug("Responding to FastForwardRequest")
 
 	rpc.Respond(resp, respErr)
 }
 
+func (n *Node) processSnapshotChunkRequest(rpc net.RPC, cmd *net.SnapshotChunkRequest) {
+	n.logger.WithFields(logrus.Fields{
+		"from":  cmd.FromID,
+		"block": cmd.BlockIndex,
+		"chunk": cmd.Chunk,
+	}).Debug("process SnapshotChunkRequest")
+
+	resp := &net.SnapshotChunkResponse{
+		FromID:      n.core.validator.ID(),
+		GenesisHash: n.genesisHash,
+	}
+
+	// The snapshot is split into chunks when it is first requested, which
+	// need not be by a FastForwardRequest to this node.
+	_, respErr := n.getSnapshotManifest(cmd.BlockIndex)
+	if respErr == nil {
+		resp.Data, respErr = n.snapshots.chunk(cmd.BlockIndex, cmd.Chunk)
+	}
+
+	if respErr != nil {
+		n.logger.WithError(respErr).Debug("Getting Snapshot chunk")
+	}
+
+	rpc.Respond(resp, respErr)
+}
+
 func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 	n.logger.WithFields(logrus.Fields{
 		"peer": cmd.InternalTransaction.Body.Peer,
@@ -256,6 +377,7 @@ func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 	var accepted bool
 	var acceptedRound int
 	var peers []*peers.Peer
+	var reason string
 
 	if ok, _ := cmd.InternalTransaction.Verify(); !ok {
 
@@ -263,6 +385,12 @@ func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 		n.logger.Debug(msg)
 		respErr = fmt.Errorf(msg)
 
+	} else if cmd.InternalTransaction.Body.Type != hashgraph.PEER_ADD {
+
+		msg := "Join request is not a PEER_ADD"
+		n.logger.Debug(msg)
+		respErr = fmt.Errorf(msg)
+
 	} else if _, ok := n.core.peers.ByPubKey[cmd.InternalTransaction.Body.Peer.PubKeyString()]; ok {
 
 		n.logger.Debug("JoinRequest peer is already present")
@@ -277,6 +405,12 @@ func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 
 		peers = n.core.peers.Peers
 
+	} else if err := n.authorizeJoin(cmd.InternalTransaction); err != nil {
+
+		// Refuse early, without going through consensus
+		n.logger.WithError(err).Info("JoinRequest refused")
+		reason = err.Error()
+
 	} else {
 		// Dispatch the InternalTransaction
 		n.coreLock.Lock()
@@ -290,6 +424,7 @@ func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 			accepted = resp.accepted
 			acceptedRound = resp.acceptedRound
 			peers = resp.peers
+			reason = resp.reason
 		case <-timeout:
 			respErr = fmt.Errorf("Timeout waiting for JoinRequest to go through consensus")
 			n.logger.WithError(respErr).Error()
@@ -299,17 +434,122 @@ func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 
 	resp := &net.JoinResponse{
 		FromID:        n.core.validator.ID(),
+		GenesisHash:   n.genesisHash,
 		Accepted:      accepted,
 		AcceptedRound: acceptedRound,
 		Peers:         peers,
+		Reason:        reason,
 	}
 
 	n.logger.WithFields(logrus.Fields{
 		"accepted":       resp.Accepted,
 		"accepted_round": resp.AcceptedRound,
 		"peers":          len(resp.Peers),
+		"reason":         resp.Reason,
 		"rpc_err":        respErr,
 	}).Debug("Responding to JoinRequest")
 
 	rpc.Respond(resp, respErr)
 }
+
+// processLeaveRequest submits the leave request of another node, which could
+// not get it through consensus by itself, and responds when it is processed.
+// Any node may relay the request, which is authenticated by the signature of
+// the leaving peer, and bound to the round from which the peer has been a
+// validator so that it cannot be replayed after the peer rejoins. If the peer
+// was already removed, the response carries the round from which it was.
+func (n *Node) processLeaveRequest(rpc net.RPC, cmd *net.LeaveRequest) {
+	body := cmd.InternalTransaction.Body
+
+	n.logger.WithFields(logrus.Fields{
+		"peer": body.Peer,
+	}).Debug("process LeaveRequest")
+
+	var respErr error
+	var accepted bool
+	var acceptedRound int
+
+	if ok, _ := cmd.InternalTransaction.Verify(); !ok {
+
+		msg := "Unable to verify signature on leave request"
+		n.logger.Debug(msg)
+		respErr = fmt.Errorf(msg)
+
+	} else if body.Type != hashgraph.PEER_REMOVE || body.Proposer != "" {
+
+		msg := "Leave request is not a PEER_REMOVE from the leaving peer"
+		n.logger.Debug(msg)
+		respErr = fmt.Errorf(msg)
+
+	} else if removedRound, ok := n.removedRound(body.Peer.PubKeyString()); ok {
+
+		n.logger.Debug("LeaveRequest peer is already removed")
+
+		accepted = true
+		acceptedRound = removedRound
+
+	} else if round, err := n.validatorRound(body.Peer.PubKeyString()); err != nil {
+
+		n.logger.WithError(err).Debug("LeaveRequest peer is not a validator")
+		respErr = err
+
+	} else if round != body.Round {
+
+		msg := fmt.Sprintf("Leave request must be bound to round %d", round)
+		n.logger.Debug(msg)
+		respErr = fmt.Errorf(msg)
+
+	} else {
+		// Dispatch the InternalTransaction
+		n.coreLock.Lock()
+		promise := n.core.addInternalTransaction(cmd.InternalTransaction)
+		n.coreLock.Unlock()
+
+		//Wait for the InternalTransaction to go through consensus
+		timeout := time.After(n.conf.JoinTimeout)
+		select {
+		case resp := <-promise.respCh:
+			accepted = resp.accepted
+			acceptedRound = resp.acceptedRound
+			if !resp.accepted && resp.reason != "" {
+				respErr = fmt.Errorf(resp.reason)
+			}
+		case <-timeout:
+			respErr = fmt.Errorf("Timeout waiting for LeaveRequest to go through consensus")
+			n.logger.WithError(respErr).Error()
+		}
+	}
+
+	resp := &net.LeaveResponse{
+		FromID:        n.core.validator.ID(),
+		GenesisHash:   n.genesisHash,
+		Accepted:      accepted,
+		AcceptedRound: acceptedRound,
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"accepted":       resp.Accepted,
+		"accepted_round": resp.AcceptedRound,
+		"rpc_err":        respErr,
+	}).Debug("Responding to LeaveRequest")
+
+	rpc.Respond(resp, respErr)
+}
+
+// removedRound returns the round from which the peer with the public key was
+// removed from the validator-set, if it is not a validator anymore.
+func (n *Node) removedRound(pubKey string) (int, bool) {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.removedRoundOf(pubKey)
+}
+
+// validatorRound returns the round from which the peer with the public key has
+// held its place in the validator-set.
+func (n *Node) validatorRound(pubKey string) (int, error) {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.validatorRound(pubKey)
+}
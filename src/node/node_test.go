@@ -0,0 +1,879 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	"github.com/Kdag-K/kdag/src/discovery"
+	"github.com/Kdag-K/kdag/src/genesis"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/net"
+	_state "github.com/Kdag-K/kdag/src/node/state"
+	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/Kdag-K/kdag/src/proxy/dummy"
+)
+
+// initNodes creates and runs n validators, followed by observers that are not
+// in the PeerSet, connected with InmemTransports.
+func initNodes(t *testing.T, n int, observers int) ([]*Node, []*dummy.InmemDummyClient) {
+	validatorKeys := []*ecdsa.PrivateKey{}
+	peerSlice := []*peers.Peer{}
+	transports := []*net.InmemTransport{}
+
+	for i := 0; i < n+observers; i++ {
+		key, _ := keys.GenerateECDSAKey()
+		addr, trans := net.NewInmemTransport("")
+		validatorKeys = append(validatorKeys, key)
+		transports = append(transports, trans)
+		if i < n {
+			peerSlice = append(peerSlice, peers.NewPeer(keys.PublicKeyHex(&key.PublicKey), addr, fmt.Sprintf("node%d", i)))
+		}
+	}
+
+	for _, a := range transports {
+		for _, b := range transports {
+			a.Connect(b.LocalAddr(), b)
+		}
+	}
+
+	peerSet := peers.NewPeerSet(peerSlice)
+
+	nodes := []*Node{}
+	proxies := []*dummy.InmemDummyClient{}
+	for i, key := range validatorKeys {
+		conf := config.NewTestConfig(t, common.TestLogLevel)
+		conf.HeartbeatTimeout = 5 * time.Millisecond
+		conf.SlowHeartbeatTimeout = 10 * time.Millisecond
+		conf.Observer = i >= n
+
+		prox := dummy.NewInmemDummyClient(conf.Logger())
+
+		node := NewNode(conf,
+			NewValidator(key, fmt.Sprintf("node%d", i)),
+			peerSet,
+			peerSet,
+			hg.NewInmemStore(conf.CacheSize),
+			transports[i],
+			prox)
+
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		nodes = append(nodes, node)
+		proxies = append(proxies, prox)
+	}
+
+	for _, n := range nodes {
+		n.RunAsync(true)
+	}
+
+	return nodes, proxies
+}
+
+func shutdownNodes(nodes []*Node) {
+	for _, n := range nodes {
+		n.Shutdown()
+	}
+}
+
+// waitCommitted waits until the proxy has committed at least count
+// transactions.
+func waitCommitted(t *testing.T, prox *dummy.InmemDummyClient, count int) {
+	timeout := time.After(10 * time.Second)
+	for len(prox.GetCommittedTransactions()) < count {
+		select {
+		case <-timeout:
+			t.Fatalf("Timeout waiting for %d transactions, got %d", count, len(prox.GetCommittedTransactions()))
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// tickUntil keeps submitting transactions to the proxy, so that the hashgraph
+// makes progress, until the condition is met or a timeout expires. It is used
+// to wait for the other nodes to catch up with a change.
+func tickUntil(prox *dummy.InmemDummyClient, cond func() bool) {
+	timeout := time.After(10 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			return
+		default:
+			prox.SubmitTx([]byte("tick"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func peerOf(n *Node, id uint32) (validator *peers.Peer, selected *peers.Peer) {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.validators.ByID[id], n.core.peerSelector.getPeers().ByID[id]
+}
+
+func TestRotateKey(t *testing.T) {
+	nodes, proxies := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	rotating := nodes[0]
+	oldKey := rotating.core.validator.Key
+	oldPubKey := rotating.GetPubKey()
+	oldID := rotating.GetID()
+
+	newKey, _ := keys.GenerateECDSAKey()
+	newPubKey := keys.PublicKeyHex(&newKey.PublicKey)
+
+	if _, err := rotating.RotateKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The node switches keys when it reaches the effective round
+	timeout := time.After(10 * time.Second)
+	for rotating.GetPubKey() != newPubKey {
+		select {
+		case <-timeout:
+			t.Fatal("Timeout waiting for the node to switch keys")
+		default:
+			proxies[1].SubmitTx([]byte("tick"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if rotating.GetID() == oldID {
+		t.Fatal("ID should be derived from the new key")
+	}
+
+	// Transactions submitted to the rotated node still go through consensus
+	committed := len(proxies[1].GetCommittedTransactions())
+	for i := 0; i < 5; i++ {
+		proxies[0].SubmitTx([]byte(fmt.Sprintf("rotated%d", i)))
+	}
+	waitCommitted(t, proxies[1], committed+5)
+
+	for i, n := range nodes {
+		n.coreLock.Lock()
+		validators := n.core.validators
+		n.coreLock.Unlock()
+
+		if validators.Len() != 3 {
+			t.Fatalf("Node %d should have 3 validators, not %d", i, validators.Len())
+		}
+
+		if _, ok := validators.ByPubKey[oldPubKey]; ok {
+			t.Fatalf("Node %d still has the old key", i)
+		}
+
+		if validators.Peers[0].PubKeyString() != newPubKey || validators.Peers[0].Moniker != "node0" {
+			t.Fatalf("Node %d should have replaced node0's key in place", i)
+		}
+	}
+
+	// The retired key cannot be reused, as recorded in the validator-sets
+	other, _ := peerOf(nodes[1], nodes[1].GetID())
+	reused := hg.NewInternalTransactionRotation(*other, oldPubKey)
+	if err := reused.Sign(nodes[1].core.validator.Key); err != nil {
+		t.Fatal(err)
+	}
+	if err := reused.SignRotation(oldKey); err != nil {
+		t.Fatal(err)
+	}
+	nodes[1].coreLock.Lock()
+	promise := nodes[1].core.addInternalTransaction(reused)
+	nodes[1].coreLock.Unlock()
+
+	if resp := waitPromise(t, promise, proxies[1]); resp.accepted || resp.reason == "" {
+		t.Fatalf("Rotation to a used key should be refused: %+v", resp)
+	}
+}
+
+func TestUpdatePeer(t *testing.T) {
+	nodes, proxies := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	updating := nodes[0]
+	netAddr := updating.trans.AdvertiseAddr()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- updating.UpdatePeer(netAddr, "renamed")
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for done := false; !done; {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Fatal(err)
+			}
+			done = true
+		case <-timeout:
+			t.Fatal("Timeout waiting for the peer update")
+		default:
+			proxies[1].SubmitTx([]byte("tick"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if updating.core.validator.Moniker != "renamed" {
+		t.Fatalf("Validator moniker should be updated, not %s", updating.core.validator.Moniker)
+	}
+
+	for i, n := range nodes[1:] {
+		tickUntil(proxies[1], func() bool {
+			p, selected := peerOf(n, updating.GetID())
+			return p != nil && p.Moniker == "renamed" && selected != nil && selected.Moniker == "renamed"
+		})
+
+		p, selected := peerOf(n, updating.GetID())
+
+		if p == nil || p.Moniker != "renamed" || p.NetAddr != netAddr {
+			t.Fatalf("Node %d should have the updated peer in its validators: %v", i+1, p)
+		}
+
+		if selected == nil || selected.Moniker != "renamed" {
+			t.Fatalf("Node %d should select the updated peer without restarting: %v", i+1, selected)
+		}
+	}
+
+	// The App is notified of the update
+	changes := proxies[1].GetPeersChanges()
+	if len(changes) != 1 ||
+		len(changes[0].Updated) != 1 ||
+		changes[0].Updated[0].Moniker != "renamed" ||
+		len(changes[0].Added) != 0 ||
+		len(changes[0].Removed) != 0 {
+		t.Fatalf("App should be notified of the peer update: %+v", changes)
+	}
+}
+
+func TestJoinWithDiscovery(t *testing.T) {
+	nodes, _ := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	dir, err := ioutil.TempDir("", "kdag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The validators persist the peers when the validator-set changes
+	validatorStore := peers.NewJSONPeerSet(filepath.Join(dir, "validator"), true)
+	os.Mkdir(filepath.Join(dir, "validator"), 0755)
+	nodes[0].coreLock.Lock()
+	nodes[0].peerStore = validatorStore
+	nodes[0].coreLock.Unlock()
+
+	// The joining node only knows a seed that has left the network, and finds
+	// a validator through discovery.
+	key, _ := keys.GenerateECDSAKey()
+	addr, trans := net.NewInmemTransport("")
+	for _, n := range nodes {
+		other := n.trans.(*net.InmemTransport)
+		trans.Connect(other.LocalAddr(), other)
+		other.Connect(addr, trans)
+	}
+
+	goneKey, _ := keys.GenerateECDSAKey()
+	stale := peers.NewPeerSet([]*peers.Peer{
+		peers.NewPeer(keys.PublicKeyHex(&goneKey.PublicKey), "gone", "gone"),
+	})
+
+	conf := config.NewTestConfig(t, common.TestLogLevel)
+	conf.HeartbeatTimeout = 5 * time.Millisecond
+	conf.SlowHeartbeatTimeout = 10 * time.Millisecond
+
+	joiner := NewNode(conf,
+		NewValidator(key, "joiner"),
+		stale,
+		nodes[0].core.genesisPeers,
+		hg.NewInmemStore(conf.CacheSize),
+		trans,
+		dummy.NewInmemDummyClient(conf.Logger()))
+
+	validator := nodes[1].core.validator
+	joiner.SetDiscovery(discovery.NewDiscovery(conf.Logger(),
+		discovery.NewStaticSource([]*peers.Peer{
+			peers.NewPeer(validator.PublicKeyHex(), nodes[1].trans.LocalAddr(), validator.Moniker),
+		})))
+
+	joinerStore := peers.NewJSONPeerSet(dir, true)
+	joiner.SetPeerStore(joinerStore)
+
+	if err := joiner.Init(); err != nil {
+		t.Fatal(err)
+	}
+	joiner.RunAsync(true)
+	defer joiner.Shutdown()
+
+	persisted := func(store *peers.JSONPeerSet) *peers.PeerSet {
+		timeout := time.After(10 * time.Second)
+		for {
+			ps, err := store.PeerSet()
+			if err == nil && ps.Len() == 4 {
+				return ps
+			}
+			select {
+			case <-timeout:
+				t.Fatalf("Timeout waiting for 4 persisted peers: %v", err)
+			default:
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+	}
+
+	persisted(validatorStore)
+	ps := persisted(joinerStore)
+
+	if _, ok := ps.ByPubKey[keys.PublicKeyHex(&goneKey.PublicKey)]; ok {
+		t.Fatal("Joiner should have forgotten the seed that left")
+	}
+
+	if _, ok := ps.ByID[joiner.GetID()]; !ok {
+		t.Fatal("Joiner should have persisted itself")
+	}
+}
+
+func TestJoinRefusedByPolicy(t *testing.T) {
+	nodes, proxies := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	for _, n := range nodes {
+		n.coreLock.Lock()
+		n.core.joinAuthorizer = NewJoinPolicy(&genesis.JoinPolicy{MaxValidators: 4})
+		n.coreLock.Unlock()
+	}
+
+	// Two joins compete for the last place. Both pass the early check against
+	// the current validator-set, so the policy must refuse one of them when
+	// the Block is committed.
+	promises := []*joinPromise{}
+	joiners := []*peers.Peer{}
+	for i := 0; i < 2; i++ {
+		key, _ := keys.GenerateECDSAKey()
+		peer := peers.NewPeer(keys.PublicKeyHex(&key.PublicKey), "", fmt.Sprintf("joiner%d", i))
+
+		join := hg.NewInternalTransactionJoin(*peer)
+		join.Sign(key)
+
+		if err := nodes[0].authorizeJoin(join); err != nil {
+			t.Fatal(err)
+		}
+
+		nodes[0].coreLock.Lock()
+		promises = append(promises, nodes[0].core.addInternalTransaction(join))
+		nodes[0].coreLock.Unlock()
+
+		joiners = append(joiners, peer)
+	}
+
+	responses := make([]joinPromiseResponse, len(promises))
+	for i, p := range promises {
+		responded := false
+		tickUntil(proxies[0], func() bool {
+			select {
+			case responses[i] = <-p.respCh:
+				responded = true
+			default:
+			}
+			return responded
+		})
+		if !responded {
+			t.Fatalf("Timeout waiting for join %d", i)
+		}
+	}
+
+	if responses[0].accepted == responses[1].accepted {
+		t.Fatalf("Exactly one join should be accepted, got %v and %v", responses[0].accepted, responses[1].accepted)
+	}
+
+	refused := 0
+	if responses[0].accepted {
+		refused = 1
+	}
+
+	if responses[refused].reason == "" {
+		t.Fatal("Refused join should give a reason")
+	}
+
+	for i, n := range nodes {
+		tickUntil(proxies[0], func() bool {
+			validators, _ := peerOf(n, joiners[1-refused].ID())
+			return validators != nil
+		})
+
+		n.coreLock.Lock()
+		validators := n.core.validators
+		n.coreLock.Unlock()
+
+		if validators.Len() != 4 {
+			t.Fatalf("Node %d should have 4 validators, not %d", i, validators.Len())
+		}
+
+		if _, ok := validators.ByID[joiners[refused].ID()]; ok {
+			t.Fatalf("Node %d should not have added the refused peer", i)
+		}
+	}
+}
+
+// leaveAndTick makes the node leave, while another node keeps submitting
+// transactions so that the hashgraph makes progress, and returns the states
+// that the node went through.
+func leaveAndTick(t *testing.T, leaving *Node, prox *dummy.InmemDummyClient) []_state.State {
+	states := []_state.State{}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- leaving.LeaveWithProgress(func(state _state.State) {
+			states = append(states, state)
+		})
+	}()
+
+	timeout := time.After(15 * time.Second)
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Fatal(err)
+			}
+			return states
+		case <-timeout:
+			t.Fatal("Timeout waiting for the node to leave")
+		default:
+			prox.SubmitTx([]byte("tick"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func checkLeft(t *testing.T, leaving *Node, states []_state.State, others []*Node, prox *dummy.InmemDummyClient) {
+	expected := []_state.State{_state.Leaving, _state.LeaveAccepted, _state.Left}
+	if fmt.Sprint(states) != fmt.Sprint(expected) {
+		t.Fatalf("States should be %v, not %v", expected, states)
+	}
+
+	if leaving.GetState() != _state.Shutdown {
+		t.Fatalf("Node should be shut down, not %v", leaving.GetState())
+	}
+
+	validatorsOf := func(n *Node) *peers.PeerSet {
+		n.coreLock.Lock()
+		defer n.coreLock.Unlock()
+		return n.core.validators
+	}
+
+	for i, n := range others {
+		tickUntil(prox, func() bool {
+			return validatorsOf(n).Len() == len(others)
+		})
+
+		validators := validatorsOf(n)
+
+		if validators.Len() != len(others) {
+			t.Fatalf("Node %d should have %d validators, not %d", i, len(others), validators.Len())
+		}
+
+		if _, ok := validators.ByPubKey[leaving.GetPubKey()]; ok {
+			t.Fatalf("Node %d still has the leaving validator", i)
+		}
+	}
+}
+
+func TestLeave(t *testing.T) {
+	nodes, proxies := initNodes(t, 4, 0)
+	defer shutdownNodes(nodes)
+
+	// Leave requests bound to another round than the peer's are refused
+	replayed := hg.NewInternalTransactionLeave(*nodes[0].core.validators.ByID[nodes[0].GetID()], 5)
+	if err := replayed.Sign(nodes[0].core.validator.Key); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].coreLock.Lock()
+	promise := nodes[0].core.addInternalTransaction(replayed)
+	nodes[0].coreLock.Unlock()
+
+	if resp := waitPromise(t, promise, proxies[1]); resp.accepted || resp.reason == "" {
+		t.Fatalf("Leave request bound to the wrong round should be refused: %+v", resp)
+	}
+
+	states := leaveAndTick(t, nodes[0], proxies[1])
+
+	checkLeft(t, nodes[0], states, nodes[1:], proxies[1])
+
+	// Peers report the round from which the node was removed to late leave
+	// requests
+	removedRound, ok := nodes[1].removedRound(nodes[0].GetPubKey())
+	if !ok {
+		t.Fatal("Node should be reported as removed")
+	}
+
+	nodes[1].coreLock.Lock()
+	lastPeerChangeRound := nodes[1].core.lastPeerChangeRound
+	nodes[1].coreLock.Unlock()
+
+	if removedRound != lastPeerChangeRound {
+		t.Fatalf("Node should be removed at round %d, not %d", lastPeerChangeRound, removedRound)
+	}
+
+	// The App is notified of the removal
+	changes := proxies[1].GetPeersChanges()
+	if len(changes) != 1 {
+		t.Fatalf("App should be notified of 1 peers change, not %d", len(changes))
+	}
+
+	change := changes[0]
+	if len(change.PreviousPeers) != 4 || len(change.FuturePeers) != 3 {
+		t.Fatalf("Peers change should be from 4 to 3 peers, not from %d to %d", len(change.PreviousPeers), len(change.FuturePeers))
+	}
+
+	if change.EffectiveRound != change.DecidedRound+6 || change.EffectiveRound != lastPeerChangeRound {
+		t.Fatalf("Peers change decided at round %d should be effective at round %d, not %d", change.DecidedRound, lastPeerChangeRound, change.EffectiveRound)
+	}
+
+	if len(change.Added) != 0 ||
+		len(change.Updated) != 0 ||
+		len(change.Removed) != 1 ||
+		change.Removed[0].PubKeyString() != nodes[0].GetPubKey() {
+		t.Fatalf("Peers change should only remove the leaving peer: %+v", change)
+	}
+}
+
+// TestLeaveThroughPeers checks that a node which cannot get its leave request
+// through consensus by itself, here because it is suspended, submits it
+// through the other peers.
+func TestLeaveThroughPeers(t *testing.T) {
+	nodes, proxies := initNodes(t, 4, 0)
+	defer shutdownNodes(nodes)
+
+	nodes[0].Suspend()
+
+	states := leaveAndTick(t, nodes[0], proxies[1])
+
+	checkLeft(t, nodes[0], states, nodes[1:], proxies[1])
+}
+
+// waitPromise waits for a promise, while another node keeps submitting
+// transactions so that the hashgraph makes progress.
+func waitPromise(t *testing.T, promise *joinPromise, prox *dummy.InmemDummyClient) joinPromiseResponse {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case resp := <-promise.respCh:
+			return resp
+		case <-timeout:
+			t.Fatal("Timeout waiting for the promise")
+		default:
+			prox.SubmitTx([]byte("tick"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+// voteAndTick submits a vote, while another node keeps submitting
+// transactions so that the hashgraph makes progress, and returns true if the
+// vote completed the change.
+func voteAndTick(t *testing.T, vote func() (bool, error), prox *dummy.InmemDummyClient) bool {
+	type result struct {
+		done bool
+		err  error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		done, err := vote()
+		resCh <- result{done, err}
+	}()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case res := <-resCh:
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			return res.done
+		case <-timeout:
+			t.Fatal("Timeout waiting for the vote")
+		default:
+			prox.SubmitTx([]byte("tick"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+// proposeRemoval makes the node vote to remove the target, bound to the given
+// round, and waits for the vote to go through consensus.
+func proposeRemoval(t *testing.T, n *Node, target *Node, round int, prox *dummy.InmemDummyClient) bool {
+	itx := hg.NewInternalTransactionRemoval(peers.Peer{PubKeyHex: target.GetPubKey()}, n.GetPubKey(), round)
+	if err := itx.Sign(n.core.validator.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	return voteAndTick(t, func() (bool, error) { return n.ProposeRemoval(itx) }, prox)
+}
+
+func TestProposeRemoval(t *testing.T) {
+	nodes, proxies := initNodes(t, 4, 0)
+	defer shutdownNodes(nodes)
+
+	target := nodes[3]
+	proposal := removalProposal(target.GetPubKey(), 0)
+
+	// Votes bound to another round than the target's are refused by consensus
+	replayed := hg.NewInternalTransactionRemoval(peers.Peer{PubKeyHex: target.GetPubKey()}, nodes[0].GetPubKey(), 5)
+	if err := replayed.Sign(nodes[0].core.validator.Key); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].coreLock.Lock()
+	promise := nodes[0].core.addInternalTransaction(replayed)
+	nodes[0].coreLock.Unlock()
+
+	if resp := waitPromise(t, promise, proxies[1]); resp.accepted || resp.pending || resp.reason == "" {
+		t.Fatalf("Removal bound to the wrong round should be refused: %+v", resp)
+	}
+
+	// A supermajority of 3 votes is needed to remove a validator out of 4
+	for _, n := range nodes[:2] {
+		if proposeRemoval(t, n, target, 0, proxies[1]) {
+			t.Fatal("Removal should be pending")
+		}
+	}
+
+	votesOf := func(n *Node) []string {
+		n.coreLock.Lock()
+		defer n.coreLock.Unlock()
+		return n.core.hg.Votes[proposal]
+	}
+
+	// The votes are part of the consensus state, and carried in Frames
+	frameVotesOf := func(n *Node) []string {
+		n.coreLock.Lock()
+		defer n.coreLock.Unlock()
+		frame, err := n.core.hg.Store.GetFrame(*n.core.hg.LastConsensusRound)
+		if err != nil {
+			return nil
+		}
+		return frame.Votes[proposal]
+	}
+
+	for i, n := range nodes {
+		tickUntil(proxies[1], func() bool {
+			return len(frameVotesOf(n)) == 2
+		})
+
+		if votes := votesOf(n); len(votes) != 2 {
+			t.Fatalf("Node %d should count 2 votes, not %v", i, votes)
+		}
+
+		if votes := frameVotesOf(n); len(votes) != 2 {
+			t.Fatalf("Node %d should carry 2 votes in its last Frame, not %v", i, votes)
+		}
+	}
+
+	if !proposeRemoval(t, nodes[2], target, 0, proxies[1]) {
+		t.Fatal("Third vote should remove the validator")
+	}
+
+	for i, n := range nodes[:3] {
+		tickUntil(proxies[1], func() bool {
+			return votesOf(n) == nil
+		})
+
+		n.coreLock.Lock()
+		_, ok := n.core.validators.ByPubKey[target.GetPubKey()]
+		n.coreLock.Unlock()
+
+		if ok {
+			t.Fatalf("Node %d still has the removed validator", i)
+		}
+
+		if votes := votesOf(n); votes != nil {
+			t.Fatalf("Node %d should forget the votes, not %v", i, votes)
+		}
+	}
+}
+
+func TestSetWeight(t *testing.T) {
+	nodes, proxies := initNodes(t, 4, 0)
+	defer shutdownNodes(nodes)
+
+	target := nodes[3]
+
+	// A supermajority of 3 votes is needed to change a weight out of 4
+	for _, n := range nodes[:2] {
+		if voteAndTick(t, func() (bool, error) { return n.SetWeight(target.GetPubKey(), 5) }, proxies[1]) {
+			t.Fatal("Weight change should be pending")
+		}
+	}
+
+	if !voteAndTick(t, func() (bool, error) { return nodes[2].SetWeight(target.GetPubKey(), 5) }, proxies[1]) {
+		t.Fatal("Third vote should change the weight")
+	}
+
+	for i, n := range nodes {
+		tickUntil(proxies[1], func() bool {
+			p, _ := peerOf(n, target.GetID())
+			return p != nil && p.Weight == 5
+		})
+
+		if p, _ := peerOf(n, target.GetID()); p == nil || p.Weight != 5 {
+			t.Fatalf("Node %d should have changed the weight to 5: %v", i, p)
+		}
+	}
+
+	// Votes bound to the round before the change are refused
+	replayed := hg.NewInternalTransactionWeight(peers.Peer{PubKeyHex: target.GetPubKey()}, 7, nodes[0].GetPubKey(), 0)
+	if err := replayed.Sign(nodes[0].core.validator.Key); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].coreLock.Lock()
+	promise := nodes[0].core.addInternalTransaction(replayed)
+	nodes[0].coreLock.Unlock()
+
+	if resp := waitPromise(t, promise, proxies[1]); resp.accepted || resp.pending || resp.reason == "" {
+		t.Fatalf("Weight change bound to the wrong round should be refused: %+v", resp)
+	}
+
+	// The heavier validator alone cannot change its weight
+	if voteAndTick(t, func() (bool, error) { return target.SetWeight(target.GetPubKey(), 1) }, proxies[1]) {
+		t.Fatal("Weight change should be pending")
+	}
+}
+
+func TestSelectFastForwardResponse(t *testing.T) {
+	peerSlice := []*peers.Peer{}
+	for i := 0; i < 5; i++ {
+		key, _ := keys.GenerateECDSAKey()
+		peerSlice = append(peerSlice,
+			peers.NewPeer(keys.PublicKeyHex(&key.PublicKey), fmt.Sprintf("addr%d", i), fmt.Sprintf("node%d", i)))
+	}
+
+	// The last peer is not a validator
+	validators := peers.NewPeerSet(peerSlice[:4])
+
+	response := func(blockIndex int, snapshotHash string) *net.FastForwardResponse {
+		frame := hg.Frame{Round: blockIndex}
+		frameHash, _ := frame.Hash()
+		block := hg.NewBlock(blockIndex, blockIndex, frameHash, peerSlice[:4], [][]byte{}, []hg.InternalTransaction{}, 0)
+		return &net.FastForwardResponse{
+			Block:    *block,
+			Frame:    frame,
+			Manifest: net.SnapshotManifest{BlockIndex: blockIndex, Hash: []byte(snapshotHash)},
+		}
+	}
+
+	id := func(i int) uint32 {
+		return peerSlice[i].ID()
+	}
+
+	cases := []struct {
+		name      string
+		responses map[uint32]*net.FastForwardResponse
+		expected  int
+	}{
+		{
+			name: "agreement",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(2, "snapshot"),
+				id(2): response(2, "snapshot"),
+			},
+			expected: 2,
+		},
+		{
+			name: "not enough agreement",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(3, "snapshot"),
+			},
+			expected: -1,
+		},
+		{
+			name: "peers ahead vouch",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(2, "snapshot"),
+				id(2): response(3, "snapshot"),
+			},
+			expected: 2,
+		},
+		{
+			name: "chain advancing",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(3, "snapshot"),
+				id(2): response(4, "snapshot"),
+				id(3): response(4, "snapshot"),
+			},
+			expected: 3,
+		},
+		{
+			name: "single peer ahead",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(2, "snapshot"),
+				id(2): response(2, "snapshot"),
+				id(3): response(5, "snapshot"),
+			},
+			expected: 2,
+		},
+		{
+			name: "tampered snapshot",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(2, "snapshot"),
+				id(2): response(2, "tampered"),
+			},
+			expected: -1,
+		},
+		{
+			name: "non-validators do not count",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(2, "snapshot"),
+				id(4): response(2, "snapshot"),
+			},
+			expected: -1,
+		},
+		{
+			name: "non-validators ahead do not vouch",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(2, "snapshot"),
+				id(1): response(2, "snapshot"),
+				id(4): response(3, "snapshot"),
+			},
+			expected: -1,
+		},
+		{
+			name: "most recent confirmed",
+			responses: map[uint32]*net.FastForwardResponse{
+				id(0): response(3, "snapshot"),
+				id(1): response(3, "snapshot"),
+				id(2): response(3, "snapshot"),
+				id(3): response(3, "snapshot"),
+			},
+			expected: 3,
+		},
+	}
+
+	for _, c := range cases {
+		best, _ := selectFastForwardResponse(c.responses, validators)
+
+		index := -1
+		if best != nil {
+			index = best.Block.Index()
+		}
+
+		if index != c.expected {
+			t.Fatalf("%s: selected Block should be %d, not %d", c.name, c.expected, index)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/net"
+	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/Kdag-K/kdag/src/proxy/dummy"
+)
+
+func TestObserver(t *testing.T) {
+	validatorKeys := []*ecdsa.PrivateKey{}
+	peerSlice := []*peers.Peer{}
+	transports := []*net.InmemTransport{}
+
+	// 3 validators and 1 observer, which is not in the PeerSet
+	for i := 0; i < 4; i++ {
+		key, _ := keys.GenerateECDSAKey()
+		addr, trans := net.NewInmemTransport("")
+		validatorKeys = append(validatorKeys, key)
+		transports = append(transports, trans)
+		if i < 3 {
+			peerSlice = append(peerSlice, peers.NewPeer(keys.PublicKeyHex(&key.PublicKey), addr, fmt.Sprintf("node%d", i)))
+		}
+	}
+
+	for _, a := range transports {
+		for _, b := range transports {
+			a.Connect(b.LocalAddr(), b)
+		}
+	}
+
+	peerSet := peers.NewPeerSet(peerSlice)
+
+	nodes := []*Node{}
+	proxies := []*dummy.InmemDummyClient{}
+	for i, key := range validatorKeys {
+		conf := config.NewTestConfig(t, common.TestLogLevel)
+		conf.HeartbeatTimeout = 5 * time.Millisecond
+		conf.SlowHeartbeatTimeout = 10 * time.Millisecond
+		conf.Observer = i == 3
+
+		prox := dummy.NewInmemDummyClient(conf.Logger())
+
+		node := NewNode(conf,
+			NewValidator(key, fmt.Sprintf("node%d", i)),
+			peerSet,
+			peerSet,
+			hg.NewInmemStore(conf.CacheSize),
+			transports[i],
+			prox)
+
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		nodes = append(nodes, node)
+		proxies = append(proxies, prox)
+	}
+
+	for _, n := range nodes {
+		n.RunAsync(true)
+		defer n.Shutdown()
+	}
+
+	observer := nodes[3]
+
+	// transactions submitted to the observer are dropped
+	proxies[3].SubmitTx([]byte("dropped"))
+	for i := 0; i < 10; i++ {
+		proxies[i%3].SubmitTx([]byte(fmt.Sprintf("tx%d", i)))
+	}
+
+	timeout := time.After(10 * time.Second)
+	for len(proxies[3].GetCommittedTransactions()) < 10 {
+		select {
+		case <-timeout:
+			t.Fatalf("Observer did not follow consensus: %d transactions", len(proxies[3].GetCommittedTransactions()))
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	for _, tx := range proxies[3].GetCommittedTransactions() {
+		if string(tx) == "dropped" {
+			t.Fatal("Transaction submitted to the observer should be dropped")
+		}
+	}
+
+	observerPubKey := observer.GetPubKey()
+
+	for i, n := range nodes {
+		n.coreLock.Lock()
+		_, created := n.core.hg.Store.RepertoireByPubKey()[observerPubKey]
+		_, inPeers := n.core.peers.ByPubKey[observerPubKey]
+		n.coreLock.Unlock()
+
+		if created {
+			t.Fatalf("Node %d knows Events from the observer", i)
+		}
+
+		if inPeers {
+			t.Fatalf("Node %d has the observer in its PeerSet", i)
+		}
+	}
+
+	block, err := observer.GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pubKey := range block.Signatures {
+		if pubKey == observerPubKey {
+			t.Fatal("Observer should not sign Blocks")
+		}
+	}
+}
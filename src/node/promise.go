@@ -10,6 +10,12 @@ type joinPromiseResponse struct {
 	accepted      bool
 	acceptedRound int
 	peers         []*peers.Peer
+	// pending is true when the InternalTransaction only recorded a vote,
+	// which does not change the validator-set yet.
+	pending bool
+	// reason explains why the InternalTransaction was refused, if it was
+	// refused by the join policy or by the validators.
+	reason string
 }
 
 // joinPromise is a relay between the requestJoin RPC handler (which receives
@@ -33,5 +39,17 @@ func newJoinPromise(tx hashgraph.InternalTransaction) *joinPromise {
 
 // respond handles sending a joinPromiseResponse to a joinPromise
 func (p *joinPromise) respond(accepted bool, acceptedRound int, peers []*peers.Peer) {
-	p.respCh <- joinPromiseResponse{accepted, acceptedRound, peers}
+	p.respCh <- joinPromiseResponse{accepted: accepted, acceptedRound: acceptedRound, peers: peers}
+}
+
+// pend sends a joinPromiseResponse for an InternalTransaction that recorded a
+// vote without completing the change it votes for.
+func (p *joinPromise) pend() {
+	p.respCh <- joinPromiseResponse{pending: true, peers: []*peers.Peer{}}
+}
+
+// refuse sends a joinPromiseResponse that refuses the InternalTransaction for
+// the given reason.
+func (p *joinPromise) refuse(reason string) {
+	p.respCh <- joinPromiseResponse{peers: []*peers.Peer{}, reason: reason}
 }
@@ -0,0 +1,455 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/net"
+	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/Kdag-K/kdag/src/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// servedSnapshots is the number of snapshots that a node keeps in chunks
+	// to serve them to fast-syncing nodes.
+	servedSnapshots = 2
+
+	// maxChunkFailures is the number of chunks that a peer can fail to serve
+	// before it is not asked for chunks any more.
+	maxChunkFailures = 3
+)
+
+// snapshotStore splits the snapshots served to fast-syncing nodes into chunks
+// and keeps them on disk, so that they can be fetched in parallel without
+// holding them in memory. The chunks of a snapshot are stored one after the
+// other in a single file, named after the index of the corresponding Block.
+// Only the most recent snapshots are kept.
+type snapshotStore struct {
+	sync.Mutex
+
+	dir       string
+	chunkSize int
+	manifests map[int]*net.SnapshotManifest
+}
+
+func newSnapshotStore(dir string, chunkSize int) *snapshotStore {
+	return &snapshotStore{
+		dir:       dir,
+		chunkSize: chunkSize,
+		manifests: make(map[int]*net.SnapshotManifest),
+	}
+}
+
+func (s *snapshotStore) path(blockIndex int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.snapshot", blockIndex))
+}
+
+// manifest returns the manifest of the snapshot corresponding to a Block. If
+// the snapshot is not in the store yet, it is read from the reader returned by
+// open, and split into chunks.
+func (s *snapshotStore) manifest(blockIndex int, open func() (io.ReadCloser, error)) (*net.SnapshotManifest, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if m, ok := s.manifests[blockIndex]; ok {
+		return m, nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// Write to a temporary file first, so that an interrupted split does not
+	// leave a truncated snapshot behind.
+	f, err := ioutil.TempFile(s.dir, "split")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	manifest := &net.SnapshotManifest{
+		BlockIndex:  blockIndex,
+		ChunkSize:   s.chunkSize,
+		ChunkHashes: [][]byte{},
+	}
+
+	hash := sha256.New()
+	w := io.MultiWriter(f, hash)
+
+	buf := make([]byte, s.chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				f.Close()
+				return nil, werr
+			}
+			manifest.Size += int64(n)
+			manifest.ChunkHashes = append(manifest.ChunkHashes, crypto.SHA256(buf[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	manifest.Hash = hash.Sum(nil)
+
+	if err := os.Rename(f.Name(), s.path(blockIndex)); err != nil {
+		return nil, err
+	}
+
+	s.manifests[blockIndex] = manifest
+	s.prune()
+
+	return manifest, nil
+}
+
+// prune removes the oldest snapshots, so that only servedSnapshots are kept.
+func (s *snapshotStore) prune() {
+	indexes := []int{}
+	for i := range s.manifests {
+		indexes = append(indexes, i)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+
+	for i := servedSnapshots; i < len(indexes); i++ {
+		os.Remove(s.path(indexes[i]))
+		delete(s.manifests, indexes[i])
+	}
+}
+
+// chunk returns a chunk of a snapshot that is in the store.
+func (s *snapshotStore) chunk(blockIndex int, chunk int) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	m, ok := s.manifests[blockIndex]
+	if !ok {
+		return nil, fmt.Errorf("Snapshot %d not found", blockIndex)
+	}
+
+	offset, size, err := chunkRange(m, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(s.path(blockIndex))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// chunkRange returns the offset and size of a chunk in the snapshot described
+// by the manifest.
+func chunkRange(m *net.SnapshotManifest, chunk int) (offset int64, size int, err error) {
+	if chunk < 0 || chunk >= len(m.ChunkHashes) {
+		return 0, 0, fmt.Errorf("Chunk %d out of range [0, %d)", chunk, len(m.ChunkHashes))
+	}
+
+	offset = int64(chunk) * int64(m.ChunkSize)
+
+	size = m.ChunkSize
+	if rest := m.Size - offset; rest < int64(size) {
+		size = int(rest)
+	}
+
+	return offset, size, nil
+}
+
+// manifestID identifies the content described by a manifest.
+func manifestID(m *net.SnapshotManifest) string {
+	hash := []byte{}
+	for _, h := range m.ChunkHashes {
+		hash = crypto.SimpleHashFromTwoHashes(hash, h)
+	}
+	return common.EncodeToString(hash)
+}
+
+/*******************************************************************************
+Serving snapshots
+*******************************************************************************/
+
+// openSnapshot returns a reader over the App's snapshot of a Block. The
+// snapshot is streamed if the AppGateway supports it.
+func (n *Node) openSnapshot(blockIndex int) (io.ReadCloser, error) {
+	if gateway, ok := n.proxy.(proxy.SnapshotStreamGateway); ok {
+		return gateway.GetSnapshotStream(blockIndex)
+	}
+
+	snapshot, err := n.proxy.GetSnapshot(blockIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(snapshot)), nil
+}
+
+// getSnapshotManifest returns the manifest of the App's snapshot of a Block,
+// splitting the snapshot into chunks if necessary.
+func (n *Node) getSnapshotManifest(blockIndex int) (*net.SnapshotManifest, error) {
+	return n.snapshots.manifest(blockIndex, func() (io.ReadCloser, error) {
+		return n.openSnapshot(blockIndex)
+	})
+}
+
+/*******************************************************************************
+Fetching snapshots
+*******************************************************************************/
+
+// fetchSnapshot downloads the chunks of the snapshot described by the manifest
+// from the sources, in parallel, and returns the file they were assembled in.
+// Every chunk is checked against its hash in the manifest, and a source that
+// fails to serve maxChunkFailures chunks is not used any more. The file is kept
+// if the download fails, so that the next attempt only fetches the missing
+// chunks. Once assembled, the snapshot is checked against the hash of the
+// manifest, which catches chunk hashes that do not describe the snapshot.
+func (n *Node) fetchSnapshot(manifest *net.SnapshotManifest, sources []*peers.Peer) (*os.File, error) {
+	if err := os.MkdirAll(n.conf.SnapshotsDir(), 0700); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(n.conf.SnapshotsDir(),
+		fmt.Sprintf("%d-%s.partial", manifest.BlockIndex, manifestID(manifest)))
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Truncate(manifest.Size); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Resume from a previous attempt
+	missing := []int{}
+	for i := range manifest.ChunkHashes {
+		if !hasChunk(f, manifest, i) {
+			missing = append(missing, i)
+		}
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"block":   manifest.BlockIndex,
+		"size":    manifest.Size,
+		"chunks":  len(manifest.ChunkHashes),
+		"missing": len(missing),
+		"sources": len(sources),
+	}).Debug("Fetching snapshot")
+
+	if err := n.fetchChunks(f, manifest, missing, sources); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if !bytes.Equal(hash.Sum(nil), manifest.Hash) {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("Snapshot %d does not match the hash of its manifest", manifest.BlockIndex)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// hasChunk returns true if the file already contains the chunk.
+func hasChunk(f *os.File, manifest *net.SnapshotManifest, chunk int) bool {
+	offset, size, _ := chunkRange(manifest, chunk)
+
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, offset); err != nil {
+		return false
+	}
+
+	return bytes.Equal(crypto.SHA256(data), manifest.ChunkHashes[chunk])
+}
+
+// fetchChunks fetches the missing chunks with one worker per source. A chunk
+// that a source fails to serve is put back in the queue for the other
+// workers.
+func (n *Node) fetchChunks(f *os.File, manifest *net.SnapshotManifest, missing []int, sources []*peers.Peer) error {
+	type result struct {
+		err     error
+		dropped bool
+	}
+
+	pending := make(chan int, len(missing))
+	for _, i := range missing {
+		pending <- i
+	}
+
+	results := make(chan result)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	for _, p := range sources {
+		go func(p *peers.Peer) {
+			failures := 0
+			for {
+				select {
+				case <-quit:
+					return
+				case i := <-pending:
+					err := n.fetchChunk(f, manifest, i, p)
+					if err != nil {
+						pending <- i
+						failures++
+						err = fmt.Errorf("Fetching chunk %d from %s: %v", i, p.NetAddr, err)
+					}
+
+					dropped := failures >= maxChunkFailures
+					select {
+					case results <- result{err, dropped}:
+					case <-quit:
+						return
+					}
+
+					if dropped {
+						return
+					}
+				}
+			}
+		}(p)
+	}
+
+	remaining := len(missing)
+	active := len(sources)
+	for remaining > 0 {
+		if active == 0 {
+			return fmt.Errorf("No peer could serve the %d missing chunks of snapshot %d", remaining, manifest.BlockIndex)
+		}
+
+		r := <-results
+		if r.err == nil {
+			remaining--
+			continue
+		}
+
+		n.logger.WithError(r.err).Warn("fetchChunk()")
+		if r.dropped {
+			active--
+		}
+	}
+
+	return nil
+}
+
+// fetchChunk fetches a chunk from a peer, verifies it, and writes it at its
+// place in the file.
+func (n *Node) fetchChunk(f *os.File, manifest *net.SnapshotManifest, chunk int, p *peers.Peer) error {
+	offset, size, err := chunkRange(manifest, chunk)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.requestSnapshotChunk(p.NetAddr, manifest.BlockIndex, chunk)
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Data) != size ||
+		!bytes.Equal(crypto.SHA256(resp.Data), manifest.ChunkHashes[chunk]) {
+		return fmt.Errorf("Chunk does not match manifest")
+	}
+
+	_, err = f.WriteAt(resp.Data, offset)
+	return err
+}
+
+// restoreSnapshot fetches the snapshot of the Block that the node
+// fast-forwards to, and streams it to the App, which loads it into a staged
+// state. The App's state is only replaced once the hash of the staged state
+// matches the StateHash of the Block, because the chunks are only checked
+// against a manifest that the peers provide. The AppGateway must implement
+// the SnapshotStreamGateway interface, because Restore replaces the App's
+// state without reporting its hash.
+func (n *Node) restoreSnapshot(block *hg.Block, manifest *net.SnapshotManifest) error {
+	if manifest.BlockIndex != block.Index() {
+		return fmt.Errorf("Snapshot %d does not correspond to Block %d", manifest.BlockIndex, block.Index())
+	}
+
+	gateway, ok := n.proxy.(proxy.SnapshotStreamGateway)
+	if !ok {
+		return fmt.Errorf("AppGateway does not report the restored state hash")
+	}
+
+	f, err := n.fetchSnapshot(manifest, n.joinTargets())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stateHash, err := gateway.RestoreStream(f)
+	if err != nil {
+		return err
+	}
+
+	// The chunks match the manifest, so the manifest itself is wrong. Do not
+	// resume from this snapshot.
+	if !bytes.Equal(stateHash, block.StateHash()) {
+		os.Remove(f.Name())
+
+		if err := gateway.DiscardRestore(); err != nil {
+			n.logger.WithError(err).Error("Discarding restored snapshot")
+		}
+
+		return fmt.Errorf("Restored state hash %X does not match StateHash %X of Block %d",
+			stateHash,
+			block.StateHash(),
+			block.Index())
+	}
+
+	if err := gateway.CommitRestore(); err != nil {
+		return err
+	}
+
+	return os.Remove(f.Name())
+}
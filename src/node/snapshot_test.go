@@ -0,0 +1,384 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/config"
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/net"
+	_state "github.com/Kdag-K/kdag/src/node/state"
+	"github.com/Kdag-K/kdag/src/peers"
+	"github.com/Kdag-K/kdag/src/proxy/dummy"
+)
+
+func openBytes(b []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+func TestSnapshotStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kdag-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := newSnapshotStore(dir, 4)
+
+	snapshot := []byte("0123456789")
+
+	manifest, err := store.manifest(1, openBytes(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Size != 10 || len(manifest.ChunkHashes) != 3 {
+		t.Fatalf("Manifest should have 3 chunks for 10 bytes, not %d for %d", len(manifest.ChunkHashes), manifest.Size)
+	}
+
+	expected := []string{"0123", "4567", "89"}
+	for i, e := range expected {
+		chunk, err := store.chunk(1, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(chunk) != e {
+			t.Fatalf("Chunk %d should be %q, not %q", i, e, chunk)
+		}
+	}
+
+	if _, err := store.chunk(1, 3); err == nil {
+		t.Fatal("Chunk out of range should fail")
+	}
+
+	// The snapshot is only split once
+	if _, err := store.manifest(1, openBytes([]byte("other"))); err != nil {
+		t.Fatal(err)
+	}
+	if chunk, _ := store.chunk(1, 0); string(chunk) != "0123" {
+		t.Fatalf("Snapshot 1 should not have changed, got chunk %q", chunk)
+	}
+
+	// Only the most recent snapshots are kept
+	for i := 2; i <= 3; i++ {
+		if _, err := store.manifest(i, openBytes(snapshot)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.chunk(1, 0); err == nil {
+		t.Fatal("Snapshot 1 should have been pruned")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "1.snapshot")); !os.IsNotExist(err) {
+		t.Fatal("Snapshot 1 should have been removed from disk")
+	}
+}
+
+func TestFetchSnapshot(t *testing.T) {
+	nodes, proxies := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	dir, err := ioutil.TempDir("", "kdag-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, n := range nodes {
+		n.conf.SetDataDir(filepath.Join(dir, fmt.Sprintf("node%d", i)))
+		n.snapshots = newSnapshotStore(n.conf.SnapshotsDir(), 8)
+	}
+
+	for i := 0; i < 5; i++ {
+		proxies[1].SubmitTx([]byte(fmt.Sprintf("tx%d", i)))
+	}
+	waitCommitted(t, proxies[1], 5)
+
+	blockIndex := nodes[1].GetLastBlockIndex()
+
+	manifest, err := nodes[1].getSnapshotManifest(blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := proxies[1].GetSnapshot(blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.ChunkHashes) != (len(expected)+7)/8 {
+		t.Fatalf("Manifest should have %d chunks, not %d", (len(expected)+7)/8, len(manifest.ChunkHashes))
+	}
+
+	unreachable := peers.NewPeer("0X04", "unreachable", "unreachable")
+
+	// No source can serve the chunks
+	if _, err := nodes[0].fetchSnapshot(manifest, []*peers.Peer{unreachable}); err == nil {
+		t.Fatal("Fetching from an unreachable peer should fail")
+	}
+
+	// Unreachable sources are dropped
+	validators := nodes[0].core.validators
+	sources := []*peers.Peer{unreachable, validators.ByID[nodes[1].GetID()], validators.ByID[nodes[2].GetID()]}
+	f, err := nodes[0].fetchSnapshot(manifest, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshotFile(t, f, expected)
+
+	// The snapshot is complete, so fetching it again does not contact anyone
+	f, err = nodes[0].fetchSnapshot(manifest, []*peers.Peer{unreachable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshotFile(t, f, expected)
+
+	// The chunks match, but not the hash of the whole snapshot
+	tampered := *manifest
+	tampered.Hash = []byte("tampered")
+	if _, err := nodes[0].fetchSnapshot(&tampered, sources); err == nil {
+		t.Fatal("Fetching a snapshot that does not match its manifest should fail")
+	}
+}
+
+// TestRestoreMismatchedSnapshot checks that the App's state is only replaced
+// by a restored snapshot whose state hash matches the Block.
+func TestRestoreMismatchedSnapshot(t *testing.T) {
+	nodes, proxies := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	dir, err := ioutil.TempDir("", "kdag-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, n := range nodes {
+		n.conf.SetDataDir(filepath.Join(dir, fmt.Sprintf("node%d", i)))
+		n.snapshots = newSnapshotStore(n.conf.SnapshotsDir(), 8)
+	}
+
+	for i := 0; i < 5; i++ {
+		proxies[1].SubmitTx([]byte(fmt.Sprintf("tx%d", i)))
+	}
+	waitCommitted(t, proxies[1], 5)
+
+	blockIndex := nodes[1].GetLastBlockIndex()
+
+	block, err := nodes[1].GetBlock(blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := nodes[1].getSnapshotManifest(blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A node that is not running, with an App that has an empty state
+	key, _ := keys.GenerateECDSAKey()
+	addr, trans := net.NewInmemTransport("")
+	for _, n := range nodes {
+		other := n.trans.(*net.InmemTransport)
+		trans.Connect(other.LocalAddr(), other)
+		other.Connect(addr, trans)
+	}
+
+	conf := config.NewTestConfig(t, common.TestLogLevel)
+	conf.SetDataDir(filepath.Join(dir, "restoring"))
+
+	prox := dummy.NewInmemDummyClient(conf.Logger())
+
+	peerSet := nodes[0].core.validators
+
+	restoring := NewNode(conf,
+		NewValidator(key, "restoring"),
+		peerSet,
+		peerSet,
+		hg.NewInmemStore(conf.CacheSize),
+		trans,
+		prox)
+
+	if err := restoring.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer restoring.Shutdown()
+
+	// The chunks match the manifest, but the state does not match the Block
+	mismatched := *block
+	mismatched.Body.StateHash = []byte("mismatched")
+
+	if err := restoring.restoreSnapshot(&mismatched, manifest); err == nil {
+		t.Fatal("Restoring a snapshot that does not match the Block should fail")
+	}
+
+	if len(prox.GetStateHash()) != 0 {
+		t.Fatalf("App state should not change after a mismatched restore, got %X", prox.GetStateHash())
+	}
+
+	if err := restoring.restoreSnapshot(block, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(prox.GetStateHash(), block.StateHash()) {
+		t.Fatalf("App state hash should be %X, not %X", block.StateHash(), prox.GetStateHash())
+	}
+}
+
+func checkSnapshotFile(t *testing.T, f *os.File, expected []byte) {
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Fetched snapshot should be %X, not %X", expected, data)
+	}
+}
+
+// TestFastSync checks that a node fast-forwards from a snapshot fetched in
+// chunks, and continues from the restored state.
+func TestFastSync(t *testing.T) {
+	nodes, proxies := initNodes(t, 3, 0)
+	defer shutdownNodes(nodes)
+
+	dir, err := ioutil.TempDir("", "kdag-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, n := range nodes {
+		n.conf.SetDataDir(filepath.Join(dir, fmt.Sprintf("node%d", i)))
+		n.snapshots = newSnapshotStore(n.conf.SnapshotsDir(), 8)
+	}
+
+	for i := 0; i < 20; i++ {
+		proxies[i%3].SubmitTx([]byte(fmt.Sprintf("tx%d", i)))
+	}
+	waitCommitted(t, proxies[0], 20)
+
+	// Wait for an anchor Block past the first one, which is the earliest Block
+	// that a node can fast-forward to.
+	tickUntil(proxies[0], func() bool { return anchorBlock(nodes[0]) >= 1 })
+	if anchorBlock(nodes[0]) < 1 {
+		t.Fatal("Timeout waiting for anchor Block")
+	}
+
+	// The syncing node only fast-forwards to an anchor Block that enough
+	// validators agree on, so let them settle on the same one.
+	timeout := time.After(10 * time.Second)
+	for anchorBlock(nodes[0]) != anchorBlock(nodes[1]) || anchorBlock(nodes[1]) != anchorBlock(nodes[2]) {
+		select {
+		case <-timeout:
+			t.Fatal("Timeout waiting for validators to agree on the anchor Block")
+		default:
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// An observer that fast-forwards, so that it does not need to be added to
+	// the validator-set.
+	key, _ := keys.GenerateECDSAKey()
+	addr, trans := net.NewInmemTransport("")
+	for _, n := range nodes {
+		other := n.trans.(*net.InmemTransport)
+		trans.Connect(other.LocalAddr(), other)
+		other.Connect(addr, trans)
+	}
+
+	conf := config.NewTestConfig(t, common.TestLogLevel)
+	conf.HeartbeatTimeout = 5 * time.Millisecond
+	conf.SlowHeartbeatTimeout = 10 * time.Millisecond
+	conf.Observer = true
+	conf.EnableFastSync = true
+	conf.SetDataDir(filepath.Join(dir, "syncing"))
+
+	prox := dummy.NewInmemDummyClient(conf.Logger())
+
+	peerSet := nodes[0].core.validators
+
+	syncing := NewNode(conf,
+		NewValidator(key, "syncing"),
+		peerSet,
+		peerSet,
+		hg.NewInmemStore(conf.CacheSize),
+		trans,
+		prox)
+
+	if err := syncing.Init(); err != nil {
+		t.Fatal(err)
+	}
+	syncing.RunAsync(true)
+	defer syncing.Shutdown()
+
+	timeout = time.After(10 * time.Second)
+	for syncing.GetState() != _state.Babbling {
+		select {
+		case <-timeout:
+			t.Fatalf("Timeout waiting for fast-sync, state is %v", syncing.GetState())
+		default:
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// The node skipped the first Blocks
+	if _, err := syncing.GetBlock(0); err == nil {
+		t.Fatal("Block 0 should not exist after fast-forward")
+	}
+
+	// The Blocks committed after the fast-forward have the same StateHash on
+	// the validators, which means that the App was restored correctly.
+	committed := len(prox.GetCommittedTransactions())
+	for i := 0; i < 5; i++ {
+		proxies[1].SubmitTx([]byte(fmt.Sprintf("after%d", i)))
+	}
+	waitCommitted(t, prox, committed+5)
+
+	blockIndex := syncing.GetLastBlockIndex()
+
+	block, err := syncing.GetBlock(blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tickUntil(proxies[1], func() bool { return nodes[1].GetLastBlockIndex() >= blockIndex })
+
+	validatorBlock, err := nodes[1].GetBlock(blockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(block.StateHash(), validatorBlock.StateHash()) {
+		t.Fatalf("Block %d should have StateHash %X, not %X", blockIndex, validatorBlock.StateHash(), block.StateHash())
+	}
+
+	// The fetched snapshot is not kept once restored
+	partial, _ := filepath.Glob(filepath.Join(conf.SnapshotsDir(), "*.partial"))
+	if len(partial) != 0 {
+		t.Fatalf("Fetched snapshots should be removed, found %v", partial)
+	}
+}
+
+func anchorBlock(n *Node) int {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	if n.core.hg.AnchorBlock == nil {
+		return -1
+	}
+	return *n.core.hg.AnchorBlock
+}
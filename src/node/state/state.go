@@ -6,7 +6,7 @@ import (
 )
 
 // State captures the state of a Kdag node: Babbling, CatchingUp, Joining,
-// Leaving, Suspended, or Shutdown
+// Leaving, LeaveAccepted, Left, Suspended, or Shutdown
 type State uint32
 
 const (
@@ -34,6 +34,15 @@ const (
 	// Suspended is the state in which a node passively participates in the
 	// gossip protocol but does not process any new events or transactions.
 	Suspended
+
+	// LeaveAccepted is the state in which a leaving node's request has gone
+	// through consensus, and the node keeps gossiping until the hashgraph
+	// reaches the round at which it is removed from the validator-set.
+	LeaveAccepted
+
+	// Left is the state in which a node has been removed from the
+	// validator-set after a leave request, right before it shuts down.
+	Left
 )
 
 // WGLIMIT is the maximum number of goroutines that can be launched through
@@ -55,6 +64,10 @@ func (s State) String() string {
 		return "Shutdown"
 	case Suspended:
 		return "Suspended"
+	case LeaveAccepted:
+		return "LeaveAccepted"
+	case Left:
+		return "Left"
 	default:
 		return "Unknown"
 	}
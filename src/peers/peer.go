@@ -16,6 +16,10 @@ type Peer struct {
 	PubKeyHex string
 	Moniker   string
 
+	// Weight is the voting weight of the Peer. It is optional, and a Peer
+	// without a weight counts as 1.
+	Weight int `json:",omitempty"`
+
 	id uint32
 }
 
@@ -40,6 +44,14 @@ func (p *Peer) ID() uint32 {
 	return p.id
 }
 
+// VotingWeight returns the weight of the Peer in votes and signature counts.
+func (p *Peer) VotingWeight() int {
+	if p.Weight <= 0 {
+		return 1
+	}
+	return p.Weight
+}
+
 // PubKeyString returns the upper-case version of PubKeyHex. It is used for
 // indexing in maps with string keys.
 func (p *Peer) PubKeyString() string {
@@ -2,8 +2,10 @@ package peers
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"math"
+	"strings"
 
 	"github.com/Kdag-K/kdag/src/common"
 	"github.com/Kdag-K/kdag/src/crypto"
@@ -20,6 +22,7 @@ type PeerSet struct {
 	hex           string
 	superMajority *int
 	trustCount    *int
+	totalWeight   *int
 }
 
 /* Constructors */
@@ -63,7 +66,7 @@ func (peerSet *PeerSet) WithNewPeer(peer *Peer) *PeerSet {
 func (peerSet *PeerSet) WithRemovedPeer(peer *Peer) *PeerSet {
 	peers := []*Peer{}
 	for _, p := range peerSet.Peers {
-		if p.PubKeyHex != peer.PubKeyHex {
+		if p.PubKeyString() != peer.PubKeyString() {
 			peers = append(peers, p)
 		}
 	}
@@ -71,6 +74,62 @@ func (peerSet *PeerSet) WithRemovedPeer(peer *Peer) *PeerSet {
 	return newPeerSet
 }
 
+//WithPeerWeight returns a new PeerSet where the weight of the provided peer is
+//set to peer.Weight. The PeerSet is unchanged if it does not contain the peer.
+func (peerSet *PeerSet) WithPeerWeight(peer *Peer) *PeerSet {
+	peers := []*Peer{}
+	for _, p := range peerSet.Peers {
+		if p.PubKeyString() == peer.PubKeyString() {
+			weighted := *p
+			weighted.Weight = peer.Weight
+			p = &weighted
+		}
+		peers = append(peers, p)
+	}
+	newPeerSet := NewPeerSet(peers)
+	return newPeerSet
+}
+
+//WithRotatedKey returns a new PeerSet where the public key of the provided peer
+//is replaced with newPubKey. The Peer keeps its position, address, moniker and
+//weight, but its ID is derived from the new key. The PeerSet is unchanged if it
+//does not contain the peer, or if it already contains the new key.
+func (peerSet *PeerSet) WithRotatedKey(peer *Peer, newPubKey string) *PeerSet {
+	if _, ok := peerSet.ByPubKey[strings.ToUpper(newPubKey)]; ok {
+		return peerSet
+	}
+
+	peers := []*Peer{}
+	for _, p := range peerSet.Peers {
+		if p.PubKeyString() == peer.PubKeyString() {
+			rotated := NewPeer(newPubKey, p.NetAddr, p.Moniker)
+			rotated.Weight = p.Weight
+			p = rotated
+		}
+		peers = append(peers, p)
+	}
+	newPeerSet := NewPeerSet(peers)
+	return newPeerSet
+}
+
+//WithUpdatedPeer returns a new PeerSet where the NetAddr and Moniker of the
+//Peer with the same public key as the provided one are replaced with its own.
+//The PeerSet is unchanged if it does not contain the peer.
+func (peerSet *PeerSet) WithUpdatedPeer(peer *Peer) *PeerSet {
+	peers := []*Peer{}
+	for _, p := range peerSet.Peers {
+		if p.PubKeyString() == peer.PubKeyString() {
+			updated := *p
+			updated.NetAddr = peer.NetAddr
+			updated.Moniker = peer.Moniker
+			p = &updated
+		}
+		peers = append(peers, p)
+	}
+	newPeerSet := NewPeerSet(peers)
+	return newPeerSet
+}
+
 /* ToSlice Methods */
 
 //PubKeys returns the PeerSet's slice of public keys
@@ -102,14 +161,43 @@ func (peerSet *PeerSet) Len() int {
 	return len(peerSet.ByPubKey)
 }
 
+//TotalWeight returns the sum of the voting weights of the Peers
+func (peerSet *PeerSet) TotalWeight() int {
+	if peerSet.totalWeight == nil {
+		val := 0
+		for _, p := range peerSet.ByPubKey {
+			val += p.VotingWeight()
+		}
+		peerSet.totalWeight = &val
+	}
+	return *peerSet.totalWeight
+}
+
+//WeightOf returns the voting weight of the Peer with the given public key, or 0
+//if it does not belong to the PeerSet.
+func (peerSet *PeerSet) WeightOf(pubKey string) int {
+	p, ok := peerSet.ByPubKey[strings.ToUpper(pubKey)]
+	if !ok {
+		return 0
+	}
+	return p.VotingWeight()
+}
+
 // Hash uniquely identifies a PeerSet. It is computed by hashing (SHA256) their
-// public keys together, one by one.
+// public keys together, one by one. The weights of Peers whose voting weight is
+// not 1 are hashed after their public keys, so that unweighted PeerSets keep
+// the same hash.
 func (peerSet *PeerSet) Hash() ([]byte, error) {
 	if len(peerSet.hash) == 0 {
 		hash := []byte{}
 		for _, p := range peerSet.Peers {
 			pk := p.PubKeyBytes()
 			hash = crypto.SimpleHashFromTwoHashes(hash, pk)
+			if w := p.VotingWeight(); w != 1 {
+				wb := make([]byte, 8)
+				binary.BigEndian.PutUint64(wb, uint64(w))
+				hash = crypto.SimpleHashFromTwoHashes(hash, wb)
+			}
 		}
 		peerSet.hash = hash
 	}
@@ -154,21 +242,23 @@ func (peerSet *PeerSet) Unmarshal(peerSliceBytes []byte) error {
 
 	return nil
 }
-//in the PeerSet
+//in the PeerSet, in terms of voting weight
 func (peerSet *PeerSet) SuperMajority() int {
 	if peerSet.superMajority == nil {
-		val := 2*peerSet.Len()/3 + 1
+		val := 2*peerSet.TotalWeight()/3 + 1
 		peerSet.superMajority = &val
 	}
 	return *peerSet.superMajority
 }
 
-//TrustCount calculates the Trust Count for a peerset
+//TrustCount calculates the Trust Count for a peerset, in terms of voting
+//weight. More than TrustCount is needed to guarantee that at least one honest
+//peer is involved.
 func (peerSet *PeerSet) TrustCount() int {
 	if peerSet.trustCount == nil {
 		val := 0
 		if len(peerSet.Peers) > 1 {
-			val = int(math.Ceil(float64(peerSet.Len()) / float64(3)))
+			val = int(math.Ceil(float64(peerSet.TotalWeight()) / float64(3)))
 		}
 		peerSet.trustCount = &val
 	}
@@ -179,4 +269,6 @@ func (peerSet *PeerSet) clearCache() {
 	peerSet.hash = []byte{}
 	peerSet.hex = ""
 	peerSet.superMajority = nil
+	peerSet.trustCount = nil
+	peerSet.totalWeight = nil
 }
@@ -0,0 +1,155 @@
+package peers
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	bkeys "github.com/Kdag-K/kdag/src/crypto/keys"
+)
+
+func newTestPeers(t *testing.T, n int) []*Peer {
+	peers := []*Peer{}
+	for i := 0; i < n; i++ {
+		key, err := bkeys.GenerateECDSAKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, NewPeer(bkeys.PublicKeyHex(&key.PublicKey), fmt.Sprintf("addr%d", i), fmt.Sprintf("peer%d", i)))
+	}
+	return peers
+}
+
+func TestPeerSetWeights(t *testing.T) {
+	peers := newTestPeers(t, 4)
+	peerSet := NewPeerSet(peers)
+
+	if tw := peerSet.TotalWeight(); tw != 4 {
+		t.Fatalf("TotalWeight should be 4, not %d", tw)
+	}
+
+	if sm := peerSet.SuperMajority(); sm != 3 {
+		t.Fatalf("SuperMajority should be 3, not %d", sm)
+	}
+
+	if tc := peerSet.TrustCount(); tc != 2 {
+		t.Fatalf("TrustCount should be 2, not %d", tc)
+	}
+
+	hash, _ := peerSet.Hash()
+
+	// An explicit weight of 1 does not change the hash
+	unit := *peers[0]
+	unit.Weight = 1
+	unitHash, _ := peerSet.WithPeerWeight(&unit).Hash()
+	if !bytes.Equal(hash, unitHash) {
+		t.Fatal("Hash should not change with a weight of 1")
+	}
+
+	heavy := *peers[0]
+	heavy.Weight = 5
+	weighted := peerSet.WithPeerWeight(&heavy)
+
+	if w := peers[0].Weight; w != 0 {
+		t.Fatalf("WithPeerWeight should not modify the original peers")
+	}
+
+	if w := weighted.WeightOf(peers[0].PubKeyHex); w != 5 {
+		t.Fatalf("WeightOf should be 5, not %d", w)
+	}
+
+	if tw := weighted.TotalWeight(); tw != 8 {
+		t.Fatalf("TotalWeight should be 8, not %d", tw)
+	}
+
+	if sm := weighted.SuperMajority(); sm != 6 {
+		t.Fatalf("SuperMajority should be 6, not %d", sm)
+	}
+
+	if tc := weighted.TrustCount(); tc != 3 {
+		t.Fatalf("TrustCount should be 3, not %d", tc)
+	}
+
+	weightedHash, _ := weighted.Hash()
+	if bytes.Equal(hash, weightedHash) {
+		t.Fatal("Hash should change with weights")
+	}
+
+	if w := weighted.WeightOf("unknown"); w != 0 {
+		t.Fatalf("WeightOf unknown peer should be 0, not %d", w)
+	}
+}
+
+func TestPeerSetRotatedKey(t *testing.T) {
+	peers := newTestPeers(t, 3)
+	peers[1].Weight = 2
+	peerSet := NewPeerSet(peers)
+
+	newKey, _ := bkeys.GenerateECDSAKey()
+	newPubKey := bkeys.PublicKeyHex(&newKey.PublicKey)
+
+	rotated := peerSet.WithRotatedKey(peers[1], newPubKey)
+
+	if _, ok := rotated.ByID[peers[1].ID()]; ok {
+		t.Fatal("Old ID should not belong to the rotated PeerSet")
+	}
+
+	if _, ok := rotated.ByPubKey[peers[1].PubKeyString()]; ok {
+		t.Fatal("Old key should not belong to the rotated PeerSet")
+	}
+
+	p := rotated.Peers[1]
+	if p.PubKeyHex != newPubKey {
+		t.Fatalf("Peer 1 should have the new key")
+	}
+
+	if p.ID() != bkeys.PublicKeyID(bkeys.FromPublicKey(&newKey.PublicKey)) {
+		t.Fatalf("ID should be derived from the new key")
+	}
+
+	if rotated.ByID[p.ID()] != p {
+		t.Fatalf("ByID should index the new ID")
+	}
+
+	if p.NetAddr != peers[1].NetAddr || p.Moniker != peers[1].Moniker || p.Weight != 2 {
+		t.Fatalf("Rotated peer should keep address, moniker and weight: %v", p)
+	}
+
+	if rotated.TotalWeight() != peerSet.TotalWeight() {
+		t.Fatalf("TotalWeight should not change")
+	}
+
+	if peerSet.Peers[1].PubKeyHex != peers[1].PubKeyHex {
+		t.Fatal("WithRotatedKey should not modify the original PeerSet")
+	}
+
+	if again := rotated.WithRotatedKey(peers[1], newPubKey); again != rotated {
+		t.Fatal("Rotating to a key that already belongs to the PeerSet should not change it")
+	}
+}
+
+func TestPeerSetUpdatedPeer(t *testing.T) {
+	peers := newTestPeers(t, 3)
+	peers[1].Weight = 2
+	peerSet := NewPeerSet(peers)
+
+	update := NewPeer(peers[1].PubKeyHex, "new.addr:1337", "renamed")
+	updated := peerSet.WithUpdatedPeer(update)
+
+	p := updated.ByID[peers[1].ID()]
+	if p == nil || p != updated.Peers[1] {
+		t.Fatal("Updated peer should keep its ID and position")
+	}
+
+	if p.NetAddr != "new.addr:1337" || p.Moniker != "renamed" || p.Weight != 2 {
+		t.Fatalf("Updated peer should have the new address and moniker, and keep its weight: %v", p)
+	}
+
+	if updated.Hex() != peerSet.Hex() {
+		t.Fatal("Updating a peer should not change the PeerSet hash")
+	}
+
+	if peerSet.Peers[1].NetAddr != peers[1].NetAddr || peerSet.Peers[1].Moniker != peers[1].Moniker {
+		t.Fatal("WithUpdatedPeer should not modify the original PeerSet")
+	}
+}
@@ -3,6 +3,7 @@ package dummy
 import (
 	"github.com/sirupsen/logrus"
 
+	"github.com/Kdag-K/kdag/src/proxy"
 	"github.com/Kdag-K/kdag/src/proxy/inmem"
 )
 
@@ -19,10 +20,10 @@ type InmemDummyClient struct {
 func NewInmemDummyClient(logger *logrus.Entry) *InmemDummyClient {
 	state := NewState(logger)
 
-	proxy := inmem.NewInmemProxy(state, logger)
+	inmemProxy := inmem.NewInmemProxy(state, logger)
 
 	client := &InmemDummyClient{
-		InmemProxy: proxy,
+		InmemProxy: inmemProxy,
 		state:      state,
 		logger:     logger,
 	}
@@ -39,3 +40,13 @@ func (c *InmemDummyClient) SubmitTx(tx []byte) {
 func (c *InmemDummyClient) GetCommittedTransactions() [][]byte {
 	return c.state.GetCommittedTransactions()
 }
+
+//GetPeersChanges returns the state's list of validator-set changes
+func (c *InmemDummyClient) GetPeersChanges() []proxy.PeersChange {
+	return c.state.GetPeersChanges()
+}
+
+//GetStateHash returns the state's hash
+func (c *InmemDummyClient) GetStateHash() []byte {
+	return c.state.GetStateHash()
+}
@@ -1,7 +1,11 @@
 package dummy
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
 
 	"github.com/Kdag-K/kdag/src/crypto"
 	"github.com/Kdag-K/kdag/src/hashgraph"
@@ -23,9 +27,15 @@ import (
 type State struct {
 	committedTxs [][]byte
 	stateHash    []byte
+	stagedHash   []byte
 	snapshots    map[int][]byte
 	babbleState  state.State
+	peersChanges []proxy.PeersChange
 	logger       *logrus.Entry
+
+	// peersLock protects peersChanges, which tests read while the node
+	// appends to it.
+	peersLock sync.Mutex
 }
 
 // NewState creates a new state
@@ -34,6 +44,7 @@ func NewState(logger *logrus.Entry) *State {
 		committedTxs: [][]byte{},
 		stateHash:    []byte{},
 		snapshots:    make(map[int][]byte),
+		peersChanges: []proxy.PeersChange{},
 		logger:       logger,
 	}
 
@@ -89,6 +100,48 @@ func (a *State) RestoreHandler(snapshot []byte) ([]byte, error) {
 	return a.stateHash, nil
 }
 
+// SnapshotStreamHandler implements the SnapshotStreamHandler interface
+func (a *State) SnapshotStreamHandler(blockIndex int) (io.ReadCloser, error) {
+	snapshot, err := a.SnapshotHandler(blockIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(snapshot)), nil
+}
+
+// RestoreStreamHandler implements the SnapshotStreamHandler interface. The
+// snapshot is staged until CommitRestoreHandler is called.
+func (a *State) RestoreStreamHandler(snapshot io.Reader) ([]byte, error) {
+	staged, err := ioutil.ReadAll(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	a.stagedHash = staged
+
+	return a.stagedHash, nil
+}
+
+// CommitRestoreHandler implements the SnapshotStreamHandler interface
+func (a *State) CommitRestoreHandler() error {
+	if a.stagedHash == nil {
+		return fmt.Errorf("No staged restore")
+	}
+
+	a.stateHash = a.stagedHash
+	a.stagedHash = nil
+
+	return nil
+}
+
+// DiscardRestoreHandler implements the SnapshotStreamHandler interface
+func (a *State) DiscardRestoreHandler() error {
+	a.stagedHash = nil
+
+	return nil
+}
+
 // StateChangeHandler implements the ProxyHandler interface
 func (a *State) StateChangeHandler(state state.State) error {
 	a.babbleState = state
@@ -98,11 +151,41 @@ func (a *State) StateChangeHandler(state state.State) error {
 	return nil
 }
 
+// PeersChangeHandler implements the PeersChangeHandler interface
+func (a *State) PeersChangeHandler(change proxy.PeersChange) error {
+	a.peersLock.Lock()
+	a.peersChanges = append(a.peersChanges, change)
+	a.peersLock.Unlock()
+
+	a.logger.WithFields(logrus.Fields{
+		"decided_round":   change.DecidedRound,
+		"effective_round": change.EffectiveRound,
+		"added":           len(change.Added),
+		"removed":         len(change.Removed),
+		"updated":         len(change.Updated),
+	}).Debug("PeersChangeHandler")
+
+	return nil
+}
+
 // GetCommittedTransactions returns the list of committed transactions
 func (a *State) GetCommittedTransactions() [][]byte {
 	return a.committedTxs
 }
 
+// GetStateHash returns the state hash
+func (a *State) GetStateHash() []byte {
+	return a.stateHash
+}
+
+// GetPeersChanges returns the list of validator-set changes
+func (a *State) GetPeersChanges() []proxy.PeersChange {
+	a.peersLock.Lock()
+	defer a.peersLock.Unlock()
+
+	return append([]proxy.PeersChange{}, a.peersChanges...)
+}
+
 func (a *State) commit(block hashgraph.Block) error {
 	a.committedTxs = append(a.committedTxs, block.Transactions()...)
 
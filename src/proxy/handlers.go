@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"io"
+
 	"github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/node/state"
 )
@@ -27,3 +29,44 @@ type ProxyHandler interface {
 	// node entered a certain state
 	StateChangeHandler(state.State) error
 }
+
+// ConsensusEventHandler is an optional interface that a ProxyHandler can
+// implement to be notified of every Event that reaches consensus.
+type ConsensusEventHandler interface {
+	// ConsensusEventHandler is called by Kdag, in consensus order, for every
+	// Event whose round-received is decided
+	ConsensusEventHandler(event hashgraph.ConsensusEvent) error
+}
+
+// PeersChangeHandler is an optional interface that a ProxyHandler can
+// implement to be notified of validator-set changes.
+type PeersChangeHandler interface {
+	// PeersChangeHandler is called by Kdag when consensus decides a future
+	// validator-set, which only becomes effective at change.EffectiveRound
+	PeersChangeHandler(change PeersChange) error
+}
+
+// SnapshotStreamHandler is an optional interface that a ProxyHandler can
+// implement to stream snapshots, instead of holding them in memory. It also
+// restores snapshots in two steps, so that Kdag can check the resulting state
+// hash before the application's state is replaced, which fast-sync requires.
+type SnapshotStreamHandler interface {
+	// SnapshotStreamHandler is called by Kdag to read the snapshot
+	// corresponding to a particular block. Kdag closes the reader when it is
+	// done.
+	SnapshotStreamHandler(blockIndex int) (snapshot io.ReadCloser, err error)
+
+	// RestoreStreamHandler is called by Kdag to load a snapshot, that is read
+	// as it is fetched from other nodes, into a staged state, and returns the
+	// hash of that state. The application's state must not change until
+	// CommitRestoreHandler is called.
+	RestoreStreamHandler(snapshot io.Reader) (stateHash []byte, err error)
+
+	// CommitRestoreHandler is called by Kdag to replace the application's
+	// state with the staged state, once its hash has been checked
+	CommitRestoreHandler() error
+
+	// DiscardRestoreHandler is called by Kdag to discard the staged state,
+	// when its hash does not match the expected one
+	DiscardRestoreHandler() error
+}
@@ -3,6 +3,10 @@
 package inmem
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
 	"github.com/sirupsen/logrus"
 
 	hg "github.com/Kdag-K/kdag/src/hashgraph"
@@ -105,7 +109,80 @@ func (p *InmemProxy) Restore(snapshot []byte) error {
 	return err
 }
 
+// GetSnapshotStream calls the SnapshotStreamHandler if the ProxyHandler
+// implements it, and otherwise reads the snapshot returned by the
+// SnapshotHandler.
+func (p *InmemProxy) GetSnapshotStream(blockIndex int) (io.ReadCloser, error) {
+	if handler, ok := p.handler.(proxy.SnapshotStreamHandler); ok {
+		return handler.SnapshotStreamHandler(blockIndex)
+	}
+
+	snapshot, err := p.GetSnapshot(blockIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(snapshot)), nil
+}
+
+// RestoreStream calls the RestoreStreamHandler. It fails if the ProxyHandler
+// does not implement the SnapshotStreamHandler interface, because the
+// RestoreHandler replaces the App's state before its hash can be checked.
+func (p *InmemProxy) RestoreStream(snapshot io.Reader) ([]byte, error) {
+	handler, ok := p.handler.(proxy.SnapshotStreamHandler)
+	if !ok {
+		return nil, proxy.ErrNoStagedRestore
+	}
+
+	stateHash, err := handler.RestoreStreamHandler(snapshot)
+
+	p.logger.WithFields(logrus.Fields{
+		"state_hash": stateHash,
+		"err":        err,
+	}).Debug("InmemProxy.RestoreStream")
+
+	return stateHash, err
+}
+
+// CommitRestore calls the CommitRestoreHandler.
+func (p *InmemProxy) CommitRestore() error {
+	handler, ok := p.handler.(proxy.SnapshotStreamHandler)
+	if !ok {
+		return proxy.ErrNoStagedRestore
+	}
+
+	return handler.CommitRestoreHandler()
+}
+
+// DiscardRestore calls the DiscardRestoreHandler.
+func (p *InmemProxy) DiscardRestore() error {
+	handler, ok := p.handler.(proxy.SnapshotStreamHandler)
+	if !ok {
+		return proxy.ErrNoStagedRestore
+	}
+
+	return handler.DiscardRestoreHandler()
+}
+
 // OnStateChanged calls the StateChangeHandler.
 func (p *InmemProxy) OnStateChanged(state state.State) error {
 	return p.handler.StateChangeHandler(state)
 }
+
+// OnPeersChanged calls the PeersChangeHandler if the ProxyHandler implements
+// it.
+func (p *InmemProxy) OnPeersChanged(change proxy.PeersChange) error {
+	if handler, ok := p.handler.(proxy.PeersChangeHandler); ok {
+		return handler.PeersChangeHandler(change)
+	}
+	return nil
+}
+
+// OnConsensusEvent calls the ConsensusEventHandler if the ProxyHandler
+// implements it.
+func (p *InmemProxy) OnConsensusEvent(event hg.ConsensusEvent) error {
+	if handler, ok := p.handler.(proxy.ConsensusEventHandler); ok {
+		return handler.ConsensusEventHandler(event)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package proxy
 
 import (
+	"errors"
+	"io"
+
 	"github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/node/state"
 )
@@ -14,3 +17,41 @@ type AppGateway interface {
 	Restore(snapshot []byte) error
 	OnStateChanged(state.State) error
 }
+
+// ConsensusEventGateway is an optional interface that an AppGateway can
+// implement to receive every Event, in consensus order, as soon as its round is
+// decided. It is intended for apps that need fine-grained ordering and timing
+// information, beyond the Blocks passed to CommitBlock.
+type ConsensusEventGateway interface {
+	OnConsensusEvent(event hashgraph.ConsensusEvent) error
+}
+
+// PeersChangeGateway is an optional interface that an AppGateway can implement
+// to be notified of the changes of validator-set. OnPeersChanged is called
+// when the Block that decides a change is committed, at change.DecidedRound,
+// which is before change.FuturePeers becomes the validator-set at
+// change.EffectiveRound. A node that fast-forwards is only notified of the
+// changes decided from the Block it fast-forwards to; the validator-set at that
+// point can be read from the node's history.
+type PeersChangeGateway interface {
+	OnPeersChanged(change PeersChange) error
+}
+
+// SnapshotStreamGateway is an optional interface that an AppGateway can
+// implement to stream snapshots instead of passing them around in one piece,
+// which matters for apps with a large state. It also restores snapshots in two
+// steps: RestoreStream loads the snapshot into a staged state and reports its
+// hash, which Kdag checks against the StateHash of the Block that the node
+// fast-forwards to, before calling CommitRestore to replace the App's state
+// with the staged state, or DiscardRestore if the hashes do not match.
+type SnapshotStreamGateway interface {
+	GetSnapshotStream(blockIndex int) (io.ReadCloser, error)
+	RestoreStream(snapshot io.Reader) (stateHash []byte, err error)
+	CommitRestore() error
+	DiscardRestore() error
+}
+
+// ErrNoStagedRestore is returned when a snapshot is restored through the
+// SnapshotStreamGateway, but the App can only restore snapshots with the
+// RestoreHandler, which replaces its state before the state hash is checked.
+var ErrNoStagedRestore = errors.New("ProxyHandler does not implement SnapshotStreamHandler, which is required to check restored snapshots")
@@ -3,6 +3,7 @@
 package app
 
 import (
+	"io"
 	"time"
 
 	"github.com/Kdag-K/kdag/src/hashgraph"
@@ -82,7 +83,34 @@ func (p *SocketAppProxy) Restore(snapshot []byte) error {
 	return p.client.Restore(snapshot)
 }
 
+// GetSnapshotStream implements the SnapshotStreamGateway interface. The App
+// returns the snapshot in chunks.
+func (p *SocketAppProxy) GetSnapshotStream(blockIndex int) (io.ReadCloser, error) {
+	return p.client.GetSnapshotStream(blockIndex)
+}
+
+// RestoreStream implements the SnapshotStreamGateway interface. The snapshot
+// is sent to the App in chunks.
+func (p *SocketAppProxy) RestoreStream(snapshot io.Reader) ([]byte, error) {
+	return p.client.RestoreStream(snapshot)
+}
+
+// CommitRestore implements the SnapshotStreamGateway interface.
+func (p *SocketAppProxy) CommitRestore() error {
+	return p.client.CommitRestore()
+}
+
+// DiscardRestore implements the SnapshotStreamGateway interface.
+func (p *SocketAppProxy) DiscardRestore() error {
+	return p.client.DiscardRestore()
+}
+
 // OnStateChanged implements the AppGateway interface.
 func (p *SocketAppProxy) OnStateChanged(state state.State) error {
 	return p.client.OnStateChanged(state)
 }
+
+// OnPeersChanged implements the PeersChangeGateway interface.
+func (p *SocketAppProxy) OnPeersChanged(change proxy.PeersChange) error {
+	return p.client.OnPeersChanged(change)
+}
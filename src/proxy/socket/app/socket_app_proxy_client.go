@@ -1,6 +1,7 @@
 package app
 
 import (
+	"io"
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
@@ -9,6 +10,7 @@ import (
 	"github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/node/state"
 	"github.com/Kdag-K/kdag/src/proxy"
+	"github.com/Kdag-K/kdag/src/proxy/socket"
 	"github.com/sirupsen/logrus"
 )
 
@@ -109,6 +111,139 @@ func (p *SocketAppProxyClient) Restore(snapshot []byte) error {
 	return nil
 }
 
+// GetSnapshotStream implements the SnapshotStreamGateway interface. The
+// snapshot is read from the App in chunks of at most socket.SnapshotChunkSize,
+// one RPC per chunk.
+func (p *SocketAppProxyClient) GetSnapshotStream(blockIndex int) (io.ReadCloser, error) {
+	var stream uint64
+
+	if err := p.call("State.OpenSnapshot", blockIndex, &stream); err != nil {
+		return nil, err
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"block":  blockIndex,
+		"stream": stream,
+	}).Debug("AppProxyClient.GetSnapshotStream")
+
+	return &snapshotReader{client: p, stream: stream}, nil
+}
+
+// RestoreStream implements the SnapshotStreamGateway interface. The snapshot
+// is sent to the App in chunks of at most socket.SnapshotChunkSize, one RPC per
+// chunk, and loaded into a staged state whose hash is returned.
+func (p *SocketAppProxyClient) RestoreStream(snapshot io.Reader) ([]byte, error) {
+	var stream uint64
+
+	if err := p.call("State.BeginRestore", struct{}{}, &stream); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, socket.SnapshotChunkSize)
+	for {
+		n, err := io.ReadFull(snapshot, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			p.call("State.AbortRestore", stream, nil)
+			return nil, err
+		}
+
+		if n > 0 {
+			chunk := socket.SnapshotChunk{Stream: stream, Data: buf[:n]}
+			if err := p.call("State.WriteRestore", chunk, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	var stateHash []byte
+
+	if err := p.call("State.EndRestore", stream, &stateHash); err != nil {
+		return nil, err
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"stream":     stream,
+		"state_hash": stateHash,
+	}).Debug("AppProxyClient.RestoreStream")
+
+	return stateHash, nil
+}
+
+// CommitRestore implements the SnapshotStreamGateway interface. It replaces
+// the App's state with the state staged by RestoreStream.
+func (p *SocketAppProxyClient) CommitRestore() error {
+	return p.call("State.CommitRestore", struct{}{}, nil)
+}
+
+// DiscardRestore implements the SnapshotStreamGateway interface. It discards
+// the state staged by RestoreStream.
+func (p *SocketAppProxyClient) DiscardRestore() error {
+	return p.call("State.DiscardRestore", struct{}{}, nil)
+}
+
+// call performs an RPC, and drops the connection if it fails.
+func (p *SocketAppProxyClient) call(method string, args interface{}, reply interface{}) error {
+	if err := p.getConnection(); err != nil {
+		return err
+	}
+
+	if err := p.rpc.Call(method, args, reply); err != nil {
+		p.rpc = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// snapshotReader reads a snapshot opened with State.OpenSnapshot, one chunk at
+// a time.
+type snapshotReader struct {
+	client *SocketAppProxyClient
+	stream uint64
+	buf    []byte
+	eof    bool
+}
+
+// Read implements the io.Reader interface.
+func (r *snapshotReader) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		var chunk socket.SnapshotChunk
+		if err := r.client.call("State.ReadSnapshot", r.stream, &chunk); err != nil {
+			r.eof = true
+			return 0, err
+		}
+
+		r.buf = chunk.Data
+		r.eof = chunk.EOF
+	}
+
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+// Close implements the io.Closer interface. The App is told to close the
+// snapshot if it was not read entirely.
+func (r *snapshotReader) Close() error {
+	if r.eof {
+		return nil
+	}
+
+	r.eof = true
+
+	return r.client.call("State.CloseSnapshot", r.stream, nil)
+}
+
 // OnStateChanged implements the AppGateway interface
 func (p *SocketAppProxyClient) OnStateChanged(state state.State) error {
 	if err := p.getConnection(); err != nil {
@@ -127,3 +262,25 @@ func (p *SocketAppProxyClient) OnStateChanged(state state.State) error {
 
 	return nil
 }
+
+// OnPeersChanged implements the PeersChangeGateway interface
+func (p *SocketAppProxyClient) OnPeersChanged(change proxy.PeersChange) error {
+	if err := p.getConnection(); err != nil {
+		return err
+	}
+
+	if err := p.rpc.Call("State.OnPeersChanged", change, nil); err != nil {
+		p.rpc = nil
+
+		return err
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"effective_round": change.EffectiveRound,
+		"added":           len(change.Added),
+		"removed":         len(change.Removed),
+		"updated":         len(change.Updated),
+	}).Debug("AppProxyClient.OnPeersChanged")
+
+	return nil
+}
@@ -1,14 +1,20 @@
 package kdag
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+	"sync"
 	"time"
 
 	"github.com/Kdag-K/kdag/src/hashgraph"
 	"github.com/Kdag-K/kdag/src/node/state"
 	"github.com/Kdag-K/kdag/src/proxy"
+	"github.com/Kdag-K/kdag/src/proxy/socket"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,6 +26,24 @@ type SocketKdagProxyServer struct {
 	handler     proxy.ProxyHandler
 	timeout     time.Duration
 	logger      *logrus.Entry
+
+	// the snapshots that Kdag is reading or restoring, by stream
+	streamLock sync.Mutex
+	nextStream uint64
+	snapshots  map[uint64]io.ReadCloser
+	restores   map[uint64]*restoreStream
+}
+
+// restoreStream pipes the chunks of a snapshot to the handler that restores
+// it, and returns the resulting state hash on done.
+type restoreStream struct {
+	writer *io.PipeWriter
+	done   chan restoreResult
+}
+
+type restoreResult struct {
+	stateHash []byte
+	err       error
 }
 
 // NewSocketKdagProxyServer creates a new SocketKdagProxyServer
@@ -31,9 +55,11 @@ func NewSocketKdagProxyServer(
 ) (*SocketKdagProxyServer, error) {
 
 	server := &SocketKdagProxyServer{
-		handler: handler,
-		timeout: timeout,
-		logger:  logger,
+		handler:   handler,
+		timeout:   timeout,
+		logger:    logger,
+		snapshots: make(map[uint64]io.ReadCloser),
+		restores:  make(map[uint64]*restoreStream),
 	}
 
 	if err := server.register(bindAddress); err != nil {
@@ -121,3 +147,229 @@ func (p *SocketKdagProxyServer) OnStateChanged(state state.State, obj *struct{})
 
 	return
 }
+
+// OnPeersChanged implements the AppProxy interface. It is a no-op if the
+// handler does not implement the PeersChangeHandler interface.
+func (p *SocketKdagProxyServer) OnPeersChanged(change proxy.PeersChange, obj *struct{}) (err error) {
+	if handler, ok := p.handler.(proxy.PeersChangeHandler); ok {
+		err = handler.PeersChangeHandler(change)
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"effective_round": change.EffectiveRound,
+		"err":             err,
+	}).Debug("KdagProxyServer.OnPeersChanged")
+
+	return
+}
+
+// OpenSnapshot implements the AppProxy interface. It opens the snapshot of a
+// block, which Kdag then reads in chunks with ReadSnapshot. The snapshot is
+// streamed if the handler implements the SnapshotStreamHandler interface.
+func (p *SocketKdagProxyServer) OpenSnapshot(blockIndex int, stream *uint64) (err error) {
+	var snapshot io.ReadCloser
+
+	if handler, ok := p.handler.(proxy.SnapshotStreamHandler); ok {
+		snapshot, err = handler.SnapshotStreamHandler(blockIndex)
+	} else {
+		var snapshotBytes []byte
+		snapshotBytes, err = p.handler.SnapshotHandler(blockIndex)
+		snapshot = ioutil.NopCloser(bytes.NewReader(snapshotBytes))
+	}
+
+	if err == nil {
+		p.streamLock.Lock()
+		p.nextStream++
+		*stream = p.nextStream
+		p.snapshots[*stream] = snapshot
+		p.streamLock.Unlock()
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"block":  blockIndex,
+		"stream": *stream,
+		"err":    err,
+	}).Debug("KdagProxyServer.OpenSnapshot")
+
+	return
+}
+
+// ReadSnapshot implements the AppProxy interface. It returns the next chunk of
+// a snapshot opened with OpenSnapshot, and closes the snapshot after the last
+// chunk.
+func (p *SocketKdagProxyServer) ReadSnapshot(stream uint64, chunk *socket.SnapshotChunk) error {
+	p.streamLock.Lock()
+	snapshot, ok := p.snapshots[stream]
+	p.streamLock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Unknown snapshot stream %d", stream)
+	}
+
+	buf := make([]byte, socket.SnapshotChunkSize)
+	n, err := io.ReadFull(snapshot, buf)
+
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		chunk.EOF = true
+		p.CloseSnapshot(stream, nil)
+	default:
+		p.CloseSnapshot(stream, nil)
+		return err
+	}
+
+	chunk.Stream = stream
+	chunk.Data = buf[:n]
+
+	return nil
+}
+
+// CloseSnapshot implements the AppProxy interface. It closes a snapshot opened
+// with OpenSnapshot before it was read entirely.
+func (p *SocketKdagProxyServer) CloseSnapshot(stream uint64, obj *struct{}) error {
+	p.streamLock.Lock()
+	snapshot, ok := p.snapshots[stream]
+	delete(p.snapshots, stream)
+	p.streamLock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return snapshot.Close()
+}
+
+// BeginRestore implements the AppProxy interface. It starts loading a snapshot
+// into a staged state, whose chunks Kdag then sends with WriteRestore. The
+// handler must implement the SnapshotStreamHandler interface, so that the
+// application's state is only replaced by CommitRestore, once Kdag has checked
+// the state hash returned by EndRestore.
+func (p *SocketKdagProxyServer) BeginRestore(obj struct{}, stream *uint64) error {
+	handler, ok := p.handler.(proxy.SnapshotStreamHandler)
+	if !ok {
+		return proxy.ErrNoStagedRestore
+	}
+
+	reader, writer := io.Pipe()
+
+	restore := &restoreStream{
+		writer: writer,
+		done:   make(chan restoreResult, 1),
+	}
+
+	go func() {
+		var res restoreResult
+
+		res.stateHash, res.err = handler.RestoreStreamHandler(reader)
+
+		// unblock WriteRestore if the handler stopped reading
+		reader.CloseWithError(res.err)
+
+		restore.done <- res
+	}()
+
+	p.streamLock.Lock()
+	p.nextStream++
+	*stream = p.nextStream
+	p.restores[*stream] = restore
+	p.streamLock.Unlock()
+
+	p.logger.WithField("stream", *stream).Debug("KdagProxyServer.BeginRestore")
+
+	return nil
+}
+
+// WriteRestore implements the AppProxy interface. It passes a chunk of the
+// snapshot to the restore started with BeginRestore.
+func (p *SocketKdagProxyServer) WriteRestore(chunk socket.SnapshotChunk, obj *struct{}) error {
+	p.streamLock.Lock()
+	restore, ok := p.restores[chunk.Stream]
+	p.streamLock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Unknown restore stream %d", chunk.Stream)
+	}
+
+	if _, err := restore.writer.Write(chunk.Data); err != nil {
+		p.AbortRestore(chunk.Stream, nil)
+		return err
+	}
+
+	return nil
+}
+
+// EndRestore implements the AppProxy interface. It signals the end of the
+// snapshot to the restore started with BeginRestore, and returns the hash of
+// the staged state.
+func (p *SocketKdagProxyServer) EndRestore(stream uint64, stateHash *[]byte) error {
+	p.streamLock.Lock()
+	restore, ok := p.restores[stream]
+	delete(p.restores, stream)
+	p.streamLock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Unknown restore stream %d", stream)
+	}
+
+	restore.writer.Close()
+	res := <-restore.done
+
+	*stateHash = res.stateHash
+
+	p.logger.WithFields(logrus.Fields{
+		"stream":     stream,
+		"state_hash": stateHash,
+		"err":        res.err,
+	}).Debug("KdagProxyServer.EndRestore")
+
+	return res.err
+}
+
+// AbortRestore implements the AppProxy interface. It interrupts a restore
+// started with BeginRestore.
+func (p *SocketKdagProxyServer) AbortRestore(stream uint64, obj *struct{}) error {
+	p.streamLock.Lock()
+	restore, ok := p.restores[stream]
+	delete(p.restores, stream)
+	p.streamLock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	restore.writer.CloseWithError(fmt.Errorf("Restore aborted"))
+	<-restore.done
+
+	return nil
+}
+
+// CommitRestore implements the AppProxy interface. It replaces the
+// application's state with the state staged by the last restore.
+func (p *SocketKdagProxyServer) CommitRestore(obj struct{}, ack *struct{}) error {
+	handler, ok := p.handler.(proxy.SnapshotStreamHandler)
+	if !ok {
+		return proxy.ErrNoStagedRestore
+	}
+
+	err := handler.CommitRestoreHandler()
+
+	p.logger.WithField("err", err).Debug("KdagProxyServer.CommitRestore")
+
+	return err
+}
+
+// DiscardRestore implements the AppProxy interface. It discards the state
+// staged by the last restore.
+func (p *SocketKdagProxyServer) DiscardRestore(obj struct{}, ack *struct{}) error {
+	handler, ok := p.handler.(proxy.SnapshotStreamHandler)
+	if !ok {
+		return proxy.ErrNoStagedRestore
+	}
+
+	err := handler.DiscardRestoreHandler()
+
+	p.logger.WithField("err", err).Debug("KdagProxyServer.DiscardRestore")
+
+	return err
+}
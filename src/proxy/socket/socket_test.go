@@ -0,0 +1,162 @@
+package socket_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Kdag-K/kdag/src/common"
+	"github.com/Kdag-K/kdag/src/crypto"
+	"github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/node/state"
+	"github.com/Kdag-K/kdag/src/proxy"
+	"github.com/Kdag-K/kdag/src/proxy/socket"
+	"github.com/Kdag-K/kdag/src/proxy/socket/app"
+	"github.com/Kdag-K/kdag/src/proxy/socket/kdag"
+)
+
+// snapshotHandler serves a single snapshot, and restores the App from any
+// snapshot by recording it.
+type snapshotHandler struct {
+	snapshot []byte
+	staged   []byte
+	restored []byte
+}
+
+func (h *snapshotHandler) CommitHandler(block hashgraph.Block) (proxy.CommitResponse, error) {
+	return proxy.CommitResponse{}, nil
+}
+
+func (h *snapshotHandler) SnapshotHandler(blockIndex int) ([]byte, error) {
+	return h.snapshot, nil
+}
+
+func (h *snapshotHandler) RestoreHandler(snapshot []byte) ([]byte, error) {
+	h.restored = snapshot
+	return crypto.SHA256(snapshot), nil
+}
+
+func (h *snapshotHandler) StateChangeHandler(state.State) error {
+	return nil
+}
+
+func (h *snapshotHandler) SnapshotStreamHandler(blockIndex int) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(h.snapshot)), nil
+}
+
+func (h *snapshotHandler) RestoreStreamHandler(snapshot io.Reader) ([]byte, error) {
+	staged, err := ioutil.ReadAll(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	h.staged = staged
+	return crypto.SHA256(staged), nil
+}
+
+func (h *snapshotHandler) CommitRestoreHandler() error {
+	h.restored = h.staged
+	h.staged = nil
+	return nil
+}
+
+func (h *snapshotHandler) DiscardRestoreHandler() error {
+	h.staged = nil
+	return nil
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestSnapshotChunks(t *testing.T) {
+	logger := common.NewTestEntry(t, common.TestLogLevel)
+
+	// A snapshot larger than two chunks
+	snapshot := make([]byte, 2*socket.SnapshotChunkSize+1000)
+	for i := range snapshot {
+		snapshot[i] = byte(i)
+	}
+
+	handler := &snapshotHandler{snapshot: snapshot}
+
+	appAddr := freeAddr(t)
+	kdagAddr := freeAddr(t)
+
+	if _, err := kdag.NewSocketKdagProxy(kdagAddr, appAddr, handler, time.Second, logger); err != nil {
+		t.Fatal(err)
+	}
+
+	appProxy, err := app.NewSocketAppProxy(appAddr, kdagAddr, time.Second, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := appProxy.GetSnapshotStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+
+	if !bytes.Equal(res, snapshot) {
+		t.Fatalf("Snapshot should be read entirely, got %d bytes out of %d", len(res), len(snapshot))
+	}
+
+	stateHash, err := appProxy.RestoreStream(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(stateHash, crypto.SHA256(snapshot)) {
+		t.Fatalf("RestoreStream should return the staged state hash, not %X", stateHash)
+	}
+
+	if handler.restored != nil {
+		t.Fatal("App should not be restored before CommitRestore")
+	}
+
+	if err := appProxy.CommitRestore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(handler.restored, snapshot) {
+		t.Fatalf("App should be restored from the whole snapshot, got %d bytes out of %d", len(handler.restored), len(snapshot))
+	}
+
+	// A discarded restore does not change the App
+	if _, err := appProxy.RestoreStream(bytes.NewReader([]byte("other"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := appProxy.DiscardRestore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(handler.restored, snapshot) || handler.staged != nil {
+		t.Fatal("Discarded restore should not change the App")
+	}
+
+	// A snapshot that is not read entirely can be closed
+	reader, err = appProxy.GetSnapshotStream(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
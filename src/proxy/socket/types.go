@@ -0,0 +1,20 @@
+package socket
+
+// SnapshotChunkSize is the maximum size of the pieces in which snapshots are
+// passed between Kdag and the App, so that a large snapshot is never held in a
+// single RPC.
+const SnapshotChunkSize = 1 << 20
+
+// SnapshotChunk is a piece of a snapshot. Kdag reads the App's snapshots with
+// the State.OpenSnapshot, State.ReadSnapshot, and State.CloseSnapshot RPCs, and
+// stages the App's restored state with the State.BeginRestore,
+// State.WriteRestore, and State.EndRestore (or State.AbortRestore) RPCs, before
+// replacing the App's state with State.CommitRestore, or discarding it with
+// State.DiscardRestore. Stream identifies the snapshot returned by
+// State.OpenSnapshot or State.BeginRestore, and EOF is set on the last chunk
+// read from a snapshot.
+type SnapshotChunk struct {
+	Stream uint64
+	Data   []byte
+	EOF    bool
+}
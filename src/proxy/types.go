@@ -1,6 +1,9 @@
 package proxy
 
-import "github.com/Kdag-K/kdag/src/hashgraph"
+import (
+	"github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
 
 // CommitResponse ...
 type CommitResponse struct {
@@ -8,6 +11,61 @@ type CommitResponse struct {
 	InternalTransactionReceipts []hashgraph.InternalTransactionReceipt
 }
 
+// PeersChange describes a future change of validator-set. It is passed to the
+// App when the Block received in DecidedRound decides the change, but
+// FuturePeers only becomes the validator-set at EffectiveRound; until then,
+// consensus keeps running with PreviousPeers, which is itself a future
+// validator-set if another change was decided and is not effective yet. Added,
+// Removed, and Updated are the difference between PreviousPeers and
+// FuturePeers. Updated contains the new version of the peers whose address,
+// moniker, or weight changed; a rotated key appears as a peer removed and a
+// peer added.
+type PeersChange struct {
+	DecidedRound   int
+	EffectiveRound int
+	PreviousPeers  []*peers.Peer
+	FuturePeers    []*peers.Peer
+	Added          []*peers.Peer
+	Removed        []*peers.Peer
+	Updated        []*peers.Peer
+}
+
+// NewPeersChange creates a PeersChange from the previous and future
+// validator-sets.
+func NewPeersChange(decidedRound, effectiveRound int, oldPeers, newPeers *peers.PeerSet) PeersChange {
+	change := PeersChange{
+		DecidedRound:   decidedRound,
+		EffectiveRound: effectiveRound,
+		PreviousPeers:  oldPeers.Peers,
+		FuturePeers:    newPeers.Peers,
+		Added:          []*peers.Peer{},
+		Removed:        []*peers.Peer{},
+		Updated:        []*peers.Peer{},
+	}
+
+	for _, p := range newPeers.Peers {
+		old, ok := oldPeers.ByPubKey[p.PubKeyString()]
+		if !ok {
+			change.Added = append(change.Added, p)
+			continue
+		}
+
+		if old.NetAddr != p.NetAddr ||
+			old.Moniker != p.Moniker ||
+			old.VotingWeight() != p.VotingWeight() {
+			change.Updated = append(change.Updated, p)
+		}
+	}
+
+	for _, p := range oldPeers.Peers {
+		if _, ok := newPeers.ByPubKey[p.PubKeyString()]; !ok {
+			change.Removed = append(change.Removed, p)
+		}
+	}
+
+	return change
+}
+
 // CommitCallback ...
 type CommitCallback func(block hashgraph.Block) (CommitResponse, error)
 
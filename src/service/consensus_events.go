@@ -0,0 +1,57 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StreamConsensusEvents streams the events that reach consensus, in consensus
+// order, as Server-Sent Events. Every message carries a JSON
+// hashgraph.ConsensusEvent, and its id is the event's position in the total
+// order. Only the events decided after the request are streamed. If the client
+// does not keep up, events are dropped, which it can detect from gaps in the
+// ids.
+//
+//  GET /consensusevents
+//  returns: text/event-stream of hashgraph.ConsensusEvent
+func (s *Service) StreamConsensusEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := s.node.SubscribeConsensusEvents()
+	defer unsubscribe()
+
+	// enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				s.logger.WithError(err).Error("Marshalling consensus event")
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Position, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	_state "github.com/Kdag-K/kdag/src/node/state"
+)
+
+// Leave makes the node leave the network politely, and streams the progress of
+// the leave workflow as Server-Sent Events. Every message but the last carries
+// the state the node went through (Leaving, LeaveAccepted, Left); the last one
+// carries the error, if the node could not leave. The node shuts down once it
+// has left, so the stream may end right after the Left state. Only requests
+// from the loopback interface are accepted, and no CORS header is set, so that
+// neither remote clients nor web pages can make the node leave.
+//
+//  POST /leave
+//  returns: text/event-stream of JSON {"state": string} or {"error": string}
+func (s *Service) Leave(w http.ResponseWriter, r *http.Request) {
+	if !fromLoopback(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(msg map[string]string) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			s.logger.WithError(err).Error("Marshalling leave progress")
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	// The progress function is called from the goroutine of this request, so
+	// it is safe to write the response from it.
+	err := s.node.LeaveWithProgress(func(state _state.State) {
+		send(map[string]string{"state": state.String()})
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Leaving")
+		send(map[string]string{"error": err.Error()})
+	}
+}
+
+// fromLoopback returns true if the request comes from the loopback interface.
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
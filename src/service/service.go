@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/Kdag-K/kdag/src/common"
 	hg "github.com/Kdag-K/kdag/src/hashgraph"
 
 	"github.com/Kdag-K/kdag/src/node"
@@ -51,11 +52,26 @@ func (s *Service) registerHandlers() {
 	http.HandleFunc("/stats", s.makeHandler(s.GetStats))
 	http.HandleFunc("/block/", s.makeHandler(s.GetBlock))
 	http.HandleFunc("/blocks/", s.makeHandler(s.GetBlocks))
+	http.HandleFunc("/blocksbytime", s.makeHandler(s.GetBlocksByTime))
+	http.HandleFunc("/verifyblock/", s.makeHandler(s.VerifyBlock))
+	http.HandleFunc("/tx/", s.makeHandler(s.GetTxLocation))
 	http.HandleFunc("/graph", s.makeHandler(s.GetGraph))
 	http.HandleFunc("/peers", s.makeHandler(s.GetPeers))
 	http.HandleFunc("/genesispeers", s.makeHandler(s.GetGenesisPeers))
 	http.HandleFunc("/validators/", s.makeHandler(s.GetValidatorSet))
 	http.HandleFunc("/history", s.makeHandler(s.GetAllValidatorSets))
+	http.HandleFunc("/debug/rounds/", s.makeHandler(s.GetRoundTrace))
+
+	// The event stream is long-lived, so it must not hold the service lock.
+	http.HandleFunc("/consensusevents", s.StreamConsensusEvents)
+
+	// Removals and key rotations wait for consensus, so they must not hold
+	// the service lock either.
+	http.HandleFunc("/validators/remove", s.RemoveValidator)
+	http.HandleFunc("/validators/rotate", s.RotateKey)
+
+	// Leaving waits for the node to be removed from the validator-set.
+	http.HandleFunc("/leave", s.Leave)
 }
 
 func (s *Service) makeHandler(fn func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
@@ -123,6 +139,34 @@ func (s *Service) GetBlock(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(block)
 }
 
+// VerifyBlock checks the signatures of a block against the validator-set that
+// was effective at the block's round-received, and reports which validators
+// legitimately signed it.
+//
+//  GET /verifyblock/{index}
+//  returns: JSON hashgraph.BlockVerification
+func (s *Service) VerifyBlock(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Path[len("/verifyblock/"):]
+
+	blockIndex, err := strconv.Atoi(param)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Parsing block_index parameter %s", param)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	verification, err := s.node.VerifyBlock(blockIndex)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Verifying block %d", blockIndex)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(verification)
+}
+
 // GetBlocks will fetch an array of blocks starting at {startIndex} and finishing
 // {counts<=MAXBLOCKS} blocks later. If no count param is provided it will just
 // return the index requested rather than listing blocks.
@@ -189,8 +233,94 @@ func (s *Service) GetBlocks(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(blocks)
 }
 
-// GetGraph ...
+// GetBlocksByTime returns the blocks whose timestamp is within [start, end],
+// limited to MAXBLOCKS blocks. Timestamps are unix timestamps in seconds. If no
+// end is provided, it defaults to start.
+//
+//  GET /blocksbytime?start={x}&end={y}
+//  example: /blocksbytime?start=1600000000&end=1600003600
+//  returns: JSON []hashgraph.Block
+func (s *Service) GetBlocksByTime(w http.ResponseWriter, r *http.Request) {
+	start, err := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Parsing start parameter")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	end := start
+	if qe := r.URL.Query().Get("end"); qe != "" {
+		end, err = strconv.ParseInt(qe, 10, 64)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Parsing end parameter")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	indexes, err := s.node.GetBlockIndexesByTime(start, end)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Retrieving blocks between %d and %d", start, end)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(indexes) > MAXBLOCKS {
+		indexes = indexes[:MAXBLOCKS]
+	}
+
+	blocks := []*hg.Block{}
+	for _, i := range indexes {
+		block, err := s.node.GetBlock(i)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Retrieving block %d", i)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		blocks = append(blocks, block)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocks)
+}
+
+// GetTxLocation returns the index of the block that contains a transaction,
+// and the position of the transaction within the block. The transaction is
+// identified by the hex encoding of its SHA256 hash.
+//
+//  GET /tx/{hash}
+//  returns: JSON hashgraph.TxLocation
+func (s *Service) GetTxLocation(w http.ResponseWriter, r *http.Request) {
+	txHash := r.URL.Path[len("/tx/"):]
+
+	loc, err := s.node.GetTxLocation(txHash)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Retrieving transaction %s", txHash)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loc)
+}
+
+// GetGraph returns the raw content of the hashgraph, or, when the format
+// parameter is set, a window of the hashgraph annotated with rounds, fame,
+// round-received and block membership, in Graphviz DOT or JSON format. The
+// window is selected by round (fromRound, toRound) and by event timestamp
+// (fromTime, toTime, in Unix seconds).
+//
+//  GET /graph
+//  returns: JSON node.Infos
+//
+//  GET /graph?format={dot|json}&fromRound={r}&toRound={r}&fromTime={t}&toTime={t}
+//  returns: DOT or JSON hashgraph.DAG
 func (s *Service) GetGraph(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		s.getDAG(w, r, format)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	encoder := json.NewEncoder(w)
@@ -200,6 +330,160 @@ func (s *Service) GetGraph(w http.ResponseWriter, r *http.Request) {
 	encoder.Encode(res)
 }
 
+func (s *Service) getDAG(w http.ResponseWriter, r *http.Request, format string) {
+	if format != "dot" && format != "json" {
+		http.Error(w, "Unknown format "+format, http.StatusBadRequest)
+		return
+	}
+
+	// toRound defaults to the last round, the other bounds to 0.
+	bounds := map[string]int64{"toRound": -1}
+	for _, name := range []string{"fromRound", "toRound", "fromTime", "toTime"} {
+		q := r.URL.Query().Get(name)
+		if q == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(q, 10, 64)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Parsing %s parameter", name)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bounds[name] = v
+	}
+
+	opts := hg.DAGOptions{
+		FromRound: int(bounds["fromRound"]),
+		ToRound:   int(bounds["toRound"]),
+		FromTime:  bounds["fromTime"],
+		ToTime:    bounds["toTime"],
+	}
+
+	dag, err := s.graph.GetDAG(opts)
+	if err != nil {
+		s.logger.WithError(err).Error("Exporting graph")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		dag.WriteDOT(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dag)
+}
+
+// RemoveValidator submits the node's vote to remove another validator. The
+// request body is a PEER_REMOVE InternalTransaction whose Proposer is the node
+// itself, signed with the node's private key; this is what authenticates the
+// request. It responds when the vote has gone through consensus, and reports
+// whether the validator was removed or if more votes are needed.
+//
+//  POST /validators/remove
+//  body: JSON hashgraph.InternalTransaction
+//  returns: JSON {"removed": bool}
+func (s *Service) RemoveValidator(w http.ResponseWriter, r *http.Request) {
+	// enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var itx hg.InternalTransaction
+	if err := json.NewDecoder(r.Body).Decode(&itx); err != nil {
+		s.logger.WithError(err).Error("Decoding removal proposal")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	removed, err := s.node.ProposeRemoval(itx)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Proposing removal of %s", itx.Body.Peer.PubKeyHex)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string]bool{"removed": removed})
+}
+
+// RotateKey replaces the public key of the node's validator with the key
+// stored in the node's data directory (priv_key.next). It responds when the
+// rotation has gone through consensus, with the round at which it takes effect.
+//
+//  POST /validators/rotate
+//  returns: JSON {"effective_round": int}
+func (s *Service) RotateKey(w http.ResponseWriter, r *http.Request) {
+	// enable CORS
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	effectiveRound, err := s.node.RotateKeyFromFile()
+	if err != nil {
+		s.logger.WithError(err).Error("Rotating key")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string]int{"effective_round": effectiveRound})
+}
+
+// GetRoundTrace returns the votes on the fame of a round's witnesses, and the
+// reason why the round is still pending, if it is. It requires the node to be
+// started with --trace-rounds. Without an index, it dumps the traces of all the
+// recent rounds.
+//
+//  GET /debug/rounds/{index}
+//  returns: JSON hashgraph.RoundTrace
+//
+//  GET /debug/rounds/
+//  returns: JSON []hashgraph.RoundTrace
+func (s *Service) GetRoundTrace(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Path[len("/debug/rounds/"):]
+
+	if param == "" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := s.node.DumpRoundTraces(w); err != nil {
+			s.logger.WithError(err).Error("Dumping round traces")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	roundIndex, err := strconv.Atoi(param)
+	if err != nil {
+		s.logger.WithError(err).Errorf("Parsing round_index parameter %s", param)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trace, err := s.node.GetRoundTrace(roundIndex)
+	if common.IsStore(err, common.KeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		s.logger.WithError(err).Errorf("Retrieving round trace %d", roundIndex)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(trace)
+}
+
 // GetPeers returns the node's current peers, which is not necessarily
 // equivalent to the current validator-set.
 //
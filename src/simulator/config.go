@@ -0,0 +1,130 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+// Partition splits the validators into groups that cannot sync with each other
+// during a range of ticks. Validators that are not listed in any group are
+// isolated from all the others.
+type Partition struct {
+	// From is the first tick of the partition.
+	From int
+	// To is the tick at which the partition heals.
+	To int
+	// Groups contains the IDs of the validators in each side of the partition.
+	Groups [][]int
+}
+
+// group returns the index of the group containing validator id, or -1-id if it
+// is not in any group.
+func (p Partition) group(id int) int {
+	for i, g := range p.Groups {
+		for _, v := range g {
+			if v == id {
+				return i
+			}
+		}
+	}
+	return -1 - id
+}
+
+// Config contains the parameters of a simulation.
+type Config struct {
+	// Validators is the number of validators in the genesis peer-set.
+	Validators int
+
+	// Seed seeds the scheduler, the validator keys, and the faults. Two runs
+	// with the same Config produce the same Events in the same consensus
+	// order.
+	Seed int64
+
+	// Ticks is the number of steps of the simulation. At every tick, one
+	// validator sends a sync to a random peer.
+	Ticks int
+
+	// TxRate is the probability that a new Event carries a transaction.
+	TxRate float64
+
+	// MinDelay and MaxDelay bound the number of ticks it takes to deliver a
+	// sync. A delay of 0 delivers the sync at the beginning of the next tick.
+	MinDelay int
+	MaxDelay int
+
+	// DropRate is the probability that a sync is lost.
+	DropRate float64
+
+	// Partitions lists the network partitions.
+	Partitions []Partition
+
+	// Silent lists the IDs of validators that never send, nor process, syncs.
+	Silent []int
+
+	// Equivocators lists the IDs of validators that create forks.
+	Equivocators []int
+
+	// EquivocationRate is the probability that an equivocator sends a fork of
+	// its last Event instead of a normal sync.
+	EquivocationRate float64
+
+	// ConsensusParams are the fame-voting parameters of every Hashgraph.
+	ConsensusParams hg.ConsensusParams
+
+	// CacheSize is the size of the caches of every Hashgraph.
+	CacheSize int
+
+	// Logger is used by the Hashgraphs. If it is nil, their output is
+	// discarded.
+	Logger *logrus.Entry
+}
+
+// NewDefaultConfig returns a Config for 4 validators without faults.
+func NewDefaultConfig() *Config {
+	return &Config{
+		Validators: 4,
+		Seed:       1,
+		Ticks:      500,
+		TxRate:     1,
+		MinDelay:   0,
+		MaxDelay:   0,
+		CacheSize:  10000,
+
+		ConsensusParams: hg.DefaultConsensusParams(),
+	}
+}
+
+func (c *Config) validate() error {
+	if c.Validators < 2 {
+		return fmt.Errorf("Need at least 2 validators, not %d", c.Validators)
+	}
+
+	if c.MinDelay < 0 || c.MaxDelay < c.MinDelay {
+		return fmt.Errorf("Invalid delay range [%d, %d]", c.MinDelay, c.MaxDelay)
+	}
+
+	ids := append(append([]int{}, c.Silent...), c.Equivocators...)
+	for _, p := range c.Partitions {
+		for _, g := range p.Groups {
+			ids = append(ids, g...)
+		}
+	}
+	for _, id := range ids {
+		if id < 0 || id >= c.Validators {
+			return fmt.Errorf("Invalid validator ID %d", id)
+		}
+	}
+
+	if err := c.ConsensusParams.Validate(); err != nil {
+		return err
+	}
+
+	if len(c.Silent) >= c.Validators {
+		return fmt.Errorf("At least one validator must not be silent")
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+// Package simulator drives a set of Hashgraphs, one per validator, with a
+// seeded scheduler and configurable faults, to test the consensus algorithm
+// without a network.
+//
+// At every tick of the simulation, a random validator syncs with a random
+// peer. The sync carries the Events that the peer does not know, and when it is
+// delivered, the peer inserts them and records a new Event whose other-parent
+// is the sender's head, like Node.gossip does. Syncs can be delayed, dropped,
+// or blocked by network partitions. Validators can be silent, as if they had
+// crashed, or equivocate by sending forks of their own Events.
+//
+// After a run, CheckSafety verifies that all the validators agree on the order
+// of consensus Events, and on the Blocks and Frames they produced, while the
+// Report gives an indication of liveness.
+package simulator
@@ -0,0 +1,229 @@
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Kdag-K/kdag/src/common"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+// Report contains the liveness metrics of a simulation.
+type Report struct {
+	// Ticks is the number of ticks that were run.
+	Ticks int
+	// Events is the number of Events that were created, including forks.
+	Events int
+	// Forks is the number of forked Events sent by equivocators.
+	Forks int
+	// Syncs is the number of syncs that were initiated.
+	Syncs int
+	// DroppedSyncs is the number of syncs that were lost or blocked by a
+	// partition.
+	DroppedSyncs int
+	// DeliveredSyncs is the number of syncs that reached a validator that is
+	// not silent.
+	DeliveredSyncs int
+	// FailedSyncs is the number of delivered syncs that contained Events that
+	// could not be inserted.
+	FailedSyncs int
+	// Validators contains the metrics of every validator.
+	Validators []ValidatorReport
+}
+
+// ValidatorReport contains the liveness metrics of one validator.
+type ValidatorReport struct {
+	ID          int
+	Silent      bool
+	Equivocator bool
+	// ConsensusEvents is the number of Events that reached consensus.
+	ConsensusEvents int
+	// Blocks is the number of Blocks that were produced.
+	Blocks int
+	// LastConsensusRound is the last round that was decided, or -1.
+	LastConsensusRound int
+	// UndeterminedEvents is the number of Events that have not reached
+	// consensus yet.
+	UndeterminedEvents int
+	// MeanLatency is the average number of ticks between the creation of an
+	// Event and its consensus.
+	MeanLatency float64
+	// Fame describes how the fame of witnesses was decided.
+	Fame hg.FameStats
+}
+
+// MinBlocks returns the lowest number of Blocks produced by a validator that is
+// neither silent nor an equivocator.
+func (r *Report) MinBlocks() int {
+	min := -1
+	for _, v := range r.Validators {
+		if v.Silent || v.Equivocator {
+			continue
+		}
+		if min < 0 || v.Blocks < min {
+			min = v.Blocks
+		}
+	}
+	return min
+}
+
+// String returns a summary of the Report.
+func (r *Report) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "ticks=%d events=%d forks=%d syncs=%d dropped=%d delivered=%d failed=%d\n",
+		r.Ticks,
+		r.Events,
+		r.Forks,
+		r.Syncs,
+		r.DroppedSyncs,
+		r.DeliveredSyncs,
+		r.FailedSyncs)
+
+	for _, v := range r.Validators {
+		fmt.Fprintf(&b, "validator %d: silent=%v equivocator=%v consensus_events=%d blocks=%d last_consensus_round=%d undetermined_events=%d mean_latency=%.1f fame_decisions=%d mean_voting_rounds=%.2f max_voting_rounds=%d coin_rounds=%d coin_flips=%d exhausted=%d\n",
+			v.ID,
+			v.Silent,
+			v.Equivocator,
+			v.ConsensusEvents,
+			v.Blocks,
+			v.LastConsensusRound,
+			v.UndeterminedEvents,
+			v.MeanLatency,
+			v.Fame.DecidedWitnesses,
+			v.Fame.MeanVotingRounds(),
+			v.Fame.MaxVotingRounds,
+			v.Fame.CoinRounds,
+			v.Fame.CoinFlips,
+			v.Fame.Exhausted)
+	}
+
+	return b.String()
+}
+
+// Report returns the metrics of the simulation so far.
+func (s *Simulator) Report() *Report {
+	report := *s.report
+	report.Ticks = s.tick
+	report.Validators = []ValidatorReport{}
+
+	for _, v := range s.validators {
+		vr := ValidatorReport{
+			ID:                 v.id,
+			Silent:             v.silent,
+			Equivocator:        v.equivocator,
+			ConsensusEvents:    len(v.order),
+			Blocks:             v.hg.Store.LastBlockIndex() + 1,
+			LastConsensusRound: -1,
+			UndeterminedEvents: len(v.hg.UndeterminedEvents),
+			Fame:               v.hg.FameStats,
+		}
+
+		if v.hg.LastConsensusRound != nil {
+			vr.LastConsensusRound = *v.hg.LastConsensusRound
+		}
+
+		if len(v.order) > 0 {
+			vr.MeanLatency = float64(v.latencySum) / float64(len(v.order))
+		}
+
+		report.Validators = append(report.Validators, vr)
+	}
+
+	return &report
+}
+
+// CheckSafety verifies that every pair of validators agrees on the consensus
+// order of Events, and on the Blocks and Frames they have in common. Frames
+// that were evicted from a cache are not compared.
+func (s *Simulator) CheckSafety() error {
+	for i, a := range s.validators {
+		for _, b := range s.validators[i+1:] {
+			if err := checkPair(a, b); err != nil {
+				return fmt.Errorf("Validators %d and %d diverge: %v", a.id, b.id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func checkPair(a, b *validator) error {
+	for i := 0; i < len(a.order) && i < len(b.order); i++ {
+		if a.order[i] != b.order[i] {
+			return fmt.Errorf("consensus Event %d: %s != %s", i, a.order[i], b.order[i])
+		}
+	}
+
+	lastBlock := a.hg.Store.LastBlockIndex()
+	if l := b.hg.Store.LastBlockIndex(); l < lastBlock {
+		lastBlock = l
+	}
+
+	for i := 0; i <= lastBlock; i++ {
+		ba, err := a.hg.Store.GetBlock(i)
+		if err != nil {
+			return err
+		}
+
+		bb, err := b.hg.Store.GetBlock(i)
+		if err != nil {
+			return err
+		}
+
+		ha, err := ba.Body.Hash()
+		if err != nil {
+			return err
+		}
+
+		hb, err := bb.Body.Hash()
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(ha, hb) {
+			return fmt.Errorf("Block %d: %X != %X", i, ha, hb)
+		}
+	}
+
+	if a.hg.LastConsensusRound == nil || b.hg.LastConsensusRound == nil {
+		return nil
+	}
+
+	lastRound := *a.hg.LastConsensusRound
+	if l := *b.hg.LastConsensusRound; l < lastRound {
+		lastRound = l
+	}
+
+	for r := 0; r <= lastRound; r++ {
+		fa, err := a.hg.Store.GetFrame(r)
+		if common.IsStore(err, common.KeyNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		fb, err := b.hg.Store.GetFrame(r)
+		if common.IsStore(err, common.KeyNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		ha, err := fa.Hash()
+		if err != nil {
+			return err
+		}
+
+		hb, err := fb.Hash()
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(ha, hb) {
+			return fmt.Errorf("Frame %d: %X != %X", r, ha, hb)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+)
+
+// signEvent signs an Event with a nonce derived from the private key and the
+// Event's hash, instead of a random one. Ties in the consensus order are broken
+// by comparing signatures, so random signatures would make the consensus order
+// differ from one run to the next.
+func signEvent(key *ecdsa.PrivateKey, event *hg.Event) error {
+	hash, err := event.Body.Hash()
+	if err != nil {
+		return err
+	}
+
+	params := key.Curve.Params()
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(params.N, one)
+	e := new(big.Int).SetBytes(hash)
+
+	seed := append(key.D.Bytes(), hash...)
+	for {
+		digest := sha256.Sum256(seed)
+		seed = digest[:]
+
+		k := new(big.Int).SetBytes(digest[:])
+		k.Mod(k, nMinusOne).Add(k, one)
+
+		x, _ := key.Curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, params.N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		s := new(big.Int).Mul(r, key.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, params.N))
+		s.Mod(s, params.N)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		event.Signature = keys.EncodeSignature(r, s)
+
+		return nil
+	}
+}
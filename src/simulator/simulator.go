@@ -0,0 +1,389 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Kdag-K/kdag/src/crypto/keys"
+	hg "github.com/Kdag-K/kdag/src/hashgraph"
+	"github.com/Kdag-K/kdag/src/peers"
+)
+
+// validator is a simulated Kdag node.
+type validator struct {
+	id          int
+	key         *ecdsa.PrivateKey
+	pubKey      []byte
+	hg          *hg.Hashgraph
+	head        string
+	seq         int
+	txs         int
+	silent      bool
+	equivocator bool
+
+	// order records the consensus Events in the order they were decided.
+	order []string
+
+	latencySum int
+}
+
+// message is a sync in transit.
+type message struct {
+	from      int
+	to        int
+	deliver   int
+	seq       int
+	events    []hg.WireEvent
+	otherHead string
+}
+
+// Simulator runs a simulation.
+type Simulator struct {
+	conf       *Config
+	rng        *rand.Rand
+	validators []*validator
+	active     []*validator
+	queue      []*message
+	tick       int
+	seq        int
+
+	// created records the tick at which every Event was created.
+	created map[string]int
+
+	report *Report
+}
+
+// NewSimulator creates the validators and their Hashgraphs, and records the
+// first Event of every validator that is not silent.
+func NewSimulator(conf *Config) (*Simulator, error) {
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+
+	logger := conf.Logger
+	if logger == nil {
+		log := logrus.New()
+		log.Out = ioutil.Discard
+		logger = logrus.NewEntry(log)
+	}
+
+	s := &Simulator{
+		conf:    conf,
+		rng:     rand.New(rand.NewSource(conf.Seed)),
+		created: make(map[string]int),
+		report:  &Report{},
+	}
+
+	peerList := []*peers.Peer{}
+	for i := 0; i < conf.Validators; i++ {
+		key, err := s.generateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		v := &validator{
+			id:     i,
+			key:    key,
+			pubKey: keys.FromPublicKey(&key.PublicKey),
+			seq:    -1,
+		}
+
+		s.validators = append(s.validators, v)
+
+		peerList = append(peerList, peers.NewPeer(
+			keys.PublicKeyHex(&key.PublicKey),
+			fmt.Sprintf("sim%d", i),
+			fmt.Sprintf("validator%d", i)))
+	}
+
+	for _, id := range conf.Silent {
+		s.validators[id].silent = true
+	}
+	for _, id := range conf.Equivocators {
+		s.validators[id].equivocator = true
+	}
+
+	peerSet := peers.NewPeerSet(peerList)
+
+	for _, v := range s.validators {
+		v := v
+
+		v.hg = hg.NewHashgraph(hg.NewInmemStore(conf.CacheSize),
+			hg.DummyInternalCommitCallback,
+			logger.WithField("validator", v.id))
+
+		if err := v.hg.SetConsensusParams(conf.ConsensusParams); err != nil {
+			return nil, err
+		}
+
+		if err := v.hg.Init(peerSet); err != nil {
+			return nil, err
+		}
+
+		v.hg.SetConsensusEventCallback(func(event hg.ConsensusEvent) {
+			v.order = append(v.order, event.Hash)
+			v.latencySum += s.tick - s.created[event.Hash]
+		})
+
+		if !v.silent {
+			s.active = append(s.active, v)
+		}
+	}
+
+	for _, v := range s.active {
+		if err := s.createEvent(v, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// generateKey derives a private key from the scheduler's random source.
+func (s *Simulator) generateKey() (*ecdsa.PrivateKey, error) {
+	for i := 0; i < 10; i++ {
+		d := make([]byte, 32)
+		s.rng.Read(d)
+
+		if key, err := keys.ParsePrivateKey(d); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("Failed to generate key")
+}
+
+// Run runs the simulation for the configured number of ticks, and returns
+// the Report. It can be called multiple times to extend the simulation.
+func (s *Simulator) Run() (*Report, error) {
+	end := s.tick + s.conf.Ticks
+	for ; s.tick < end; s.tick++ {
+		if err := s.step(); err != nil {
+			return s.Report(), fmt.Errorf("Tick %d: %v", s.tick, err)
+		}
+	}
+	return s.Report(), nil
+}
+
+// step delivers the syncs that are due, and sends a new one.
+func (s *Simulator) step() error {
+	due := 0
+	for due < len(s.queue) && s.queue[due].deliver <= s.tick {
+		due++
+	}
+
+	deliveries := s.queue[:due]
+	s.queue = s.queue[due:]
+
+	for _, m := range deliveries {
+		if err := s.deliver(m); err != nil {
+			return err
+		}
+	}
+
+	from := s.active[s.rng.Intn(len(s.active))]
+
+	to := s.rng.Intn(len(s.validators) - 1)
+	if to >= from.id {
+		to++
+	}
+
+	return s.send(from, s.validators[to])
+}
+
+// send prepares a sync from one validator to another and queues it, unless it
+// is dropped.
+func (s *Simulator) send(from, to *validator) error {
+	s.report.Syncs++
+
+	// Always draw the same random numbers, so that changing a fault parameter
+	// does not change the rest of the schedule.
+	drop := s.rng.Float64() < s.conf.DropRate
+	delay := s.conf.MinDelay + s.rng.Intn(s.conf.MaxDelay-s.conf.MinDelay+1)
+	equivocate := s.rng.Float64() < s.conf.EquivocationRate
+
+	if drop || s.partitioned(from.id, to.id) {
+		s.report.DroppedSyncs++
+		return nil
+	}
+
+	m := &message{
+		from:    from.id,
+		to:      to.id,
+		deliver: s.tick + 1 + delay,
+		seq:     s.seq,
+	}
+	s.seq++
+
+	if from.equivocator && equivocate {
+		fork, err := s.fork(from)
+		if err != nil {
+			return err
+		}
+		m.events = []hg.WireEvent{fork.ToWire()}
+	} else {
+		events, err := s.eventDiff(from, to.hg.Store.KnownEvents())
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			m.events = append(m.events, e.ToWire())
+		}
+		m.otherHead = from.head
+	}
+
+	s.enqueue(m)
+
+	return nil
+}
+
+// enqueue inserts a message in the queue, which is sorted by delivery tick and
+// sequence number.
+func (s *Simulator) enqueue(m *message) {
+	i := sort.Search(len(s.queue), func(i int) bool {
+		q := s.queue[i]
+		return q.deliver > m.deliver || (q.deliver == m.deliver && q.seq > m.seq)
+	})
+
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = m
+}
+
+// partitioned returns true if a and b are separated by a partition at the
+// current tick.
+func (s *Simulator) partitioned(a, b int) bool {
+	for _, p := range s.conf.Partitions {
+		if s.tick >= p.From && s.tick < p.To && p.group(a) != p.group(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventDiff returns the Events known by v, with an index greater than the ones
+// in the known map, in topological order.
+func (s *Simulator) eventDiff(v *validator, known map[uint32]int) ([]*hg.Event, error) {
+	unknown := []*hg.Event{}
+
+	for id, peer := range v.hg.Store.RepertoireByID() {
+		ct, ok := known[id]
+		if !ok {
+			ct = -1
+		}
+
+		participantEvents, err := v.hg.Store.ParticipantEvents(peer.PubKeyString(), ct)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range participantEvents {
+			ev, err := v.hg.Store.GetEvent(e)
+			if err != nil {
+				return nil, err
+			}
+			unknown = append(unknown, ev)
+		}
+	}
+
+	sort.Sort(hg.ByTopologicalOrder(unknown))
+
+	return unknown, nil
+}
+
+// deliver inserts the Events of a sync in the receiver's Hashgraph, and records
+// a new Event on top of the sender's head.
+func (s *Simulator) deliver(m *message) error {
+	v := s.validators[m.to]
+	if v.silent {
+		return nil
+	}
+
+	s.report.DeliveredSyncs++
+
+	if _, err := v.hg.InsertWireEventsAndRunConsensus(m.events); err != nil {
+		s.report.FailedSyncs++
+	}
+
+	if m.otherHead == "" {
+		return nil
+	}
+
+	if _, err := v.hg.Store.GetEvent(m.otherHead); err != nil {
+		return nil
+	}
+
+	return s.createEvent(v, m.otherHead)
+}
+
+// createEvent signs and inserts a new Event for validator v.
+func (s *Simulator) createEvent(v *validator, otherHead string) error {
+	ev := hg.NewEvent(s.transactions(v),
+		nil,
+		nil,
+		[]string{v.head, otherHead},
+		v.pubKey,
+		v.seq+1)
+
+	ev.Body.Timestamp = int64(s.tick)
+
+	if err := signEvent(v.key, ev); err != nil {
+		return err
+	}
+
+	s.created[ev.Hex()] = s.tick
+	s.report.Events++
+
+	if err := v.hg.InsertEventAndRunConsensus(ev, true); err != nil {
+		return fmt.Errorf("Validator %d inserting own Event: %v", v.id, err)
+	}
+
+	v.head = ev.Hex()
+	v.seq = ev.Index()
+
+	return nil
+}
+
+// fork returns a new Event with the same parents and index as the head of v,
+// but different transactions. It is not inserted in v's Hashgraph.
+func (s *Simulator) fork(v *validator) (*hg.Event, error) {
+	head, err := v.hg.Store.GetEvent(v.head)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := hg.NewEvent([][]byte{[]byte(fmt.Sprintf("fork %d:%d", v.id, s.tick))},
+		nil,
+		nil,
+		head.Body.Parents,
+		v.pubKey,
+		head.Index())
+
+	ev.Body.Timestamp = int64(s.tick)
+
+	if err := signEvent(v.key, ev); err != nil {
+		return nil, err
+	}
+
+	if err := v.hg.SetWireInfo(ev); err != nil {
+		return nil, err
+	}
+
+	s.created[ev.Hex()] = s.tick
+	s.report.Events++
+	s.report.Forks++
+
+	return ev, nil
+}
+
+func (s *Simulator) transactions(v *validator) [][]byte {
+	if s.rng.Float64() >= s.conf.TxRate {
+		return nil
+	}
+	v.txs++
+	return [][]byte{[]byte(fmt.Sprintf("tx %d:%d", v.id, v.txs))}
+}
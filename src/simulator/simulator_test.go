@@ -0,0 +1,177 @@
+package simulator
+
+import (
+	"flag"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+var simRuns = flag.Int("sim.runs", 10, "Number of random simulations run by TestSafetyProperty")
+
+func runSimulation(t *testing.T, conf *Config) (*Simulator, *Report) {
+	sim, err := NewSimulator(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := sim.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(report)
+
+	if err := sim.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+
+	return sim, report
+}
+
+func TestSimulator(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(*Config)
+		// live indicates that the honest validators are expected to produce
+		// blocks despite the faults
+		live bool
+	}{
+		{
+			name:   "no faults",
+			modify: func(c *Config) {},
+			live:   true,
+		},
+		{
+			name: "delays",
+			modify: func(c *Config) {
+				c.MinDelay = 1
+				c.MaxDelay = 10
+			},
+			live: true,
+		},
+		{
+			name: "drops",
+			modify: func(c *Config) {
+				c.DropRate = 0.3
+			},
+			live: true,
+		},
+		{
+			name: "partition",
+			modify: func(c *Config) {
+				c.Partitions = []Partition{{From: 50, To: 300, Groups: [][]int{{0, 1}, {2, 3}}}}
+				c.Ticks = 800
+			},
+			live: true,
+		},
+		{
+			name: "silent validator",
+			modify: func(c *Config) {
+				c.Silent = []int{3}
+			},
+			live: true,
+		},
+		{
+			name: "frequent coin rounds",
+			modify: func(c *Config) {
+				c.ConsensusParams.CoinRoundFreq = 3
+				c.DropRate = 0.3
+			},
+			live: true,
+		},
+		{
+			name: "equivocator",
+			modify: func(c *Config) {
+				c.Equivocators = []int{0}
+				c.EquivocationRate = 0.2
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := NewDefaultConfig()
+			tc.modify(conf)
+
+			_, report := runSimulation(t, conf)
+
+			if tc.live && report.MinBlocks() == 0 {
+				t.Fatal("Honest validators should have produced blocks")
+			}
+		})
+	}
+}
+
+func TestDeterminism(t *testing.T) {
+	conf := NewDefaultConfig()
+	conf.MaxDelay = 5
+	conf.DropRate = 0.1
+
+	sim1, _ := runSimulation(t, conf)
+	sim2, _ := runSimulation(t, conf)
+
+	for i := range sim1.validators {
+		if !reflect.DeepEqual(sim1.validators[i].order, sim2.validators[i].order) {
+			t.Fatalf("Validator %d should have the same consensus order in both runs", i)
+		}
+	}
+}
+
+// TestSafetyProperty runs simulations with random faults, and checks that the
+// validators never diverge. The number of simulations is set by -sim.runs.
+func TestSafetyProperty(t *testing.T) {
+	property := func(seed int64) bool {
+		rng := rand.New(rand.NewSource(seed))
+
+		conf := NewDefaultConfig()
+		conf.Seed = seed
+		conf.Validators = 3 + rng.Intn(5)
+		conf.Ticks = 300
+		conf.TxRate = rng.Float64()
+		conf.MaxDelay = rng.Intn(10)
+		conf.DropRate = rng.Float64() / 2
+
+		if rng.Intn(2) == 0 {
+			from := rng.Intn(conf.Ticks)
+			conf.Partitions = []Partition{{
+				From:   from,
+				To:     from + rng.Intn(conf.Ticks),
+				Groups: [][]int{rng.Perm(conf.Validators)[:conf.Validators/2]},
+			}}
+		}
+
+		if rng.Intn(2) == 0 {
+			conf.Silent = []int{rng.Intn(conf.Validators)}
+		}
+
+		if rng.Intn(2) == 0 {
+			conf.Equivocators = []int{rng.Intn(conf.Validators)}
+			conf.EquivocationRate = rng.Float64() / 2
+		}
+
+		sim, err := NewSimulator(conf)
+		if err != nil {
+			t.Logf("seed %d: %v", seed, err)
+			return false
+		}
+
+		report, err := sim.Run()
+		if err != nil {
+			t.Logf("seed %d: %v", seed, err)
+			return false
+		}
+
+		if err := sim.CheckSafety(); err != nil {
+			t.Logf("seed %d: %v\n%s", seed, err, report)
+			return false
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: *simRuns}); err != nil {
+		t.Fatal(err)
+	}
+}